@@ -0,0 +1,151 @@
+// Package admin implements a JSON-RPC server for operational methods that must
+// not be exposed on the public Lightnode port, such as inspecting and
+// invalidating the cache. Other packages register their methods with the
+// server.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/renproject/darknode/jsonrpc"
+)
+
+// A Handler handles the params of an admin method and returns its result.
+type Handler func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// ErrInvalidParams is returned by a `Handler` when the given params are
+// invalid.
+var ErrInvalidParams = errors.New("invalid params")
+
+// InvalidParams returns an error that wraps `ErrInvalidParams` with the given
+// reason.
+func InvalidParams(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %v", ErrInvalidParams, fmt.Sprintf(format, args...))
+}
+
+// UnmarshalParams decodes the params of an admin method into the given value.
+// Missing params are treated as empty params.
+func UnmarshalParams(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return InvalidParams("%v", err)
+	}
+	return nil
+}
+
+// Server serves admin methods over JSON-RPC.
+type Server struct {
+	options Options
+
+	handlersMu *sync.RWMutex
+	handlers   map[string]Handler
+}
+
+// New constructs a new admin `Server` without any methods.
+func New(options Options) *Server {
+	return &Server{
+		options:    options,
+		handlersMu: new(sync.RWMutex),
+		handlers:   map[string]Handler{},
+	}
+}
+
+// Register the handler for the given method, replacing any existing handler.
+func (server *Server) Register(method string, handler Handler) {
+	server.handlersMu.Lock()
+	defer server.handlersMu.Unlock()
+
+	server.handlers[method] = handler
+}
+
+// Run the server until the context is canceled. It returns immediately if no
+// port has been configured.
+func (server *Server) Run(ctx context.Context) {
+	if server.options.Port == "" {
+		return
+	}
+
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%s", server.options.Port),
+		Handler: server,
+	}
+	go func() {
+		<-ctx.Done()
+		if err := httpServer.Close(); err != nil {
+			server.options.Logger.Errorf("[admin] cannot close server: %v", err)
+		}
+	}()
+
+	server.options.Logger.Infof("[admin] listening on port %v", server.options.Port)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		server.options.Logger.Errorf("[admin] cannot serve: %v", err)
+	}
+}
+
+// ServeHTTP implements the `http.Handler` interface.
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !server.authorised(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var req jsonrpc.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonErr := jsonrpc.NewError(jsonrpc.ErrorCodeInvalidJSON, fmt.Sprintf("invalid json: %v", err), nil)
+		server.write(w, jsonrpc.NewResponse(nil, nil, &jsonErr))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), server.options.Timeout)
+	defer cancel()
+	server.write(w, server.handle(ctx, req))
+}
+
+func (server *Server) handle(ctx context.Context, req jsonrpc.Request) jsonrpc.Response {
+	server.handlersMu.RLock()
+	handler, ok := server.handlers[req.Method]
+	server.handlersMu.RUnlock()
+	if !ok {
+		jsonErr := jsonrpc.NewError(jsonrpc.ErrorCodeMethodNotFound, fmt.Sprintf("unsupported method %v", req.Method), nil)
+		return jsonrpc.NewResponse(req.ID, nil, &jsonErr)
+	}
+
+	result, err := handler(ctx, req.Params)
+	if err != nil {
+		code := jsonrpc.ErrorCodeInternal
+		if errors.Is(err, ErrInvalidParams) {
+			code = jsonrpc.ErrorCodeInvalidParams
+		}
+		jsonErr := jsonrpc.NewError(code, err.Error(), nil)
+		return jsonrpc.NewResponse(req.ID, nil, &jsonErr)
+	}
+	return jsonrpc.NewResponse(req.ID, result, nil)
+}
+
+// authorised returns whether the request carries the configured bearer token.
+func (server *Server) authorised(r *http.Request) bool {
+	if server.options.Token == "" {
+		return true
+	}
+	expected := []byte("Bearer " + server.options.Token)
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) == 1
+}
+
+func (server *Server) write(w http.ResponseWriter, response jsonrpc.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.options.Logger.Errorf("[admin] cannot write response: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package admin_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/lightnode/admin"
+
+	"github.com/renproject/darknode/jsonrpc"
+)
+
+var _ = Describe("Admin server", func() {
+	send := func(server *Server, token, method string, params interface{}) *httptest.ResponseRecorder {
+		rawParams, err := json.Marshal(params)
+		Expect(err).ToNot(HaveOccurred())
+		body, err := json.Marshal(jsonrpc.Request{
+			Version: "2.0",
+			ID:      1,
+			Method:  method,
+			Params:  rawParams,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", fmt.Sprintf("Bearer %v", token))
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		return w
+	}
+
+	decode := func(w *httptest.ResponseRecorder) jsonrpc.Response {
+		var response jsonrpc.Response
+		Expect(json.NewDecoder(w.Body).Decode(&response)).To(Succeed())
+		return response
+	}
+
+	echo := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var value string
+		if err := json.Unmarshal(params, &value); err != nil {
+			return nil, InvalidParams("%v", err)
+		}
+		return value, nil
+	}
+
+	Context("when receiving a request for a registered method", func() {
+		It("should return the result of the handler", func() {
+			server := New(DefaultOptions())
+			server.Register("echo", echo)
+
+			w := send(server, "", "echo", "hello")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			response := decode(w)
+			Expect(response.Error).To(BeNil())
+			Expect(response.Result).To(Equal("hello"))
+		})
+
+		It("should return an invalid params error if the handler rejects the params", func() {
+			server := New(DefaultOptions())
+			server.Register("echo", echo)
+
+			response := decode(send(server, "", "echo", 1))
+			Expect(response.Error).ToNot(BeNil())
+			Expect(response.Error.Code).To(Equal(jsonrpc.ErrorCodeInvalidParams))
+		})
+	})
+
+	Context("when receiving a request for an unknown method", func() {
+		It("should return a method not found error", func() {
+			server := New(DefaultOptions())
+
+			response := decode(send(server, "", "unknown", nil))
+			Expect(response.Error).ToNot(BeNil())
+			Expect(response.Error.Code).To(Equal(jsonrpc.ErrorCodeMethodNotFound))
+		})
+	})
+
+	Context("when a token is configured", func() {
+		It("should reject requests without the token", func() {
+			server := New(DefaultOptions().WithToken("secret"))
+			server.Register("echo", echo)
+
+			Expect(send(server, "", "echo", "hello").Code).To(Equal(http.StatusUnauthorized))
+			Expect(send(server, "wrong", "echo", "hello").Code).To(Equal(http.StatusUnauthorized))
+			Expect(send(server, "secret", "echo", "hello").Code).To(Equal(http.StatusOK))
+		})
+	})
+})
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Enumerate default options.
+var (
+	DefaultPort    = ""
+	DefaultToken   = ""
+	DefaultTimeout = 15 * time.Second
+)
+
+// Options to configure the precise behaviour of the admin server.
+type Options struct {
+	Logger logrus.FieldLogger
+	// Port the admin server listens on. The server is disabled if no port is
+	// given.
+	Port string
+	// Token that requests must provide as a bearer token in the Authorization
+	// header. Requests are not authenticated if no token is given, so the port
+	// should not be publicly reachable.
+	Token string
+	// Timeout for handling a single request.
+	Timeout time.Duration
+}
+
+// DefaultOptions returns new options with default configurations that should
+// work for the majority of use cases.
+func DefaultOptions() Options {
+	return Options{
+		Logger:  logrus.New(),
+		Port:    DefaultPort,
+		Token:   DefaultToken,
+		Timeout: DefaultTimeout,
+	}
+}
+
+// WithLogger returns new options with the given logger.
+func (opts Options) WithLogger(logger logrus.FieldLogger) Options {
+	opts.Logger = logger
+	return opts
+}
+
+// WithPort returns new options with the given port.
+func (opts Options) WithPort(port string) Options {
+	opts.Port = port
+	return opts
+}
+
+// WithToken returns new options with the given authentication token.
+func (opts Options) WithToken(token string) Options {
+	opts.Token = token
+	return opts
+}
+
+// WithTimeout returns new options with the given request timeout.
+func (opts Options) WithTimeout(timeout time.Duration) Options {
+	opts.Timeout = timeout
+	return opts
+}
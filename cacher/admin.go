@@ -0,0 +1,189 @@
+package cacher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/lightnode/admin"
+	"github.com/renproject/phi"
+)
+
+// Enumerate the admin methods served by the `Cacher`.
+const (
+	MethodCacheKeys       = "cache_keys"
+	MethodCacheEntry      = "cache_entry"
+	MethodCacheInvalidate = "cache_invalidate"
+	MethodCacheStats      = "cache_stats"
+)
+
+// EntryInfo describes a cached response.
+type EntryInfo struct {
+	Key        string            `json:"key"`
+	Method     string            `json:"method"`
+	Params     json.RawMessage   `json:"params,omitempty"`
+	Expiry     time.Time         `json:"expiry"`
+	StaleUntil time.Time         `json:"staleUntil"`
+	Response   *jsonrpc.Response `json:"response,omitempty"`
+}
+
+// Stats are the statistics the `Cacher` has collected since it started.
+type Stats struct {
+	Entries       int    `json:"entries"`
+	Bytes         int    `json:"bytes"`
+	Hits          uint64 `json:"hits"`
+	StaleHits     uint64 `json:"staleHits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+}
+
+// ListRequest is a message which asks the `Cacher` for all cached entries for
+// the given method, or for all methods if none is given. The entries do not
+// include their responses.
+type ListRequest struct {
+	Method    string
+	Responder chan []EntryInfo
+}
+
+// IsMessage implements the `phi.Message` interface.
+func (ListRequest) IsMessage() {}
+
+// InspectRequest is a message which asks the `Cacher` for the entry with the
+// given key. A nil entry is written to the responder if it cannot be found.
+type InspectRequest struct {
+	Key       string
+	Responder chan *EntryInfo
+}
+
+// IsMessage implements the `phi.Message` interface.
+func (InspectRequest) IsMessage() {}
+
+// InvalidateRequest is a message which tells the `Cacher` to delete all
+// entries for the given method and request hash. An empty method or hash
+// matches all entries. The number of deleted entries is written to the
+// responder.
+type InvalidateRequest struct {
+	Method    string
+	Hash      string
+	Responder chan int
+}
+
+// IsMessage implements the `phi.Message` interface.
+func (InvalidateRequest) IsMessage() {}
+
+// StatsRequest is a message which asks the `Cacher` for its statistics.
+type StatsRequest struct {
+	Responder chan Stats
+}
+
+// IsMessage implements the `phi.Message` interface.
+func (StatsRequest) IsMessage() {}
+
+// ParamsCacheKeys are the params of the `cache_keys` admin method.
+type ParamsCacheKeys struct {
+	Method string `json:"method"`
+}
+
+// ParamsCacheEntry are the params of the `cache_entry` admin method.
+type ParamsCacheEntry struct {
+	Key string `json:"key"`
+}
+
+// ParamsCacheInvalidate are the params of the `cache_invalidate` admin method.
+type ParamsCacheInvalidate struct {
+	Method string `json:"method"`
+	Hash   string `json:"hash"`
+}
+
+// ResponseCacheInvalidate is the result of the `cache_invalidate` admin method.
+type ResponseCacheInvalidate struct {
+	Invalidated int `json:"invalidated"`
+}
+
+// RegisterAdmin registers the admin methods for inspecting and invalidating
+// the cache of the given `Cacher` with the admin server.
+func RegisterAdmin(server *admin.Server, cacher phi.Sender) {
+	server.Register(MethodCacheKeys, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var params ParamsCacheKeys
+		if err := admin.UnmarshalParams(raw, &params); err != nil {
+			return nil, err
+		}
+		responder := make(chan []EntryInfo, 1)
+		if err := send(cacher, ListRequest{Method: params.Method, Responder: responder}); err != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case entries := <-responder:
+			return entries, nil
+		}
+	})
+
+	server.Register(MethodCacheEntry, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var params ParamsCacheEntry
+		if err := admin.UnmarshalParams(raw, &params); err != nil {
+			return nil, err
+		}
+		if params.Key == "" {
+			return nil, admin.InvalidParams("missing key")
+		}
+		responder := make(chan *EntryInfo, 1)
+		if err := send(cacher, InspectRequest{Key: params.Key, Responder: responder}); err != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case entry := <-responder:
+			if entry == nil {
+				return nil, admin.InvalidParams("entry %v not found", params.Key)
+			}
+			return entry, nil
+		}
+	})
+
+	server.Register(MethodCacheInvalidate, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var params ParamsCacheInvalidate
+		if err := admin.UnmarshalParams(raw, &params); err != nil {
+			return nil, err
+		}
+		if params.Method == "" && params.Hash == "" {
+			return nil, admin.InvalidParams("method or hash must be specified")
+		}
+		responder := make(chan int, 1)
+		if err := send(cacher, InvalidateRequest{Method: params.Method, Hash: params.Hash, Responder: responder}); err != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case n := <-responder:
+			return ResponseCacheInvalidate{Invalidated: n}, nil
+		}
+	})
+
+	server.Register(MethodCacheStats, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		responder := make(chan Stats, 1)
+		if err := send(cacher, StatsRequest{Responder: responder}); err != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case stats := <-responder:
+			return stats, nil
+		}
+	})
+}
+
+// send the message to the cacher, returning an error if its queue is full.
+func send(cacher phi.Sender, message phi.Message) error {
+	if ok := cacher.Send(message); !ok {
+		return errors.New("too much back pressure")
+	}
+	return nil
+}
@@ -1,11 +1,16 @@
 package cacher
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/renproject/darknode/engine"
 	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/darknode/tx"
 	"github.com/renproject/kv"
 	"github.com/renproject/lightnode/compat/v1"
 	"github.com/renproject/lightnode/db"
@@ -24,37 +29,134 @@ func (id ID) String() string {
 	return hex.EncodeToString(id[:])
 }
 
+// entry is a cached response along with the time it expires. A zero expiry
+// means the entry never expires. Once expired, the response may still be served
+// until it goes stale while it is being refreshed in the background.
+type entry struct {
+	Method     string           `json:"method"`
+	Params     json.RawMessage  `json:"params"`
+	Response   jsonrpc.Response `json:"response"`
+	Expiry     time.Time        `json:"expiry"`
+	StaleUntil time.Time        `json:"staleUntil"`
+}
+
+// info returns a description of the entry with the given key.
+func (e entry) info(key string, withResponse bool) EntryInfo {
+	info := EntryInfo{
+		Key:        key,
+		Method:     e.Method,
+		Params:     e.Params,
+		Expiry:     e.Expiry,
+		StaleUntil: e.StaleUntil,
+	}
+	if withResponse {
+		response := e.Response
+		info.Response = &response
+	}
+	return info
+}
+
+// expired returns whether the entry has expired at the given time.
+func (e entry) expired(now time.Time) bool {
+	return !e.Expiry.IsZero() && now.After(e.Expiry)
+}
+
+// evictable returns whether the entry can no longer be served at the given
+// time.
+func (e entry) evictable(now time.Time) bool {
+	return e.expired(now) && now.After(e.StaleUntil)
+}
+
 // Cacher is a task responsible for caching responses for corresponding
 // requests. Upon receiving a request it will check its cache to see if it has a
 // cached response. If it does, it will write this immediately as a response,
 // otherwise it will forward the request on to the `Dispatcher`. Once the
 // `Dispatcher` has a response ready, the `Cacher` will store this response in
 // its cache with a key derived from the request, and then pass the response
-// along to be given to the client.
+// along to be given to the client. How long a response is cached for depends
+// on its method and contents, as configured by the `Options`.
+//
+// Identical requests that miss the cache while a request is already in flight
+// are coalesced: only the first is forwarded to the `Dispatcher`, and all of
+// them receive its response.
+//
+// For methods configured to serve stale responses, an expired response is
+// returned immediately while it is refreshed in the background, as long as it
+// has not exceeded the maximum staleness.
+//
+// The cache is bounded by a maximum number of entries and bytes, measured on
+// the marshalled responses. When either bound is exceeded, the least recently
+// used entries are evicted.
+//
+// The cache can be inspected and invalidated by sending the `Cacher` a
+// `ListRequest`, `InspectRequest`, `InvalidateRequest` or `StatsRequest`.
 type Cacher struct {
 	logger     logrus.FieldLogger
 	dispatcher phi.Sender
 	db         db.DB
-	ttlCache   kv.Table
+	options    Options
+	lastPrune  time.Time
+	stats      Stats
+
+	// tableMu guards updates which must keep the table and the LRU in sync, as
+	// responses are inserted from outside of the handler.
+	tableMu  *sync.Mutex
+	ttlCache kv.Table
+	lru      *lru
+
+	inflightMu *sync.Mutex
+	inflight   map[string][]http.RequestWithResponder
 }
 
-// New constructs a new `Cacher` as a `phi.Task` which can be `Run()`.
-func New(dispatcher phi.Sender, logger logrus.FieldLogger, ttl kv.Table, opts phi.Options, db db.DB) phi.Task {
+// New constructs a new `Cacher` as a `phi.Task` which can be `Run()`. Expired
+// entries are removed from the given table periodically, so it does not need to
+// evict entries itself.
+func New(dispatcher phi.Sender, logger logrus.FieldLogger, table kv.Table, options Options, opts phi.Options, db db.DB) phi.Task {
 	return phi.New(&Cacher{
 		logger:     logger,
 		dispatcher: dispatcher,
 		db:         db,
-		ttlCache:   ttl,
+		options:    options,
+		lastPrune:  time.Now(),
+		tableMu:    new(sync.Mutex),
+		ttlCache:   table,
+		lru:        newLRU(options.MaxEntries, options.MaxBytes),
+		inflightMu: new(sync.Mutex),
+		inflight:   map[string][]http.RequestWithResponder{},
 	}, opts)
 }
 
 // Handle implements the `phi.Handler` interface.
 func (cacher *Cacher) Handle(_ phi.Task, message phi.Message) {
-	msg, ok := message.(http.RequestWithResponder)
-	if !ok {
+	var msg http.RequestWithResponder
+	refresh := false
+	switch message := message.(type) {
+	case http.RequestWithResponder:
+		msg = message
+	case RefreshRequest:
+		msg = message.Request
+		refresh = true
+	case ListRequest:
+		message.Responder <- cacher.list(message.Method)
+		return
+	case InspectRequest:
+		message.Responder <- cacher.inspect(message.Key)
+		return
+	case InvalidateRequest:
+		message.Responder <- cacher.invalidate(message.Method, message.Hash)
+		return
+	case StatsRequest:
+		message.Responder <- cacher.statistics()
+		return
+	default:
 		cacher.logger.Panicf("[cacher] unexpected message type %T", message)
 	}
 
+	if now := time.Now(); now.Sub(cacher.lastPrune) > cacher.options.PruneInterval {
+		cacher.prune(now)
+		cacher.lastPrune = now
+	}
+
 	paramsBytes, err := json.Marshal(msg.Params)
 	if err != nil {
 		cacher.logger.Errorf("[cacher] cannot marshal request to json: %v", err)
@@ -64,7 +166,12 @@ func (cacher *Cacher) Handle(_ phi.Task, message phi.Message) {
 
 	// Calculate the request ID.
 	data := append(paramsBytes, []byte(msg.Method)...)
-	reqID := sha3.Sum256(data)
+	reqID := ID(sha3.Sum256(data))
+
+	if refresh {
+		cacher.refresh(reqID, msg)
+		return
+	}
 
 	switch msg.Method {
 	case jsonrpc.MethodSubmitTx:
@@ -75,89 +182,377 @@ func (cacher *Cacher) Handle(_ phi.Task, message phi.Message) {
 	// The cacher will only be called when the darknode itself is queried
 	default:
 		darknodeID := msg.Query.Get("id")
-		response, cached := cacher.get(reqID, darknodeID)
+		response, fresh, cached := cacher.get(reqID, darknodeID)
 		if cached {
+			response.ID = msg.ID
 			msg.Responder <- response
+			if fresh {
+				cacher.stats.Hits++
+			} else {
+				cacher.stats.StaleHits++
+				cacher.refresh(reqID, msg)
+			}
 			return
 		}
+		cacher.stats.Misses++
+
+		// If an identical request is already in flight, wait for its response
+		// instead of dispatching another one.
+		if !cacher.coalesce(reqID.String()+darknodeID, msg) {
+			return
+		}
+
+		// The response is shared with every coalesced request, so it must
+		// not be cancelled when the first client disconnects.
+		cacher.dispatch(reqID, msg, true)
+		return
 	}
-	cacher.dispatch(reqID, msg)
+	cacher.dispatch(reqID, msg, false)
 }
 
-func (cacher *Cacher) insert(reqID ID, darknodeID string, response jsonrpc.Response) {
-	id := reqID.String() + darknodeID
-	if err := cacher.ttlCache.Insert(id, response); err != nil {
+// coalesce registers the request as in flight. It returns true if there is no
+// identical request in flight, in which case the caller is responsible for
+// dispatching it. Otherwise, the request will be responded to once the in
+// flight request receives its response.
+func (cacher *Cacher) coalesce(key string, msg http.RequestWithResponder) bool {
+	cacher.inflightMu.Lock()
+	defer cacher.inflightMu.Unlock()
+
+	waiters, ok := cacher.inflight[key]
+	if ok {
+		cacher.inflight[key] = append(waiters, msg)
+		return false
+	}
+	cacher.inflight[key] = []http.RequestWithResponder{}
+	return true
+}
+
+// refresh dispatches the request in the background to update its cached
+// response, unless an identical request is already in flight. The request is
+// detached from the context of the original request, as the client will have
+// already received a response.
+func (cacher *Cacher) refresh(reqID ID, msg http.RequestWithResponder) {
+	key := reqID.String() + msg.Query.Get("id")
+
+	cacher.inflightMu.Lock()
+	if _, ok := cacher.inflight[key]; ok {
+		cacher.inflightMu.Unlock()
+		return
+	}
+	cacher.inflight[key] = []http.RequestWithResponder{}
+	cacher.inflightMu.Unlock()
+
+	cacher.dispatch(reqID, http.NewRequestWithResponder(context.Background(), msg.ID, msg.Method, msg.Params, msg.Query), true)
+}
+
+// complete removes the in flight request with the given key and writes the
+// response to all requests that were waiting on it.
+func (cacher *Cacher) complete(key string, response jsonrpc.Response) {
+	cacher.inflightMu.Lock()
+	waiters := cacher.inflight[key]
+	delete(cacher.inflight, key)
+	cacher.inflightMu.Unlock()
+
+	for _, waiter := range waiters {
+		waiterResponse := response
+		waiterResponse.ID = waiter.ID
+		waiter.Responder <- waiterResponse
+	}
+}
+
+func (cacher *Cacher) insert(reqID ID, msg http.RequestWithResponder, response jsonrpc.Response, expiry, staleUntil time.Time) {
+	id := reqID.String() + msg.Query.Get("id")
+	params, err := json.Marshal(msg.Params)
+	if err != nil {
+		cacher.logger.Errorf("[cacher] cannot marshal request to json: %v", err)
+		return
+	}
+	e := entry{
+		Method:     msg.Method,
+		Params:     params,
+		Response:   response,
+		Expiry:     expiry,
+		StaleUntil: staleUntil,
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		cacher.logger.Errorf("[cacher] cannot marshal response to json: %v", err)
+		return
+	}
+
+	cacher.tableMu.Lock()
+	defer cacher.tableMu.Unlock()
+
+	for _, evicted := range cacher.lru.add(id, len(data)) {
+		if evicted == id {
+			cacher.logger.Warnf("[cacher] %v response of %v bytes exceeds the cache budget", msg.Method, len(data))
+			if err := cacher.ttlCache.Delete(id); err != nil {
+				cacher.logger.Warnf("[cacher] cannot delete evicted response from TTL cache: %v", err)
+			}
+			return
+		}
+		if err := cacher.ttlCache.Delete(evicted); err != nil {
+			cacher.logger.Warnf("[cacher] cannot delete evicted response from TTL cache: %v", err)
+		}
+	}
+	if err := cacher.ttlCache.Insert(id, e); err != nil {
 		cacher.logger.Errorf("[cacher] cannot insert response into TTL cache: %v", err)
+		cacher.lru.remove(id)
 		return
 	}
 }
 
-func (cacher *Cacher) get(reqID ID, darknodeID string) (jsonrpc.Response, bool) {
+// remove deletes the entry with the given key from the cache.
+func (cacher *Cacher) remove(id string) error {
+	cacher.tableMu.Lock()
+	defer cacher.tableMu.Unlock()
+
+	cacher.lru.remove(id)
+	return cacher.ttlCache.Delete(id)
+}
+
+// get returns the cached response for the request, whether it is still fresh,
+// and whether a response could be found.
+func (cacher *Cacher) get(reqID ID, darknodeID string) (jsonrpc.Response, bool, bool) {
 	id := reqID.String() + darknodeID
 
-	var response jsonrpc.Response
-	if err := cacher.ttlCache.Get(id, &response); err == nil {
-		return response, true
+	var e entry
+	if err := cacher.ttlCache.Get(id, &e); err != nil {
+		return jsonrpc.Response{}, false, false
+	}
+	now := time.Now()
+	if e.evictable(now) {
+		if err := cacher.remove(id); err != nil {
+			cacher.logger.Warnf("[cacher] cannot delete expired response from TTL cache: %v", err)
+		}
+		cacher.stats.Evictions++
+		return jsonrpc.Response{}, false, false
+	}
+
+	cacher.tableMu.Lock()
+	cacher.lru.touch(id)
+	cacher.tableMu.Unlock()
+
+	return e.Response, !e.expired(now), true
+}
+
+// prune removes all entries which have expired at the given time.
+func (cacher *Cacher) prune(now time.Time) {
+	expired := []string{}
+	iter := cacher.ttlCache.Iterator()
+	for iter.Next() {
+		id, err := iter.Key()
+		if err != nil {
+			cacher.logger.Warnf("[cacher] cannot read key from TTL cache: %v", err)
+			continue
+		}
+		var e entry
+		if err := iter.Value(&e); err != nil || e.evictable(now) {
+			expired = append(expired, id)
+		}
+	}
+	iter.Close()
+
+	for _, id := range expired {
+		if err := cacher.remove(id); err != nil {
+			cacher.logger.Warnf("[cacher] cannot delete expired response from TTL cache: %v", err)
+			continue
+		}
+		cacher.stats.Evictions++
+	}
+}
+
+// each calls the function for every decodable entry in the cache.
+func (cacher *Cacher) each(f func(key string, e entry)) {
+	iter := cacher.ttlCache.Iterator()
+	defer iter.Close()
+	for iter.Next() {
+		key, err := iter.Key()
+		if err != nil {
+			cacher.logger.Warnf("[cacher] cannot read key from TTL cache: %v", err)
+			continue
+		}
+		var e entry
+		if err := iter.Value(&e); err != nil {
+			cacher.logger.Warnf("[cacher] cannot read value from TTL cache: %v", err)
+			continue
+		}
+		f(key, e)
+	}
+}
+
+// list returns the entries for the given method, or all entries if the method
+// is empty.
+func (cacher *Cacher) list(method string) []EntryInfo {
+	entries := []EntryInfo{}
+	cacher.each(func(key string, e entry) {
+		if method == "" || e.Method == method {
+			entries = append(entries, e.info(key, false))
+		}
+	})
+	return entries
+}
+
+// inspect returns the entry with the given key, or nil if it is not cached.
+func (cacher *Cacher) inspect(key string) *EntryInfo {
+	var e entry
+	if err := cacher.ttlCache.Get(key, &e); err != nil {
+		return nil
+	}
+	info := e.info(key, true)
+	return &info
+}
+
+// invalidate deletes all entries matching the given method and request hash,
+// and returns how many were deleted.
+func (cacher *Cacher) invalidate(method, hash string) int {
+	keys := []string{}
+	cacher.each(func(key string, e entry) {
+		if (method == "" || e.Method == method) && strings.HasPrefix(key, hash) {
+			keys = append(keys, key)
+		}
+	})
+
+	n := 0
+	for _, key := range keys {
+		if err := cacher.remove(key); err != nil {
+			cacher.logger.Warnf("[cacher] cannot delete invalidated response from TTL cache: %v", err)
+			continue
+		}
+		n++
 	}
+	cacher.stats.Invalidations += uint64(n)
+	return n
+}
+
+// statistics returns the current statistics of the cache.
+func (cacher *Cacher) statistics() Stats {
+	stats := cacher.stats
+	cacher.each(func(string, entry) {
+		stats.Entries++
+	})
+
+	cacher.tableMu.Lock()
+	bytes, evictions := cacher.lru.usage()
+	cacher.tableMu.Unlock()
 
-	return jsonrpc.Response{}, false
+	stats.Bytes = bytes
+	stats.Evictions += evictions
+	return stats
 }
 
-func (cacher *Cacher) dispatch(id [32]byte, msg http.RequestWithResponder) {
+// dispatch forwards the request to the `Dispatcher` and caches its response. A
+// detached request is not bound to the context of the client, and is instead
+// bounded by the dispatch timeout.
+func (cacher *Cacher) dispatch(id ID, msg http.RequestWithResponder, detached bool) {
+	key := id.String() + msg.Query.Get("id")
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if detached {
+		ctx, cancel = context.WithTimeout(context.Background(), cacher.options.DispatchTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(msg.Context)
+	}
 	responder := make(chan jsonrpc.Response, 1)
-	cacher.dispatcher.Send(http.RequestWithResponder{
-		Context:   msg.Context,
+	if ok := cacher.dispatcher.Send(http.RequestWithResponder{
+		Context:   ctx,
 		ID:        msg.ID,
 		Method:    msg.Method,
 		Params:    msg.Params,
 		Responder: responder,
 		Query:     msg.Query,
-	})
+	}); !ok {
+		cacher.logger.Errorf("[cacher] cannot send message to dispatcher: too much back pressure")
+		jsonErr := jsonrpc.NewError(jsonrpc.ErrorCodeInternal, "too much back pressure", nil)
+		response := jsonrpc.NewResponse(msg.ID, nil, &jsonErr)
+		cancel()
+		cacher.complete(key, response)
+		msg.Responder <- response
+		return
+	}
 
 	go func() {
 		response := <-responder
-		// QueryTx has an intermediary state where it has not yet been executed
-		// don't cache if we don't have output
-		skipCache := func() bool {
-			if msg.Method == jsonrpc.MethodQueryTx && response.Error == nil {
-				raw, err := json.Marshal(response.Result)
-				// no need to handle errors here as it will be handled by the resolver
-				if err != nil {
-					cacher.logger.Warnf("failed to marshal queryTx response: %v", err)
-					return true
-				}
-				var tx jsonrpc.ResponseQueryTx
-				err = json.Unmarshal(raw, &tx)
-				if err != nil {
-					cacher.logger.Warnf("failed to unmarshal queryTx response: %v", err)
-					return true
-				}
-
-				if !tx.Tx.Selector.IsCrossChain() {
-					return false
-				}
-
-				if tx.Tx.Output.String() == pack.NewTyped().String() {
-					return true
-				}
-
-				var output engine.LockMintBurnReleaseOutput
-				err = pack.Decode(&output, tx.Tx.Output)
-				if err != nil {
-					cacher.logger.Warnf("failed to decode tx output: %v", err)
-					return false
-				}
-				if output.Revert.Equal("") {
-					v1TxOutput := v1.TxOutputFromV2QueryTxOutput(output)
-					tx.Tx.Output = v1TxOutput
-					response = jsonrpc.NewResponse(msg.ID, tx, nil)
-				}
+		cancel()
+
+		now := time.Now()
+		expiry := now.Add(cacher.options.ttl(msg.Method))
+		cache := true
+		switch {
+		case isNotFound(msg.Method, response):
+			expiry = now.Add(cacher.options.NotFoundTTL)
+			cache = cacher.options.NotFoundTTL > 0
+		case response.Error != nil:
+			// Other errors are usually transient, so the next request should
+			// be given the chance to reach the Darknodes.
+			cache = false
+		case msg.Method == jsonrpc.MethodQueryTx && response.Error == nil:
+			var final bool
+			response, final, cache = cacher.handleQueryTx(msg.ID, response)
+			if final && cacher.options.CacheFinalTxs {
+				expiry = time.Time{}
 			}
-			return false
 		}
-		if !skipCache() {
-			cacher.insert(id, msg.Query.Get("id"), response)
+		staleUntil := expiry
+		if !expiry.IsZero() && response.Error == nil && cacher.options.ServeStale[msg.Method] {
+			staleUntil = expiry.Add(cacher.options.MaxStaleness)
 		}
+		if cache {
+			cacher.insert(id, msg, response, expiry, staleUntil)
+		}
+		cacher.complete(key, response)
 		msg.Responder <- response
 	}()
 }
+
+// handleQueryTx strips empty revert messages from executed transactions. It
+// returns the updated response, whether the transaction has reached a final
+// state, and whether the response should be cached. QueryTx has an
+// intermediary state where it has not yet been executed, and we do not cache
+// the response if we do not have an output.
+func (cacher *Cacher) handleQueryTx(id interface{}, response jsonrpc.Response) (jsonrpc.Response, bool, bool) {
+	raw, err := json.Marshal(response.Result)
+	// no need to handle errors here as it will be handled by the resolver
+	if err != nil {
+		cacher.logger.Warnf("failed to marshal queryTx response: %v", err)
+		return response, false, false
+	}
+	var resp jsonrpc.ResponseQueryTx
+	err = json.Unmarshal(raw, &resp)
+	if err != nil {
+		cacher.logger.Warnf("failed to unmarshal queryTx response: %v", err)
+		return response, false, false
+	}
+	final := resp.TxStatus == tx.StatusDone
+
+	if !resp.Tx.Selector.IsCrossChain() {
+		return response, final, true
+	}
+
+	if resp.Tx.Output.String() == pack.NewTyped().String() {
+		return response, false, false
+	}
+
+	var output engine.LockMintBurnReleaseOutput
+	err = pack.Decode(&output, resp.Tx.Output)
+	if err != nil {
+		cacher.logger.Warnf("failed to decode tx output: %v", err)
+		return response, final, true
+	}
+	if output.Revert.Equal("") {
+		v1TxOutput := v1.TxOutputFromV2QueryTxOutput(output)
+		resp.Tx.Output = v1TxOutput
+		response = jsonrpc.NewResponse(id, resp, nil)
+	}
+	return response, final, true
+}
+
+// isNotFound returns whether the response is an error indicating the requested
+// tx could not be found. The Darknodes report unknown txs as invalid params, so
+// only invalid params errors of tx lookups are considered.
+func isNotFound(method string, response jsonrpc.Response) bool {
+	if method != jsonrpc.MethodQueryTx || response.Error == nil {
+		return false
+	}
+	return response.Error.Code == jsonrpc.ErrorCodeInvalidParams && strings.Contains(strings.ToLower(response.Error.Message), "not found")
+}
@@ -25,9 +25,9 @@ import (
 )
 
 var _ = Describe("Cacher", func() {
-	init := func(ctx context.Context, interval time.Duration) (phi.Sender, <-chan phi.Message) {
+	initWithOptions := func(ctx context.Context, options Options) (phi.Sender, <-chan phi.Message) {
 		inspector, messages := testutils.NewInspector(10)
-		ttl := kv.NewTTLCache(ctx, kv.NewMemDB(kv.JSONCodec), "cacher", interval)
+		table := kv.NewTable(kv.NewMemDB(kv.JSONCodec), "cacher")
 
 		sqlDB, err := sql.Open("sqlite3", "./test.db")
 		Expect(err).NotTo(HaveOccurred())
@@ -35,13 +35,59 @@ var _ = Describe("Cacher", func() {
 		database := db.New(sqlDB, 100)
 		Expect(database.Init()).Should(Succeed())
 
-		cacher := New(inspector, logrus.New(), ttl, phi.Options{Cap: 10}, database)
+		cacher := New(inspector, logrus.New(), table, options, phi.Options{Cap: 10}, database)
 		go inspector.Run(ctx)
 		go cacher.Run(ctx)
 
 		return cacher, messages
 	}
 
+	init := func(ctx context.Context, interval time.Duration) (phi.Sender, <-chan phi.Message) {
+		return initWithOptions(ctx, DefaultOptions().WithTTL(interval).WithMethodTTLs(nil))
+	}
+
+	// sendAndRespond sends a request to the cacher, expects it to be forwarded
+	// and responds to it with the given response.
+	sendAndRespond := func(ctx context.Context, cacher phi.Sender, messages <-chan phi.Message, method string, response jsonrpc.Response) {
+		id, params := testutils.ValidRequest(method)
+		request := http.NewRequestWithResponder(ctx, id, method, params, url.Values{})
+		Expect(cacher.Send(request)).Should(BeTrue())
+
+		var message phi.Message
+		Eventually(messages).Should(Receive(&message))
+		req, ok := message.(http.RequestWithResponder)
+		Expect(ok).To(BeTrue())
+		req.Responder <- response
+		Eventually(request.Responder).Should(Receive())
+	}
+
+	// successResponse constructs a response without an error, as only these
+	// are cached for the TTL of their method.
+	successResponse := func(id interface{}) jsonrpc.Response {
+		return jsonrpc.NewResponse(id, map[string]string{"block": "1"}, nil)
+	}
+
+	// isCached sends a request to the cacher and returns whether it was
+	// answered without being forwarded.
+	isCached := func(ctx context.Context, cacher phi.Sender, messages <-chan phi.Message, method string) bool {
+		id, params := testutils.ValidRequest(method)
+		request := http.NewRequestWithResponder(ctx, id, method, params, url.Values{})
+		Expect(cacher.Send(request)).Should(BeTrue())
+
+		select {
+		case <-request.Responder:
+			return true
+		case message := <-messages:
+			req, ok := message.(http.RequestWithResponder)
+			Expect(ok).To(BeTrue())
+			req.Responder <- testutils.ErrorResponse(req.ID)
+			return false
+		case <-time.After(time.Second):
+			Fail("request was neither answered nor forwarded")
+			return false
+		}
+	}
+
 	cleanup := func() {
 		Expect(os.Remove("./test.db")).Should(BeNil())
 	}
@@ -133,13 +179,14 @@ var _ = Describe("Cacher", func() {
 			defer cleanup()
 
 			for method := range jsonrpc.RPCs {
-				// Ignore these methods.
+				// Ignore these methods. Tx lookups are rewritten before they
+				// are cached, and are covered by their own tests.
 				switch method {
-				case jsonrpc.MethodSubmitTx:
+				case jsonrpc.MethodSubmitTx, jsonrpc.MethodQueryTx:
 					continue
 				}
 
-				// Send the first request and respond with an error
+				// Send the first request and respond with a result
 				id, params := testutils.ValidRequest(method)
 				request := http.NewRequestWithResponder(ctx, id, method, params, url.Values{})
 				Expect(cacher.Send(request)).Should(BeTrue())
@@ -147,7 +194,7 @@ var _ = Describe("Cacher", func() {
 				Eventually(messages).Should(Receive(&message))
 				req, ok := message.(http.RequestWithResponder)
 				Expect(ok).To(BeTrue())
-				resp := testutils.ErrorResponse(request.ID)
+				resp := successResponse(request.ID)
 				req.Responder <- resp
 
 				// Expect receiving the response from the responder channel
@@ -170,4 +217,319 @@ var _ = Describe("Cacher", func() {
 			}
 		})
 	})
+
+	Context("when caching responses with a per-method TTL", func() {
+		It("should expire the response after the TTL of its method", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			options := DefaultOptions().
+				WithTTL(time.Minute).
+				WithMethodTTLs(map[string]time.Duration{jsonrpc.MethodQueryBlock: 100 * time.Millisecond})
+			cacher, messages := initWithOptions(ctx, options)
+			defer cleanup()
+
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryBlock, successResponse(1))
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryBlocks, successResponse(1))
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryBlock)).To(BeTrue())
+
+			time.Sleep(200 * time.Millisecond)
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryBlock)).To(BeFalse())
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryBlocks)).To(BeTrue())
+		})
+	})
+
+	Context("when receiving a not found response", func() {
+		It("should only cache it for a short window", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			options := DefaultOptions().
+				WithTTL(time.Minute).
+				WithNotFoundTTL(100 * time.Millisecond)
+			cacher, messages := initWithOptions(ctx, options)
+			defer cleanup()
+
+			jsonErr := jsonrpc.NewError(jsonrpc.ErrorCodeInvalidParams, "tx not found", nil)
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryTx, jsonrpc.NewResponse(1, nil, &jsonErr))
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryTx)).To(BeTrue())
+
+			time.Sleep(200 * time.Millisecond)
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryTx)).To(BeFalse())
+		})
+
+		It("should not cache it if negative caching is disabled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cacher, messages := initWithOptions(ctx, DefaultOptions().WithNotFoundTTL(0))
+			defer cleanup()
+
+			jsonErr := jsonrpc.NewError(jsonrpc.ErrorCodeInvalidParams, "tx not found", nil)
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryTx, jsonrpc.NewResponse(1, nil, &jsonErr))
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryTx)).To(BeFalse())
+		})
+
+		It("should only apply to tx lookups", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			options := DefaultOptions().
+				WithTTL(time.Minute).
+				WithNotFoundTTL(100 * time.Millisecond)
+			cacher, messages := initWithOptions(ctx, options)
+			defer cleanup()
+
+			jsonErr := jsonrpc.NewError(jsonrpc.ErrorCodeInvalidParams, "block not found", nil)
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryBlock, jsonrpc.NewResponse(1, nil, &jsonErr))
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryBlock)).To(BeFalse())
+		})
+
+		It("should not apply to internal errors", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			options := DefaultOptions().
+				WithTTL(time.Minute).
+				WithNotFoundTTL(100 * time.Millisecond)
+			cacher, messages := initWithOptions(ctx, options)
+			defer cleanup()
+
+			jsonErr := jsonrpc.NewError(jsonrpc.ErrorCodeInternal, "peer not found", nil)
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryTx, jsonrpc.NewResponse(1, nil, &jsonErr))
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryTx)).To(BeFalse())
+		})
+	})
+
+	Context("when receiving an error response", func() {
+		It("should forward the next request", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cacher, messages := initWithOptions(ctx, DefaultOptions())
+			defer cleanup()
+
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryConfig, testutils.ErrorResponse(1))
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryConfig)).To(BeFalse())
+		})
+	})
+
+	Context("when receiving an executed queryTx response", func() {
+		It("should cache it indefinitely", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cacher, messages := initWithOptions(ctx, DefaultOptions().WithTTL(100*time.Millisecond))
+			defer cleanup()
+
+			queryTx := testutils.MockQueryTxResponse()
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryTx, jsonrpc.NewResponse(1, queryTx, nil))
+
+			time.Sleep(200 * time.Millisecond)
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryTx)).To(BeTrue())
+		})
+	})
+
+	Context("when receiving identical requests before the first has a response", func() {
+		It("should only dispatch one request and respond to all of them", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cacher, messages := init(ctx, time.Minute)
+			defer cleanup()
+
+			method := jsonrpc.MethodQueryBlockState
+			requests := make([]http.RequestWithResponder, 10)
+			for i := range requests {
+				_, params := testutils.ValidRequest(method)
+				requests[i] = http.NewRequestWithResponder(ctx, i, method, params, url.Values{})
+				Expect(cacher.Send(requests[i])).Should(BeTrue())
+			}
+
+			var message phi.Message
+			Eventually(messages).Should(Receive(&message))
+			req, ok := message.(http.RequestWithResponder)
+			Expect(ok).To(BeTrue())
+			Consistently(messages).ShouldNot(Receive())
+			req.Responder <- testutils.ErrorResponse(req.ID)
+
+			for i := range requests {
+				var response jsonrpc.Response
+				Eventually(requests[i].Responder).Should(Receive(&response))
+				Expect(response.ID).To(Equal(i))
+				Expect(response.Error).NotTo(BeNil())
+			}
+		})
+
+		It("should not cancel the request when the first client disconnects", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cacher, messages := init(ctx, time.Minute)
+			defer cleanup()
+
+			method := jsonrpc.MethodQueryBlockState
+			_, params := testutils.ValidRequest(method)
+			firstCtx, firstCancel := context.WithCancel(ctx)
+			first := http.NewRequestWithResponder(firstCtx, 0, method, params, url.Values{})
+			Expect(cacher.Send(first)).Should(BeTrue())
+			second := http.NewRequestWithResponder(ctx, 1, method, params, url.Values{})
+			Expect(cacher.Send(second)).Should(BeTrue())
+
+			var message phi.Message
+			Eventually(messages).Should(Receive(&message))
+			req, ok := message.(http.RequestWithResponder)
+			Expect(ok).To(BeTrue())
+			firstCancel()
+			Consistently(req.Context.Done()).ShouldNot(BeClosed())
+			_, ok = req.Context.Deadline()
+			Expect(ok).To(BeTrue())
+
+			req.Responder <- successResponse(req.ID)
+			var response jsonrpc.Response
+			Eventually(second.Responder).Should(Receive(&response))
+			Expect(response.Error).To(BeNil())
+		})
+	})
+
+	Context("when receiving a request that has a stale response in the cache", func() {
+		It("should return the stale response and refresh it in the background", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			options := DefaultOptions().
+				WithMethodTTLs(map[string]time.Duration{jsonrpc.MethodQueryBlockState: 100 * time.Millisecond}).
+				WithMaxStaleness(time.Minute)
+			cacher, messages := initWithOptions(ctx, options)
+			defer cleanup()
+
+			method := jsonrpc.MethodQueryBlockState
+			sendAndRespond(ctx, cacher, messages, method, jsonrpc.NewResponse(1, map[string]string{"block": "1"}, nil))
+			time.Sleep(200 * time.Millisecond)
+
+			// The stale response should be returned immediately.
+			id, params := testutils.ValidRequest(method)
+			request := http.NewRequestWithResponder(ctx, id, method, params, url.Values{})
+			Expect(cacher.Send(request)).Should(BeTrue())
+			var response jsonrpc.Response
+			Eventually(request.Responder).Should(Receive(&response))
+			Expect(response.Result).To(Equal(map[string]interface{}{"block": "1"}))
+
+			// The cacher should refresh the response in the background.
+			var message phi.Message
+			Eventually(messages).Should(Receive(&message))
+			req, ok := message.(http.RequestWithResponder)
+			Expect(ok).To(BeTrue())
+			req.Responder <- jsonrpc.NewResponse(req.ID, map[string]string{"block": "2"}, nil)
+
+			Eventually(func() interface{} {
+				request := http.NewRequestWithResponder(ctx, id, method, params, url.Values{})
+				Expect(cacher.Send(request)).Should(BeTrue())
+				var response jsonrpc.Response
+				Eventually(request.Responder).Should(Receive(&response))
+				return response.Result
+			}).Should(Equal(map[string]interface{}{"block": "2"}))
+		})
+	})
+
+	Context("when prefetching requests", func() {
+		It("should periodically send refresh requests to the cacher", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			inspector, messages := testutils.NewInspector(10)
+			go inspector.Run(ctx)
+
+			request, err := NewPrefetchRequest(jsonrpc.MethodQueryFees)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(request.Method).To(Equal(jsonrpc.MethodQueryBlockState))
+			prefetcher := NewPrefetcher(logrus.New(), inspector, []PrefetchRequest{request}, 50*time.Millisecond)
+			go prefetcher.Run(ctx)
+
+			for i := 0; i < 2; i++ {
+				var message phi.Message
+				Eventually(messages).Should(Receive(&message))
+				refresh, ok := message.(RefreshRequest)
+				Expect(ok).To(BeTrue())
+				Expect(refresh.Request.Method).To(Equal(jsonrpc.MethodQueryBlockState))
+			}
+		})
+
+		It("should reject methods that require params", func() {
+			_, err := NewPrefetchRequest(jsonrpc.MethodQueryTx)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when inspecting and invalidating the cache", func() {
+		It("should list, inspect and invalidate entries and report statistics", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cacher, messages := init(ctx, time.Minute)
+			defer cleanup()
+
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryBlock, successResponse(1))
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryConfig, successResponse(1))
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryBlock)).To(BeTrue())
+
+			// List the entries for a single method.
+			listResponder := make(chan []EntryInfo, 1)
+			Expect(cacher.Send(ListRequest{Method: jsonrpc.MethodQueryBlock, Responder: listResponder})).Should(BeTrue())
+			var entries []EntryInfo
+			Eventually(listResponder).Should(Receive(&entries))
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Method).To(Equal(jsonrpc.MethodQueryBlock))
+			Expect(entries[0].Response).To(BeNil())
+
+			// Inspect the entry.
+			inspectResponder := make(chan *EntryInfo, 1)
+			Expect(cacher.Send(InspectRequest{Key: entries[0].Key, Responder: inspectResponder})).Should(BeTrue())
+			var entry *EntryInfo
+			Eventually(inspectResponder).Should(Receive(&entry))
+			Expect(entry).ToNot(BeNil())
+			Expect(entry.Response).ToNot(BeNil())
+
+			// Invalidate the entry by its hash.
+			invalidateResponder := make(chan int, 1)
+			Expect(cacher.Send(InvalidateRequest{Hash: entries[0].Key, Responder: invalidateResponder})).Should(BeTrue())
+			Eventually(invalidateResponder).Should(Receive(Equal(1)))
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryBlock)).To(BeFalse())
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryConfig)).To(BeTrue())
+
+			// Invalidate all entries for a method.
+			Expect(cacher.Send(InvalidateRequest{Method: jsonrpc.MethodQueryConfig, Responder: invalidateResponder})).Should(BeTrue())
+			Eventually(invalidateResponder).Should(Receive(Equal(1)))
+
+			statsResponder := make(chan Stats, 1)
+			Expect(cacher.Send(StatsRequest{Responder: statsResponder})).Should(BeTrue())
+			var stats Stats
+			Eventually(statsResponder).Should(Receive(&stats))
+			Expect(stats.Hits).To(Equal(uint64(2)))
+			Expect(stats.Misses).To(Equal(uint64(3)))
+			Expect(stats.Invalidations).To(Equal(uint64(2)))
+		})
+	})
+
+	Context("when the cache is full", func() {
+		It("should evict the least recently used entry", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cacher, messages := initWithOptions(ctx, DefaultOptions().WithTTL(time.Minute).WithMethodTTLs(nil).WithMaxEntries(2))
+			defer cleanup()
+
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryBlock, successResponse(1))
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryBlocks, successResponse(1))
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryBlock)).To(BeTrue())
+
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryConfig, successResponse(1))
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryBlock)).To(BeTrue())
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryConfig)).To(BeTrue())
+
+			statsResponder := make(chan Stats, 1)
+			Expect(cacher.Send(StatsRequest{Responder: statsResponder})).Should(BeTrue())
+			var stats Stats
+			Eventually(statsResponder).Should(Receive(&stats))
+			Expect(stats.Entries).To(Equal(2))
+			Expect(stats.Evictions).To(Equal(uint64(1)))
+		})
+
+		It("should not cache responses larger than the byte budget", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cacher, messages := initWithOptions(ctx, DefaultOptions().WithTTL(time.Minute).WithMethodTTLs(nil).WithMaxBytes(10))
+			defer cleanup()
+
+			sendAndRespond(ctx, cacher, messages, jsonrpc.MethodQueryBlock, successResponse(1))
+			Expect(isCached(ctx, cacher, messages, jsonrpc.MethodQueryBlock)).To(BeFalse())
+		})
+	})
 })
@@ -0,0 +1,86 @@
+package cacher
+
+import (
+	"container/list"
+)
+
+// lru tracks the recency and size of cached entries, and decides which entries
+// must be evicted to stay within a maximum number of entries and bytes. It
+// only tracks keys; deleting the evicted entries from the cache is the
+// responsibility of the caller. A zero maximum means there is no bound. It is
+// not safe for concurrent use.
+type lru struct {
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	evictions  uint64
+	order      *list.List
+	elems      map[string]*list.Element
+}
+
+type lruItem struct {
+	key  string
+	size int
+}
+
+func newLRU(maxEntries, maxBytes int) *lru {
+	return &lru{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		elems:      map[string]*list.Element{},
+	}
+}
+
+// add marks the key as the most recently used with the given size, and returns
+// the keys which must be evicted to stay within bounds. If the entry alone
+// exceeds the byte budget, its own key is returned.
+func (l *lru) add(key string, size int) []string {
+	if l.maxBytes > 0 && size > l.maxBytes {
+		l.remove(key)
+		return []string{key}
+	}
+
+	if elem, ok := l.elems[key]; ok {
+		item := elem.Value.(*lruItem)
+		l.bytes += size - item.size
+		item.size = size
+		l.order.MoveToFront(elem)
+	} else {
+		l.elems[key] = l.order.PushFront(&lruItem{key: key, size: size})
+		l.bytes += size
+	}
+
+	evicted := []string{}
+	for (l.maxEntries > 0 && l.order.Len() > l.maxEntries) || (l.maxBytes > 0 && l.bytes > l.maxBytes) {
+		item := l.order.Back().Value.(*lruItem)
+		l.remove(item.key)
+		l.evictions++
+		evicted = append(evicted, item.key)
+	}
+	return evicted
+}
+
+// touch marks the key as the most recently used.
+func (l *lru) touch(key string) {
+	if elem, ok := l.elems[key]; ok {
+		l.order.MoveToFront(elem)
+	}
+}
+
+// remove stops tracking the key.
+func (l *lru) remove(key string) {
+	elem, ok := l.elems[key]
+	if !ok {
+		return
+	}
+	l.bytes -= elem.Value.(*lruItem).size
+	l.order.Remove(elem)
+	delete(l.elems, key)
+}
+
+// usage returns the number of bytes tracked and the number of entries evicted
+// to stay within bounds.
+func (l *lru) usage() (int, uint64) {
+	return l.bytes, l.evictions
+}
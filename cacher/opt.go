@@ -0,0 +1,180 @@
+package cacher
+
+import (
+	"time"
+
+	"github.com/renproject/darknode/jsonrpc"
+)
+
+// Enumerate default options.
+var (
+	DefaultTTL        = 3 * time.Second
+	DefaultMethodTTLs = map[string]time.Duration{
+		jsonrpc.MethodQueryConfig:     5 * time.Minute,
+		jsonrpc.MethodQueryBlockState: 5 * time.Second,
+	}
+	DefaultNotFoundTTL   = time.Second
+	DefaultCacheFinalTxs = true
+	DefaultPruneInterval = time.Minute
+	DefaultServeStale    = map[string]bool{
+		jsonrpc.MethodQueryBlockState: true,
+	}
+	DefaultMaxStaleness     = 30 * time.Second
+	DefaultPrefetchInterval = 2 * time.Second
+	DefaultMaxEntries       = 100000
+	DefaultMaxBytes         = 256 * 1024 * 1024
+	DefaultDispatchTimeout  = 30 * time.Second
+)
+
+// Options to configure the precise behaviour of the cacher.
+type Options struct {
+	// TTL is the time-to-live for responses to methods which do not have an
+	// entry in the method TTL table.
+	TTL time.Duration
+	// MethodTTLs overrides the time-to-live for responses to specific methods.
+	MethodTTLs map[string]time.Duration
+	// NotFoundTTL is the time-to-live for "not found" error responses. These
+	// are cached for a short window so that repeated polling for a missing
+	// resource does not reach the Darknodes. A zero value disables negative
+	// caching. Other error responses are never cached.
+	NotFoundTTL time.Duration
+	// CacheFinalTxs will cache executed `ren_queryTx` responses indefinitely,
+	// as their contents will never change.
+	CacheFinalTxs bool
+	// PruneInterval is how often expired entries are removed from the cache.
+	PruneInterval time.Duration
+	// ServeStale is the set of methods for which expired responses are served
+	// while they are refreshed in the background. These are the methods sent
+	// to the cacher, so queries which the resolver answers from the block
+	// state are covered by `ren_queryBlockState`.
+	ServeStale map[string]bool
+	// MaxStaleness is how long after expiring a response can still be served.
+	MaxStaleness time.Duration
+	// Prefetch is the set of requests that are periodically refreshed in the
+	// background so that they are always warm in the cache.
+	Prefetch []PrefetchRequest
+	// PrefetchInterval is how often the prefetch requests are refreshed.
+	PrefetchInterval time.Duration
+	// MaxEntries is the maximum number of responses in the cache. A zero value
+	// means the number of entries is unbounded.
+	MaxEntries int
+	// MaxBytes is the maximum total size of the marshalled responses in the
+	// cache. A zero value means the size is unbounded.
+	MaxBytes int
+	// DispatchTimeout bounds requests which are shared by several clients,
+	// such as coalesced misses and background refreshes, as they are not
+	// bound to the context of any single client.
+	DispatchTimeout time.Duration
+}
+
+// DefaultOptions returns new options with default configurations that should
+// work for the majority of use cases.
+func DefaultOptions() Options {
+	methodTTLs := make(map[string]time.Duration, len(DefaultMethodTTLs))
+	for method, ttl := range DefaultMethodTTLs {
+		methodTTLs[method] = ttl
+	}
+	serveStale := make(map[string]bool, len(DefaultServeStale))
+	for method, ok := range DefaultServeStale {
+		serveStale[method] = ok
+	}
+	return Options{
+		TTL:              DefaultTTL,
+		MethodTTLs:       methodTTLs,
+		NotFoundTTL:      DefaultNotFoundTTL,
+		CacheFinalTxs:    DefaultCacheFinalTxs,
+		PruneInterval:    DefaultPruneInterval,
+		ServeStale:       serveStale,
+		MaxStaleness:     DefaultMaxStaleness,
+		Prefetch:         []PrefetchRequest{},
+		PrefetchInterval: DefaultPrefetchInterval,
+		MaxEntries:       DefaultMaxEntries,
+		MaxBytes:         DefaultMaxBytes,
+		DispatchTimeout:  DefaultDispatchTimeout,
+	}
+}
+
+// WithTTL returns new options with the given default time-to-live.
+func (opts Options) WithTTL(ttl time.Duration) Options {
+	opts.TTL = ttl
+	return opts
+}
+
+// WithMethodTTLs returns new options with the given per-method time-to-live
+// table. Methods not present in the table use the default time-to-live.
+func (opts Options) WithMethodTTLs(methodTTLs map[string]time.Duration) Options {
+	opts.MethodTTLs = methodTTLs
+	return opts
+}
+
+// WithNotFoundTTL returns new options with the given time-to-live for "not
+// found" responses.
+func (opts Options) WithNotFoundTTL(notFoundTTL time.Duration) Options {
+	opts.NotFoundTTL = notFoundTTL
+	return opts
+}
+
+// WithCacheFinalTxs returns new options which enable or disable caching
+// executed transactions indefinitely.
+func (opts Options) WithCacheFinalTxs(cacheFinalTxs bool) Options {
+	opts.CacheFinalTxs = cacheFinalTxs
+	return opts
+}
+
+// WithPruneInterval returns new options with the given prune interval.
+func (opts Options) WithPruneInterval(pruneInterval time.Duration) Options {
+	opts.PruneInterval = pruneInterval
+	return opts
+}
+
+// WithServeStale returns new options with the given set of methods for which
+// stale responses are served while being refreshed.
+func (opts Options) WithServeStale(methods map[string]bool) Options {
+	opts.ServeStale = methods
+	return opts
+}
+
+// WithMaxStaleness returns new options with the given maximum staleness.
+func (opts Options) WithMaxStaleness(maxStaleness time.Duration) Options {
+	opts.MaxStaleness = maxStaleness
+	return opts
+}
+
+// WithPrefetch returns new options with the given requests to keep warm.
+func (opts Options) WithPrefetch(prefetch []PrefetchRequest) Options {
+	opts.Prefetch = prefetch
+	return opts
+}
+
+// WithPrefetchInterval returns new options with the given prefetch interval.
+func (opts Options) WithPrefetchInterval(prefetchInterval time.Duration) Options {
+	opts.PrefetchInterval = prefetchInterval
+	return opts
+}
+
+// WithMaxEntries returns new options with the given maximum number of entries.
+func (opts Options) WithMaxEntries(maxEntries int) Options {
+	opts.MaxEntries = maxEntries
+	return opts
+}
+
+// WithMaxBytes returns new options with the given maximum size in bytes.
+func (opts Options) WithMaxBytes(maxBytes int) Options {
+	opts.MaxBytes = maxBytes
+	return opts
+}
+
+// WithDispatchTimeout returns new options with the given timeout for requests
+// which are not bound to the context of a client.
+func (opts Options) WithDispatchTimeout(dispatchTimeout time.Duration) Options {
+	opts.DispatchTimeout = dispatchTimeout
+	return opts
+}
+
+// ttl returns the time-to-live for a successful response to the given method.
+func (opts Options) ttl(method string) time.Duration {
+	if ttl, ok := opts.MethodTTLs[method]; ok {
+		return ttl
+	}
+	return opts.TTL
+}
@@ -0,0 +1,106 @@
+package cacher
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"time"
+
+	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/lightnode/http"
+	"github.com/renproject/phi"
+	"github.com/sirupsen/logrus"
+)
+
+// RefreshRequest is a message which tells the `Cacher` to refresh the cached
+// response for a request in the background, regardless of whether it has
+// expired.
+type RefreshRequest struct {
+	Request http.RequestWithResponder
+}
+
+// IsMessage implements the `phi.Message` interface.
+func (RefreshRequest) IsMessage() {}
+
+// PrefetchRequest is a request that is kept warm in the cache.
+type PrefetchRequest struct {
+	Method string
+	Params interface{}
+}
+
+// NewPrefetchRequest returns the request that the resolver sends to the
+// `Cacher` when a client calls the given method with empty params, so that the
+// prefetched response is stored under the same key. Only methods which do not
+// require any params are supported.
+func NewPrefetchRequest(method string) (PrefetchRequest, error) {
+	switch method {
+	case jsonrpc.MethodQueryBlockState:
+		return PrefetchRequest{Method: jsonrpc.MethodQueryBlockState, Params: jsonrpc.ParamsQueryBlockState{}}, nil
+	case jsonrpc.MethodQueryFees:
+		return PrefetchRequest{Method: jsonrpc.MethodQueryBlockState, Params: jsonrpc.ParamsQueryFees{}}, nil
+	case jsonrpc.MethodQueryState:
+		return PrefetchRequest{Method: jsonrpc.MethodQueryBlockState, Params: jsonrpc.ParamsQueryState{}}, nil
+	case jsonrpc.MethodQueryShards:
+		return PrefetchRequest{Method: jsonrpc.MethodQueryBlockState, Params: jsonrpc.ParamsQueryShards{}}, nil
+	case jsonrpc.MethodQueryConfig:
+		return PrefetchRequest{Method: jsonrpc.MethodQueryConfig, Params: jsonrpc.ParamsQueryConfig{}}, nil
+	case jsonrpc.MethodQueryNumPeers:
+		return PrefetchRequest{Method: jsonrpc.MethodQueryNumPeers, Params: jsonrpc.ParamsQueryNumPeers{}}, nil
+	case jsonrpc.MethodQueryPeers:
+		return PrefetchRequest{Method: jsonrpc.MethodQueryPeers, Params: jsonrpc.ParamsQueryPeers{}}, nil
+	case jsonrpc.MethodQueryStat:
+		return PrefetchRequest{Method: jsonrpc.MethodQueryStat, Params: jsonrpc.ParamsQueryStat{}}, nil
+	default:
+		return PrefetchRequest{}, fmt.Errorf("cannot prefetch method %v", method)
+	}
+}
+
+// A Prefetcher periodically asks the `Cacher` to refresh a set of hot requests
+// so that clients never have to wait for the Darknodes to respond to them.
+type Prefetcher struct {
+	logger   logrus.FieldLogger
+	cacher   phi.Sender
+	requests []PrefetchRequest
+	interval time.Duration
+}
+
+// NewPrefetcher constructs a new `Prefetcher` which refreshes the given
+// requests at the given interval.
+func NewPrefetcher(logger logrus.FieldLogger, cacher phi.Sender, requests []PrefetchRequest, interval time.Duration) Prefetcher {
+	return Prefetcher{
+		logger:   logger,
+		cacher:   cacher,
+		requests: requests,
+		interval: interval,
+	}
+}
+
+// Run starts the `Prefetcher` until the context is canceled. It returns
+// immediately if there are no requests to prefetch.
+func (prefetcher Prefetcher) Run(ctx context.Context) {
+	if len(prefetcher.requests) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(prefetcher.interval)
+	defer ticker.Stop()
+
+	for {
+		prefetcher.prefetch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (prefetcher Prefetcher) prefetch(ctx context.Context) {
+	for _, request := range prefetcher.requests {
+		req := http.NewRequestWithResponder(ctx, rand.Int31(), request.Method, request.Params, url.Values{})
+		if ok := prefetcher.cacher.Send(RefreshRequest{Request: req}); !ok {
+			prefetcher.logger.Warnf("[prefetcher] cannot send %v request to cacher: too much back pressure", request.Method)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/renproject/darknode/tx"
+	"github.com/renproject/lightnode"
+	"github.com/renproject/lightnode/watcher"
+	"github.com/renproject/pack"
+	"github.com/sirupsen/logrus"
+)
+
+// backfillArgs are the arguments of the backfill subcommand.
+type backfillArgs struct {
+	selector tx.Selector
+	from     uint64
+	to       uint64
+	dryRun   bool
+}
+
+// parseBackfillArgs parses the arguments of the backfill subcommand, for
+// example `backfill --selector BTC/fromEthereum --from 100 --to 200 --dry-run`.
+func parseBackfillArgs(args []string) (backfillArgs, error) {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	selector := flags.String("selector", "", "selector of the burns to replay, e.g. BTC/fromEthereum")
+	from := flags.Uint64("from", 0, "first block (or burn index for Solana) to replay")
+	to := flags.Uint64("to", 0, "last block (or burn index for Solana) to replay")
+	dryRun := flags.Bool("dry-run", false, "print the burns instead of submitting them")
+	if err := flags.Parse(args); err != nil {
+		return backfillArgs{}, err
+	}
+
+	if *selector == "" {
+		return backfillArgs{}, fmt.Errorf("missing selector")
+	}
+	parsed := tx.Selector(*selector)
+	if !parsed.IsBurn() || !parsed.IsRelease() {
+		return backfillArgs{}, fmt.Errorf("invalid selector %v: expected a burn and release selector", parsed)
+	}
+	if *to < *from {
+		return backfillArgs{}, fmt.Errorf("invalid range: from=%v is after to=%v", *from, *to)
+	}
+	return backfillArgs{
+		selector: parsed,
+		from:     *from,
+		to:       *to,
+		dryRun:   *dryRun,
+	}, nil
+}
+
+// runBackfill replays the burns in the given range and prints the result of
+// each burn.
+func runBackfill(ctx context.Context, logger logrus.FieldLogger, node lightnode.Lightnode, args backfillArgs, out io.Writer) {
+	results, err := node.Backfill(ctx, args.selector, args.from, args.to, args.dryRun)
+	for _, result := range results {
+		printBackfillResult(out, result)
+	}
+	if err != nil {
+		logger.Fatalf("backfill of %v from=%v to=%v stopped: %v", args.selector, args.from, args.to, err)
+	}
+	logger.Infof("backfilled %v burns of %v from=%v to=%v", len(results), args.selector, args.from, args.to)
+}
+
+func printBackfillResult(out io.Writer, result watcher.BackfillResult) {
+	burn := result.Burn
+	line := fmt.Sprintf("block=%v nonce=%v txid=%v amount=%v status=%v", burn.BlockNumber, pack.NewU256(burn.Nonce), burn.Txid, burn.Amount, result.Status)
+	if result.Params.Tx.Selector != "" {
+		line += fmt.Sprintf(" hash=%v", result.Params.Tx.Hash)
+	}
+	if result.Error != nil {
+		line += fmt.Sprintf(" error=%q", result.Error.Error())
+	}
+	fmt.Fprintln(out, line)
+}
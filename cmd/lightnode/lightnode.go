@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/url"
@@ -21,15 +20,14 @@ import (
 	"github.com/go-redis/redis/v7"
 	"github.com/renproject/aw/wire"
 	"github.com/renproject/darknode/binding"
-	"github.com/renproject/darknode/engine"
-	"github.com/renproject/darknode/jsonrpc"
 	"github.com/renproject/darknode/tx"
 	"github.com/renproject/id"
 	"github.com/renproject/lightnode"
+	"github.com/renproject/lightnode/config"
+	"github.com/renproject/lightnode/dispatcher"
 	"github.com/renproject/lightnode/http"
 	"github.com/renproject/multichain"
 	"github.com/renproject/pack"
-	"github.com/renproject/surge"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
 )
@@ -38,6 +36,18 @@ func main() {
 	// Seed random number generator.
 	rand.Seed(time.Now().UnixNano())
 
+	// Parse the arguments of the backfill subcommand first, so that invalid
+	// arguments are reported before connecting to anything.
+	var backfill *backfillArgs
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		args, err := parseBackfillArgs(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "usage: lightnode backfill --selector BTC/fromEthereum --from N --to M [--dry-run]: %v\n", err)
+			os.Exit(2)
+		}
+		backfill = &args
+	}
+
 	// Parse Lightnode options from environment variables.
 	options := parseOptions()
 
@@ -58,166 +68,47 @@ func main() {
 
 	ctx := context.Background()
 
-	// Fetch and apply the first successfully exposed config from bootstrap nodes
-	conf, err := getConfigFromBootstrap(ctx, logger, options.BootstrapAddrs)
+	// Fetch and apply the config which enough bootstrap nodes agree on
+	urls := http.NewURLResolver(options.DarknodeURLs)
+	darknodeClient := http.NewClientWithOptions(
+		options.DarknodeClient.
+			WithTimeout(time.Minute).
+			WithTLS(urls.TLS()),
+	)
+	conf, agreement, err := config.Fetch(ctx, logger, darknodeClient, urls, options.BootstrapAddrs, options.Chains, options.ConfigThreshold)
 	if err != nil {
-		logger.Fatalf("failed to fetch config from any bootstrap node")
+		logger.Fatalf("failed to fetch config from any bootstrap node: %v", err)
+	}
+	if !agreement.Reached() {
+		// Starting with a config which too few bootstrap nodes agree on is
+		// only allowed if explicitly enabled.
+		if !parseBool("CONFIG_ALLOW_DEGRADED") {
+			logger.Fatalf("bootstrap nodes disagree on config: %v", agreement)
+		}
+		logger.Errorf("starting degraded as bootstrap nodes disagree on config: %v", agreement)
 	}
 
 	options.Whitelist = conf.Whitelist
 
-	// Replace Darknode whitelist with a custom one if it is set.
+	// Replace Darknode whitelist with a custom one if it is set, and keep it
+	// when the config is refreshed.
 	if os.Getenv("WHITELIST") != "" {
 		options = options.WithWhitelist(
 			parseWhitelist("WHITELIST"),
 		)
+		options = options.WithStaticWhitelist(true)
 	}
 
-	for chain, chainOpt := range options.Chains {
-		chainOpt.Confirmations = conf.Confirmations[chain]
-		if conf.MaxConfirmations[chain] != 0 {
-			chainOpt.MaxConfirmations = conf.MaxConfirmations[chain]
-		} else {
-			chainOpt.MaxConfirmations = pack.MaxU64
-		}
-		options.Chains[chain] = chainOpt
-	}
-
-	// Fetch block state from first bootstrap node and use the public key
-	state, err := fetchBlockState(context.Background(), addrToUrl(options.BootstrapAddrs[0], logger), logger, time.Minute)
-	if err != nil {
-		logger.Fatalf("failed to fetch block state from bootstrap node")
-	}
-	pub, err := parsePubkey(state)
-	if err != nil {
-		logger.Fatalf("failed to parse public key from block state")
-	}
-	options = options.WithDistPubKey(&pub)
+	options = options.WithChains(conf.Chains)
+	options = options.WithDistPubKey(conf.DistPubKey)
 
 	// Run Lightnode.
 	node := lightnode.New(options, ctx, logger, sqlDB, client)
-	node.Run(ctx)
-}
-
-func getConfigFromBootstrap(ctx context.Context, logger logrus.FieldLogger, addrs []wire.Address) (jsonrpc.ResponseQueryConfig, error) {
-	for i, addr := range addrs {
-		conf, err := fetchConfig(ctx, addrToUrl(addr, logger), logger, time.Minute)
-		if i == len(addrs)-1 && err != nil {
-			return conf, err
-		}
-
-		if err == nil {
-			return conf, nil
-		}
-	}
-	return jsonrpc.ResponseQueryConfig{}, fmt.Errorf("could not load config from darknodes")
-}
-
-func addrToUrl(addr wire.Address, logger logrus.FieldLogger) string {
-	addrParts := strings.Split(addr.Value, ":")
-	if len(addrParts) != 2 {
-		logger.Errorf("[config] invalid address value=%v", addr.Value)
-		return ""
-	}
-	port, err := strconv.Atoi(addrParts[1])
-	if err != nil {
-		logger.Errorf("[config] invalid port=%v", addr)
-		return ""
-	}
-	return fmt.Sprintf("http://%s:%v", addrParts[0], port+1)
-}
-
-func fetchConfig(ctx context.Context, url string, logger logrus.FieldLogger, timeout time.Duration) (jsonrpc.ResponseQueryConfig, error) {
-	var resp jsonrpc.ResponseQueryConfig
-	params, err := json.Marshal(jsonrpc.ParamsQueryConfig{})
-	if err != nil {
-		logger.Errorf("[config] cannot marshal query config params: %v", err)
-		return resp, err
-	}
-	client := http.NewClient(timeout)
-
-	request := jsonrpc.Request{
-		Version: "2.0",
-		ID:      rand.Int31(),
-		Method:  jsonrpc.MethodQueryConfig,
-		Params:  params,
-	}
-
-	response, err := client.SendRequest(ctx, url, request, nil)
-	if err != nil {
-		logger.Errorf("[config] error calling queryConfig: %v", err)
-		return resp, err
-	}
-
-	raw, err := json.Marshal(response.Result)
-	if err != nil {
-		logger.Errorf("[config] error marshaling queryConfig result: %v", err)
-		return resp, err
-	}
-
-	if err := json.Unmarshal(raw, &resp); err != nil {
-		logger.Warnf("[config] cannot unmarshal queryConfig result from %v: %v", url, err)
-		return resp, err
-	}
-
-	return resp, nil
-}
-
-func fetchBlockState(ctx context.Context, url string, logger logrus.FieldLogger, timeout time.Duration) (jsonrpc.ResponseQueryBlockState, error) {
-	var resp jsonrpc.ResponseQueryBlockState
-	params, err := json.Marshal(jsonrpc.ParamsQueryBlockState{})
-	if err != nil {
-		logger.Errorf("[config] cannot marshal query block state params: %v", err)
-		return resp, err
-	}
-	client := http.NewClient(timeout)
-
-	request := jsonrpc.Request{
-		Version: "2.0",
-		ID:      rand.Int31(),
-		Method:  jsonrpc.MethodQueryBlockState,
-		Params:  params,
-	}
-
-	response, err := client.SendRequest(ctx, url, request, nil)
-	if err != nil {
-		logger.Errorf("[config] error calling queryConfig: %v", err)
-		return resp, err
-	}
-
-	raw, err := json.Marshal(response.Result)
-	if err != nil {
-		logger.Errorf("[config] error marshaling queryConfig result: %v", err)
-		return resp, err
-	}
-
-	if err := json.Unmarshal(raw, &resp); err != nil {
-		logger.Warnf("[config] cannot unmarshal queryConfig result from %v: %v", url, err)
-		return resp, err
-	}
-
-	return resp, nil
-}
-
-func parsePubkey(response jsonrpc.ResponseQueryBlockState) (id.PubKey, error) {
-	systemContract := response.State.Get("System")
-	if systemContract == nil {
-		return id.PubKey{}, fmt.Errorf("system contract is nil")
-	}
-
-	var state engine.SystemState
-	if err := pack.Decode(&state, systemContract); err != nil {
-		return id.PubKey{}, err
+	if backfill != nil {
+		runBackfill(ctx, logger, node, *backfill, os.Stdout)
+		return
 	}
-	if len(state.Shards.Primary) < 1 {
-		return id.PubKey{}, fmt.Errorf("nil primary shard")
-	}
-	shard := state.Shards.Primary[0]
-	var pub id.PubKey
-	if err := surge.FromBinary(&pub, shard.PubKey); err != nil {
-		return id.PubKey{}, err
-	}
-	return pub, nil
+	node.Run(ctx)
 }
 
 func initLogger(name string, network multichain.Network) logrus.FieldLogger {
@@ -288,9 +179,102 @@ func parseOptions() lightnode.Options {
 	if os.Getenv("TTL") != "" {
 		options = options.WithTTL(parseTime("TTL"))
 	}
+	if os.Getenv("CACHE_TTLS") != "" {
+		options = options.WithCacheTTLs(parseDurations("CACHE_TTLS"))
+	}
+	if os.Getenv("CACHE_NOT_FOUND_TTL") != "" {
+		options = options.WithCacheNotFoundTTL(parseTime("CACHE_NOT_FOUND_TTL"))
+	}
+	if os.Getenv("CACHE_FINAL_TXS") != "" {
+		options = options.WithCacheFinalTxs(parseBool("CACHE_FINAL_TXS"))
+	}
+	if os.Getenv("CACHE_SERVE_STALE") != "" {
+		options = options.WithCacheServeStale(parseMethodSet("CACHE_SERVE_STALE"))
+	}
+	if os.Getenv("CACHE_MAX_STALENESS") != "" {
+		options = options.WithCacheMaxStaleness(parseTime("CACHE_MAX_STALENESS"))
+	}
+	if os.Getenv("CACHE_PREFETCH") != "" {
+		options = options.WithCachePrefetch(strings.Split(os.Getenv("CACHE_PREFETCH"), ","))
+	}
+	if os.Getenv("CACHE_PREFETCH_INTERVAL") != "" {
+		options = options.WithCachePrefetchInterval(parseTime("CACHE_PREFETCH_INTERVAL"))
+	}
+	if os.Getenv("CACHE_MAX_ENTRIES") != "" {
+		options = options.WithCacheMaxEntries(parseInt("CACHE_MAX_ENTRIES"))
+	}
+	if os.Getenv("CACHE_MAX_BYTES") != "" {
+		options = options.WithCacheMaxBytes(parseInt("CACHE_MAX_BYTES"))
+	}
+	if os.Getenv("ADMIN_PORT") != "" {
+		options = options.WithAdminPort(os.Getenv("ADMIN_PORT"))
+	}
+	if os.Getenv("ADMIN_TOKEN") != "" {
+		options = options.WithAdminToken(os.Getenv("ADMIN_TOKEN"))
+	}
+	if os.Getenv("DISPATCHER_FAILURE_THRESHOLD") != "" {
+		options = options.WithDispatcherFailureThreshold(parseInt("DISPATCHER_FAILURE_THRESHOLD"))
+	}
+	if os.Getenv("DISPATCHER_BREAKER_COOLDOWN") != "" {
+		options = options.WithDispatcherBreakerCooldown(parseTime("DISPATCHER_BREAKER_COOLDOWN"))
+	}
+	if os.Getenv("DISPATCHER_ROUTES") != "" {
+		options = options.WithDispatcherRoutes(parseRoutes("DISPATCHER_ROUTES", options.DispatcherRoutes))
+	}
+	if os.Getenv("DISPATCHER_NETWORK_SIZE") != "" {
+		options = options.WithDispatcherNetworkSize(parseInt("DISPATCHER_NETWORK_SIZE"))
+	}
+	if os.Getenv("DISPATCHER_BATCH_WINDOW") != "" {
+		options = options.WithDispatcherBatchWindow(parseMilliseconds("DISPATCHER_BATCH_WINDOW"))
+	}
+	if os.Getenv("DISPATCHER_BATCH_SIZE") != "" {
+		options = options.WithDispatcherBatchSize(parseInt("DISPATCHER_BATCH_SIZE"))
+	}
+	if os.Getenv("DARKNODE_URL_SCHEME") != "" || os.Getenv("DARKNODE_PORT_OFFSET") != "" || os.Getenv("DARKNODE_PORTS") != "" || os.Getenv("DARKNODE_URLS") != "" || os.Getenv("DARKNODE_CA_BUNDLE") != "" {
+		options = options.WithDarknodeURLs(parseDarknodeURLs(options.DarknodeURLs))
+	}
+	if os.Getenv("DARKNODE_MAX_IDLE_CONNS") != "" {
+		options = options.WithDarknodeClient(options.DarknodeClient.WithMaxIdleConns(parseInt("DARKNODE_MAX_IDLE_CONNS")))
+	}
+	if os.Getenv("DARKNODE_MAX_IDLE_CONNS_PER_HOST") != "" {
+		options = options.WithDarknodeClient(options.DarknodeClient.WithMaxIdleConnsPerHost(parseInt("DARKNODE_MAX_IDLE_CONNS_PER_HOST")))
+	}
+	if os.Getenv("DARKNODE_IDLE_CONN_TIMEOUT") != "" {
+		options = options.WithDarknodeClient(options.DarknodeClient.WithIdleConnTimeout(parseTime("DARKNODE_IDLE_CONN_TIMEOUT")))
+	}
+	if os.Getenv("DARKNODE_KEEP_ALIVE") != "" {
+		options = options.WithDarknodeClient(options.DarknodeClient.WithKeepAlive(parseTime("DARKNODE_KEEP_ALIVE")))
+	}
+	if os.Getenv("DARKNODE_HTTP2") != "" {
+		options = options.WithDarknodeClient(options.DarknodeClient.WithHTTP2(parseBool("DARKNODE_HTTP2")))
+	}
+	if os.Getenv("DARKNODE_MAX_RESPONSE_SIZE") != "" {
+		options = options.WithDarknodeClient(options.DarknodeClient.WithMaxResponseSize(int64(parseInt("DARKNODE_MAX_RESPONSE_SIZE"))))
+	}
+	if os.Getenv("DARKNODE_GZIP") != "" {
+		options = options.WithDarknodeClient(options.DarknodeClient.WithGzip(parseBool("DARKNODE_GZIP")))
+	}
+	if os.Getenv("DIVERGENCE_ALERT_URL") != "" {
+		options = options.WithDivergenceAlertURL(os.Getenv("DIVERGENCE_ALERT_URL"))
+	}
+	if os.Getenv("DIVERGENCE_ALERT_INTERVAL") != "" {
+		options = options.WithDivergenceAlertInterval(parseTime("DIVERGENCE_ALERT_INTERVAL"))
+	}
 	if os.Getenv("UPDATER_POLL_RATE") != "" {
 		options = options.WithUpdaterPollRate(parseTime("UPDATER_POLL_RATE"))
 	}
+	if os.Getenv("UPDATER_PROBE_INTERVAL") != "" {
+		options = options.WithUpdaterProbeInterval(parseTime("UPDATER_PROBE_INTERVAL"))
+	}
+	if os.Getenv("UPDATER_MAX_FAILURES") != "" {
+		options = options.WithUpdaterMaxFailures(parseInt("UPDATER_MAX_FAILURES"))
+	}
+	if os.Getenv("CONFIG_REFRESH_INTERVAL") != "" {
+		options = options.WithConfigRefreshInterval(parseTime("CONFIG_REFRESH_INTERVAL"))
+	}
+	if os.Getenv("CONFIG_THRESHOLD") != "" {
+		options = options.WithConfigThreshold(parseInt("CONFIG_THRESHOLD"))
+	}
 	if os.Getenv("CONFIRMER_POLL_RATE") != "" {
 		options = options.WithConfirmerPollRate(parseTime("CONFIRMER_POLL_RATE"))
 	}
@@ -301,7 +285,22 @@ func parseOptions() lightnode.Options {
 		options = options.WithWatcherMaxBlockAdvance(uint64(parseInt("WATCHER_MAX_BLOCK_ADVANCE")))
 	}
 	if os.Getenv("WATCHER_CONFIDENCE_INTERVAL") != "" {
-		options = options.WithWatcherMaxBlockAdvance(uint64(parseInt("WATCHER_CONFIDENCE_INTERVAL")))
+		options = options.WithWatcherConfidenceInterval(uint64(parseInt("WATCHER_CONFIDENCE_INTERVAL")))
+	}
+	if os.Getenv("WATCHER_CONFIDENCE_INTERVALS") != "" {
+		options = options.WithWatcherConfidenceIntervals(parseConfidenceIntervals("WATCHER_CONFIDENCE_INTERVALS"))
+	}
+	if os.Getenv("WATCHER_RPC_MAX_FAILURES") != "" {
+		options = options.WithWatcherRPCMaxFailures(parseInt("WATCHER_RPC_MAX_FAILURES"))
+	}
+	if os.Getenv("WATCHER_RPC_BACKOFF") != "" {
+		options = options.WithWatcherRPCBackoff(parseTime("WATCHER_RPC_BACKOFF"))
+	}
+	if os.Getenv("WATCHER_RPC_QUORUM") != "" {
+		options = options.WithWatcherRPCQuorum(parseInt("WATCHER_RPC_QUORUM"))
+	}
+	if os.Getenv("WATCHER_MAX_ATTEMPTS") != "" {
+		options = options.WithWatcherMaxAttempts(parseInt("WATCHER_MAX_ATTEMPTS"))
 	}
 	if os.Getenv("EXPIRY") != "" {
 		options = options.WithTransactionExpiry(parseTime("EXPIRY"))
@@ -323,57 +322,57 @@ func parseOptions() lightnode.Options {
 	chains := map[multichain.Chain]binding.ChainOptions{}
 	if os.Getenv("RPC_ARBITRUM") != "" {
 		chains[multichain.Arbitrum] = binding.ChainOptions{
-			RPC:      pack.String(os.Getenv("RPC_ARBITRUM")),
+			RPC:      parseRPCs("RPC_ARBITRUM")[0],
 			Protocol: pack.String(os.Getenv("GATEWAY_ARBITRUM")),
 		}
 	}
 	if os.Getenv("RPC_AVALANCHE") != "" {
 		chains[multichain.Avalanche] = binding.ChainOptions{
-			RPC:      pack.String(os.Getenv("RPC_AVALANCHE")),
+			RPC:      parseRPCs("RPC_AVALANCHE")[0],
 			Protocol: pack.String(os.Getenv("GATEWAY_AVALANCHE")),
 		}
 	}
 	if os.Getenv("RPC_BINANCE") != "" {
 		chains[multichain.BinanceSmartChain] = binding.ChainOptions{
-			RPC:      pack.String(os.Getenv("RPC_BINANCE")),
+			RPC:      parseRPCs("RPC_BINANCE")[0],
 			Protocol: pack.String(os.Getenv("GATEWAY_BINANCE")),
 		}
 	}
 	if os.Getenv("RPC_BITCOIN") != "" {
 		chains[multichain.Bitcoin] = binding.ChainOptions{
-			RPC: pack.String(os.Getenv("RPC_BITCOIN")),
+			RPC: parseRPC("RPC_BITCOIN"),
 		}
 	}
 	if os.Getenv("RPC_BITCOIN_CASH") != "" {
 		chains[multichain.BitcoinCash] = binding.ChainOptions{
-			RPC: pack.String(os.Getenv("RPC_BITCOIN_CASH")),
+			RPC: parseRPC("RPC_BITCOIN_CASH"),
 		}
 	}
 	if os.Getenv("RPC_DIGIBYTE") != "" {
 		chains[multichain.DigiByte] = binding.ChainOptions{
-			RPC: pack.String(os.Getenv("RPC_DIGIBYTE")),
+			RPC: parseRPC("RPC_DIGIBYTE"),
 		}
 	}
 	if os.Getenv("RPC_DOGECOIN") != "" {
 		chains[multichain.Dogecoin] = binding.ChainOptions{
-			RPC: pack.String(os.Getenv("RPC_DOGECOIN")),
+			RPC: parseRPC("RPC_DOGECOIN"),
 		}
 	}
 	if os.Getenv("RPC_ETHEREUM") != "" {
 		chains[multichain.Ethereum] = binding.ChainOptions{
-			RPC:      pack.String(os.Getenv("RPC_ETHEREUM")),
+			RPC:      parseRPCs("RPC_ETHEREUM")[0],
 			Protocol: pack.String(os.Getenv("GATEWAY_ETHEREUM")),
 		}
 	}
 	if os.Getenv("RPC_FANTOM") != "" {
 		chains[multichain.Fantom] = binding.ChainOptions{
-			RPC:      pack.String(os.Getenv("RPC_FANTOM")),
+			RPC:      parseRPCs("RPC_FANTOM")[0],
 			Protocol: pack.String(os.Getenv("GATEWAY_FANTOM")),
 		}
 	}
 	if os.Getenv("RPC_FILECOIN") != "" {
 		chains[multichain.Filecoin] = binding.ChainOptions{
-			RPC: pack.String(os.Getenv("RPC_FILECOIN")),
+			RPC: parseRPC("RPC_FILECOIN"),
 			Extras: map[pack.String]pack.String{
 				"authToken": pack.String(os.Getenv("EXTRAS_FILECOIN_AUTH")),
 			},
@@ -381,34 +380,52 @@ func parseOptions() lightnode.Options {
 	}
 	if os.Getenv("RPC_GOERLI") != "" {
 		chains[multichain.Goerli] = binding.ChainOptions{
-			RPC:      pack.String(os.Getenv("RPC_GOERLI")),
+			RPC:      parseRPCs("RPC_GOERLI")[0],
 			Protocol: pack.String(os.Getenv("GATEWAY_GOERLI")),
 		}
 	}
 	if os.Getenv("RPC_POLYGON") != "" {
 		chains[multichain.Polygon] = binding.ChainOptions{
-			RPC:      pack.String(os.Getenv("RPC_POLYGON")),
+			RPC:      parseRPCs("RPC_POLYGON")[0],
 			Protocol: pack.String(os.Getenv("GATEWAY_POLYGON")),
 		}
 	}
 	if os.Getenv("RPC_SOLANA") != "" {
 		chains[multichain.Solana] = binding.ChainOptions{
-			RPC:      pack.String(os.Getenv("RPC_SOLANA")),
+			RPC:      parseRPCs("RPC_SOLANA")[0],
 			Protocol: pack.String(os.Getenv("GATEWAY_SOLANA")),
 		}
 	}
 	if os.Getenv("RPC_TERRA") != "" {
 		chains[multichain.Terra] = binding.ChainOptions{
-			RPC: pack.String(os.Getenv("RPC_TERRA")),
+			RPC: parseRPC("RPC_TERRA"),
 		}
 	}
 	if os.Getenv("RPC_ZCASH") != "" {
 		chains[multichain.Zcash] = binding.ChainOptions{
-			RPC: pack.String(os.Getenv("RPC_ZCASH")),
+			RPC: parseRPC("RPC_ZCASH"),
 		}
 	}
 	options = options.WithChains(chains)
 
+	// Every RPC after the first is a fallback for the watchers.
+	rpcs := map[multichain.Chain][]pack.String{}
+	for chain, name := range map[multichain.Chain]string{
+		multichain.Arbitrum:          "RPC_ARBITRUM",
+		multichain.Avalanche:         "RPC_AVALANCHE",
+		multichain.BinanceSmartChain: "RPC_BINANCE",
+		multichain.Ethereum:          "RPC_ETHEREUM",
+		multichain.Fantom:            "RPC_FANTOM",
+		multichain.Goerli:            "RPC_GOERLI",
+		multichain.Polygon:           "RPC_POLYGON",
+		multichain.Solana:            "RPC_SOLANA",
+	} {
+		if urls := parseRPCs(name); len(urls) > 1 {
+			rpcs[chain] = urls[1:]
+		}
+	}
+	options = options.WithWatcherRPCs(rpcs)
+
 	return options
 }
 
@@ -442,6 +459,14 @@ func parseTime(name string) time.Duration {
 	return time.Duration(duration) * time.Second
 }
 
+func parseMilliseconds(name string) time.Duration {
+	duration, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return 0 * time.Millisecond
+	}
+	return time.Duration(duration) * time.Millisecond
+}
+
 func parseAddresses(name string) []wire.Address {
 	addrStrings := strings.Split(os.Getenv(name), ",")
 	addrs := make([]wire.Address, len(addrStrings))
@@ -472,6 +497,181 @@ func parseRates(name string) map[string]rate.Limit {
 	return rates
 }
 
+func parseDurations(name string) map[string]time.Duration {
+	durationStrings := strings.Split(os.Getenv(name), ",")
+	durations := make(map[string]time.Duration)
+	for i := range durationStrings {
+		methodDuration := strings.Split(durationStrings[i], ":")
+		if len(methodDuration) != 2 {
+			panic(fmt.Sprintf("invalid duration pair %v", durationStrings[i]))
+		}
+		parsedDuration, err := strconv.Atoi(methodDuration[1])
+		if err != nil {
+			panic(fmt.Sprintf("invalid duration pair %v: %v", durationStrings[i], err))
+		}
+		durations[methodDuration[0]] = time.Duration(parsedDuration) * time.Second
+	}
+	return durations
+}
+
+// parseRPC reads the RPC endpoint of a chain which is not watched from the
+// environment variable. Only the watchers fail over between endpoints, so
+// fallbacks are rejected rather than silently ignored.
+func parseRPC(name string) pack.String {
+	urls := parseRPCs(name)
+	if len(urls) > 1 {
+		panic(fmt.Sprintf("%v has %v endpoints, but fallback endpoints are only supported for chains with watchers", name, len(urls)))
+	}
+	return urls[0]
+}
+
+// parseRPCs reads the RPC endpoints of a chain from the environment variable,
+// which has the format "url,...". The first endpoint is used by the bindings
+// and the others are fallbacks for the watchers.
+func parseRPCs(name string) []pack.String {
+	urls := []pack.String{}
+	for _, rpc := range strings.Split(os.Getenv(name), ",") {
+		if rpc = strings.TrimSpace(rpc); rpc != "" {
+			urls = append(urls, pack.String(rpc))
+		}
+	}
+	if len(urls) == 0 {
+		return []pack.String{""}
+	}
+	return urls
+}
+
+// parseConfidenceIntervals reads the confidence interval of each chain from the
+// environment variable, which has the format "chain:blocks,...".
+func parseConfidenceIntervals(name string) map[multichain.Chain]uint64 {
+	intervals := make(map[multichain.Chain]uint64)
+	for _, intervalString := range strings.Split(os.Getenv(name), ",") {
+		chainInterval := strings.Split(intervalString, ":")
+		if len(chainInterval) != 2 {
+			panic(fmt.Sprintf("invalid confidence interval %v", intervalString))
+		}
+		interval, err := strconv.ParseUint(chainInterval[1], 10, 64)
+		if err != nil {
+			panic(fmt.Sprintf("invalid confidence interval %v: %v", intervalString, err))
+		}
+		intervals[multichain.Chain(chainInterval[0])] = interval
+	}
+	return intervals
+}
+
+// parseRoutes overrides the pool, fan-out and strategy of the given routes with
+// those in the environment variable, which has the format
+// "method:pool:fanOut:strategy,...". Methods without a route start from the
+// default route.
+func parseRoutes(name string, routes map[string]dispatcher.Route) map[string]dispatcher.Route {
+	merged := make(map[string]dispatcher.Route, len(routes))
+	for method, route := range routes {
+		merged[method] = route
+	}
+	for _, routeString := range strings.Split(os.Getenv(name), ",") {
+		routeParts := strings.Split(routeString, ":")
+		if len(routeParts) != 4 {
+			panic(fmt.Sprintf("invalid route %v", routeString))
+		}
+		pool, err := dispatcher.ParsePool(routeParts[1])
+		if err != nil {
+			panic(fmt.Sprintf("invalid route %v: %v", routeString, err))
+		}
+		fanOut, err := strconv.Atoi(routeParts[2])
+		if err != nil {
+			panic(fmt.Sprintf("invalid route %v: %v", routeString, err))
+		}
+		strategy, err := dispatcher.ParseStrategy(routeParts[3])
+		if err != nil {
+			panic(fmt.Sprintf("invalid route %v: %v", routeString, err))
+		}
+
+		route, ok := merged[routeParts[0]]
+		if !ok {
+			route = dispatcher.DefaultRoute
+		}
+		route.Pool = pool
+		route.FanOut = fanOut
+		route.Strategy = strategy
+		merged[routeParts[0]] = route
+	}
+	return merged
+}
+
+// parseDarknodeURLs reads the environment variables describing how to derive
+// the URLs of the Darknodes. The port mapping has the format "p2p:rpc,..." and
+// the overrides have the format "id=url,...", where the ID is either the ID or
+// the "host:port" value of the multi-address.
+func parseDarknodeURLs(urls http.URLOptions) http.URLOptions {
+	if os.Getenv("DARKNODE_URL_SCHEME") != "" {
+		scheme := os.Getenv("DARKNODE_URL_SCHEME")
+		if scheme != "http" && scheme != "https" {
+			panic(fmt.Sprintf("invalid darknode url scheme %v", scheme))
+		}
+		urls = urls.WithScheme(scheme)
+	}
+	if os.Getenv("DARKNODE_PORT_OFFSET") != "" {
+		urls = urls.WithPortOffset(parseInt("DARKNODE_PORT_OFFSET"))
+	}
+	if os.Getenv("DARKNODE_PORTS") != "" {
+		ports := map[int]int{}
+		for _, portString := range strings.Split(os.Getenv("DARKNODE_PORTS"), ",") {
+			portParts := strings.Split(portString, ":")
+			if len(portParts) != 2 {
+				panic(fmt.Sprintf("invalid port mapping %v", portString))
+			}
+			from, err := strconv.Atoi(portParts[0])
+			if err != nil {
+				panic(fmt.Sprintf("invalid port mapping %v: %v", portString, err))
+			}
+			to, err := strconv.Atoi(portParts[1])
+			if err != nil {
+				panic(fmt.Sprintf("invalid port mapping %v: %v", portString, err))
+			}
+			ports[from] = to
+		}
+		urls = urls.WithPorts(ports)
+	}
+	if os.Getenv("DARKNODE_URLS") != "" {
+		overrides := map[string]string{}
+		for _, overrideString := range strings.Split(os.Getenv("DARKNODE_URLS"), ",") {
+			overrideParts := strings.SplitN(overrideString, "=", 2)
+			if len(overrideParts) != 2 {
+				panic(fmt.Sprintf("invalid darknode url %v", overrideString))
+			}
+			if _, err := url.Parse(overrideParts[1]); err != nil {
+				panic(fmt.Sprintf("invalid darknode url %v: %v", overrideString, err))
+			}
+			overrides[overrideParts[0]] = overrideParts[1]
+		}
+		urls = urls.WithOverrides(overrides)
+	}
+	if os.Getenv("DARKNODE_CA_BUNDLE") != "" {
+		config, err := http.LoadCABundle(os.Getenv("DARKNODE_CA_BUNDLE"))
+		if err != nil {
+			panic(fmt.Sprintf("invalid darknode ca bundle: %v", err))
+		}
+		urls = urls.WithTLS(config)
+	}
+	return urls
+}
+
+func parseBool(name string) bool {
+	value, err := strconv.ParseBool(os.Getenv(name))
+	if err != nil {
+		return false
+	}
+	return value
+}
+
+func parseMethodSet(name string) map[string]bool {
+	methods := make(map[string]bool)
+	for _, method := range strings.Split(os.Getenv(name), ",") {
+		methods[method] = true
+	}
+	return methods
+}
+
 func parsePubKey(name string) *id.PubKey {
 	pubKeyString := os.Getenv(name)
 	keyBytes, err := hex.DecodeString(pubKeyString)
@@ -1,15 +1,37 @@
 package main
 
 import (
-	"context"
+	"github.com/renproject/darknode/tx"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"github.com/renproject/aw/wire"
-	"github.com/sirupsen/logrus"
 )
 
 var _ = Describe("Lightnode cmd test", func() {
+	Context("when parsing backfill arguments", func() {
+		It("should parse a range of a burn and release selector", func() {
+			args, err := parseBackfillArgs([]string{"--selector", "BTC/fromEthereum", "--from", "100", "--to", "200", "--dry-run"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(args).Should(Equal(backfillArgs{
+				selector: tx.Selector("BTC/fromEthereum"),
+				from:     100,
+				to:       200,
+				dryRun:   true,
+			}))
+		})
+
+		It("should reject invalid arguments", func() {
+			_, err := parseBackfillArgs([]string{"--from", "100", "--to", "200"})
+			Expect(err).Should(HaveOccurred())
+			_, err = parseBackfillArgs([]string{"--selector", "BTC/toEthereum", "--from", "100", "--to", "200"})
+			Expect(err).Should(HaveOccurred())
+			_, err = parseBackfillArgs([]string{"--selector", "BTC/fromEthereum", "--from", "200", "--to", "100"})
+			Expect(err).Should(HaveOccurred())
+			_, err = parseBackfillArgs([]string{"--selector", "BTC/fromEthereum", "--from", "-1"})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
 	// FIXME: re-enable once devnet is at 0.4.0
 	// It("should fetch config from an rpc endpoint", func() {
 	// 	ctx, cancel := context.WithCancel(context.Background())
@@ -22,27 +44,6 @@ var _ = Describe("Lightnode cmd test", func() {
 	// 	Expect(ethConfs).NotTo(BeZero())
 	// })
 
-	It("should fail if there are no bootstrap nodes to fetch config from", func() {
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-
-		logger := logrus.New()
-		conf, err := getConfigFromBootstrap(ctx, logger, []wire.Address{})
-		Expect(conf).To(BeZero())
-		Expect(err).Should(HaveOccurred())
-	})
-
-	It("should fail if no bootstrap nodes to return configs from", func() {
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-
-		logger := logrus.New()
-		addrs := make([]wire.Address, 3)
-		conf, err := getConfigFromBootstrap(ctx, logger, addrs)
-		Expect(conf).To(BeZero())
-		Expect(err).Should(HaveOccurred())
-	})
-
 	// FIXME: re-enable once devnet is at 0.4.0
 	// It("should pass if one of the bootstrap nodes returns a config", func() {
 	// 	ctx, cancel := context.WithCancel(context.Background())
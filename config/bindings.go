@@ -0,0 +1,38 @@
+package config
+
+import (
+	"sync"
+
+	"github.com/renproject/darknode/binding"
+)
+
+// SwappableBindings holds bindings which can be replaced while they are in
+// use, such as when the network changes the confirmations of a chain. It is
+// safe for concurrent use.
+type SwappableBindings struct {
+	mu       *sync.RWMutex
+	bindings binding.Bindings
+}
+
+// NewSwappableBindings returns new `SwappableBindings` which start with the
+// given bindings.
+func NewSwappableBindings(bindings binding.Bindings) *SwappableBindings {
+	return &SwappableBindings{
+		mu:       new(sync.RWMutex),
+		bindings: bindings,
+	}
+}
+
+// Get returns the current bindings.
+func (b *SwappableBindings) Get() binding.Bindings {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.bindings
+}
+
+// Swap replaces the current bindings.
+func (b *SwappableBindings) Swap(bindings binding.Bindings) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bindings = bindings
+}
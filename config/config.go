@@ -0,0 +1,322 @@
+// Package config fetches the configuration of the network from the Darknodes
+// and keeps it up to date.
+package config
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/aw/wire"
+	"github.com/renproject/darknode/binding"
+	"github.com/renproject/darknode/engine"
+	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/darknode/tx"
+	"github.com/renproject/id"
+	"github.com/renproject/lightnode/http"
+	"github.com/renproject/multichain"
+	"github.com/renproject/pack"
+	"github.com/renproject/phi"
+	"github.com/renproject/surge"
+	"github.com/sirupsen/logrus"
+)
+
+// Config is the part of the configuration of the Lightnode which is defined by
+// the network.
+type Config struct {
+	// Whitelist is the list of selectors supported by the network.
+	Whitelist []tx.Selector
+	// Chains are the options of the chains used by the Lightnode, with the
+	// confirmations required by the network.
+	Chains map[multichain.Chain]binding.ChainOptions
+	// DistPubKey is the distributed public key of the primary shard.
+	DistPubKey *id.PubKey
+}
+
+// Agreement describes how many of the Bootstrap nodes returned the same
+// config.
+type Agreement struct {
+	// Queried is the number of Bootstrap nodes which were queried.
+	Queried int
+	// Responded is the number of Bootstrap nodes which returned a config.
+	Responded int
+	// Agreed is the number of Bootstrap nodes which returned the chosen config.
+	Agreed int
+	// Required is the number of Bootstrap nodes which must agree.
+	Required int
+}
+
+// Reached returns whether enough Bootstrap nodes agreed on the config.
+func (agreement Agreement) Reached() bool {
+	return agreement.Agreed >= agreement.Required
+}
+
+// String implements the `fmt.Stringer` interface.
+func (agreement Agreement) String() string {
+	return fmt.Sprintf("%v of %v bootstrap nodes agreed (%v responded, %v required)", agreement.Agreed, agreement.Queried, agreement.Responded, agreement.Required)
+}
+
+// Required returns the number of Bootstrap nodes which must agree on the
+// config, given the threshold and the number of Bootstrap nodes. A threshold
+// of zero means a majority of the Bootstrap nodes.
+func Required(threshold, numAddrs int) int {
+	if threshold > 0 {
+		return threshold
+	}
+	return numAddrs/2 + 1
+}
+
+// fetched is the config returned by a Bootstrap node.
+type fetched struct {
+	config Config
+	key    string
+	err    error
+}
+
+// Fetch asks every Bootstrap node for its config and public key, and returns
+// the config returned by the most Bootstrap nodes along with how many of them
+// agreed on it. The caller decides what to do when the agreement has not been
+// reached. An error is only returned if no Bootstrap node returned a config.
+// The confirmations of the network are applied to the given chain options,
+// which are not modified.
+func Fetch(ctx context.Context, logger logrus.FieldLogger, client http.Client, urls http.URLResolver, addrs []wire.Address, chains map[multichain.Chain]binding.ChainOptions, threshold int) (Config, Agreement, error) {
+	agreement := Agreement{
+		Queried:  len(addrs),
+		Required: Required(threshold, len(addrs)),
+	}
+
+	results := make([]fetched, len(addrs))
+	phi.ParForAll(addrs, func(i int) {
+		addr := addrs[i]
+		conf, err := FetchConfig(ctx, client, urls, addr)
+		if err != nil {
+			results[i].err = fmt.Errorf("cannot fetch config from %v: %v", addr.String(), err)
+			return
+		}
+		pubKey, err := FetchPubKey(ctx, client, urls, addr)
+		if err != nil {
+			results[i].err = fmt.Errorf("cannot fetch public key from %v: %v", addr.String(), err)
+			return
+		}
+		key, err := agreementKey(conf, &pubKey)
+		if err != nil {
+			results[i].err = fmt.Errorf("cannot compare config from %v: %v", addr.String(), err)
+			return
+		}
+		results[i] = fetched{
+			config: Config{
+				Whitelist:  conf.Whitelist,
+				Chains:     ApplyConfirmations(conf, chains),
+				DistPubKey: &pubKey,
+			},
+			key: key,
+		}
+	})
+
+	// Group the configs, preferring the config returned by the earliest
+	// Bootstrap node when the groups have the same size.
+	counts := map[string]int{}
+	best := -1
+	for i, result := range results {
+		if result.err != nil {
+			logger.Warnf("[config] %v", result.err)
+			continue
+		}
+		agreement.Responded++
+		counts[result.key]++
+		if best < 0 || counts[result.key] > counts[results[best].key] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Config{}, agreement, fmt.Errorf("could not load config from darknodes")
+	}
+	if len(counts) > 1 {
+		for i, result := range results {
+			if result.err == nil && result.key != results[best].key {
+				logger.Warnf("[config] %v disagrees with %v bootstrap nodes", addrs[i].String(), counts[results[best].key])
+			}
+		}
+	}
+	agreement.Agreed = counts[results[best].key]
+	return results[best].config, agreement, nil
+}
+
+// agreementKey returns a string which is equal for two Bootstrap nodes if and
+// only if they returned the same whitelist, confirmations and public key.
+func agreementKey(conf jsonrpc.ResponseQueryConfig, pubKey *id.PubKey) (string, error) {
+	whitelist := make([]string, len(conf.Whitelist))
+	for i, selector := range conf.Whitelist {
+		whitelist[i] = string(selector)
+	}
+	sort.Strings(whitelist)
+
+	// Maps are marshaled with sorted keys, so the encoding is deterministic.
+	key, err := json.Marshal(struct {
+		Whitelist        []string                      `json:"whitelist"`
+		Confirmations    map[multichain.Chain]pack.U64 `json:"confirmations"`
+		MaxConfirmations map[multichain.Chain]pack.U64 `json:"maxConfirmations"`
+		PubKey           string                        `json:"pubKey"`
+	}{
+		Whitelist:        whitelist,
+		Confirmations:    conf.Confirmations,
+		MaxConfirmations: conf.MaxConfirmations,
+		PubKey:           encodePubKey(pubKey),
+	})
+	return string(key), err
+}
+
+// ApplyConfirmations returns a copy of the given chain options with the
+// confirmations from the given config. Chains without a maximum number of
+// confirmations have no maximum.
+func ApplyConfirmations(conf jsonrpc.ResponseQueryConfig, chains map[multichain.Chain]binding.ChainOptions) map[multichain.Chain]binding.ChainOptions {
+	applied := make(map[multichain.Chain]binding.ChainOptions, len(chains))
+	for chain, chainOpt := range chains {
+		chainOpt.Confirmations = conf.Confirmations[chain]
+		if conf.MaxConfirmations[chain] != 0 {
+			chainOpt.MaxConfirmations = conf.MaxConfirmations[chain]
+		} else {
+			chainOpt.MaxConfirmations = pack.MaxU64
+		}
+		applied[chain] = chainOpt
+	}
+	return applied
+}
+
+// FetchConfig returns the config of the Darknode with the given address.
+func FetchConfig(ctx context.Context, client http.Client, urls http.URLResolver, addr wire.Address) (jsonrpc.ResponseQueryConfig, error) {
+	var resp jsonrpc.ResponseQueryConfig
+	params, err := json.Marshal(jsonrpc.ParamsQueryConfig{})
+	if err != nil {
+		return resp, fmt.Errorf("cannot marshal query config params: %v", err)
+	}
+	if err := call(ctx, client, urls, addr, jsonrpc.MethodQueryConfig, params, &resp); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// FetchPubKey returns the distributed public key of the primary shard from the
+// block state of the Darknode with the given address.
+func FetchPubKey(ctx context.Context, client http.Client, urls http.URLResolver, addr wire.Address) (id.PubKey, error) {
+	var resp jsonrpc.ResponseQueryBlockState
+	params, err := json.Marshal(jsonrpc.ParamsQueryBlockState{})
+	if err != nil {
+		return id.PubKey{}, fmt.Errorf("cannot marshal query block state params: %v", err)
+	}
+	if err := call(ctx, client, urls, addr, jsonrpc.MethodQueryBlockState, params, &resp); err != nil {
+		return id.PubKey{}, err
+	}
+	return ParsePubKey(resp)
+}
+
+// ParsePubKey returns the distributed public key of the primary shard from the
+// given block state.
+func ParsePubKey(response jsonrpc.ResponseQueryBlockState) (id.PubKey, error) {
+	systemContract := response.State.Get("System")
+	if systemContract == nil {
+		return id.PubKey{}, fmt.Errorf("system contract is nil")
+	}
+
+	var state engine.SystemState
+	if err := pack.Decode(&state, systemContract); err != nil {
+		return id.PubKey{}, err
+	}
+	if len(state.Shards.Primary) < 1 {
+		return id.PubKey{}, fmt.Errorf("nil primary shard")
+	}
+	shard := state.Shards.Primary[0]
+	var pub id.PubKey
+	if err := surge.FromBinary(&pub, shard.PubKey); err != nil {
+		return id.PubKey{}, err
+	}
+	return pub, nil
+}
+
+// call sends a request to the Darknode with the given address and unmarshals
+// the result into the given value.
+func call(ctx context.Context, client http.Client, urls http.URLResolver, addr wire.Address, method string, params json.RawMessage, v interface{}) error {
+	url, err := urls.URL(addr)
+	if err != nil {
+		return err
+	}
+	request := jsonrpc.Request{
+		Version: "2.0",
+		ID:      rand.Int31(),
+		Method:  method,
+		Params:  params,
+	}
+	response, err := client.SendRequest(ctx, url, request, nil)
+	if err != nil {
+		return fmt.Errorf("error calling %v: %v", method, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("error calling %v: %v", method, response.Error.Message)
+	}
+
+	raw, err := json.Marshal(response.Result)
+	if err != nil {
+		return fmt.Errorf("error marshaling %v result: %v", method, err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("cannot unmarshal %v result from %v: %v", method, url, err)
+	}
+	return nil
+}
+
+// Diff returns a description of each difference between the old and the new
+// config.
+func Diff(old, new Config) []string {
+	diffs := []string{}
+
+	oldSelectors := map[tx.Selector]bool{}
+	for _, selector := range old.Whitelist {
+		oldSelectors[selector] = true
+	}
+	newSelectors := map[tx.Selector]bool{}
+	for _, selector := range new.Whitelist {
+		newSelectors[selector] = true
+		if !oldSelectors[selector] {
+			diffs = append(diffs, fmt.Sprintf("whitelisted %v", selector))
+		}
+	}
+	for _, selector := range old.Whitelist {
+		if !newSelectors[selector] {
+			diffs = append(diffs, fmt.Sprintf("removed %v from whitelist", selector))
+		}
+	}
+
+	chains := make([]string, 0, len(new.Chains))
+	for chain := range new.Chains {
+		chains = append(chains, string(chain))
+	}
+	sort.Strings(chains)
+	for _, chainString := range chains {
+		chain := multichain.Chain(chainString)
+		oldOpt, newOpt := old.Chains[chain], new.Chains[chain]
+		if oldOpt.Confirmations != newOpt.Confirmations {
+			diffs = append(diffs, fmt.Sprintf("%v confirmations changed from %v to %v", chain, oldOpt.Confirmations, newOpt.Confirmations))
+		}
+		if oldOpt.MaxConfirmations != newOpt.MaxConfirmations {
+			diffs = append(diffs, fmt.Sprintf("%v max confirmations changed from %v to %v", chain, oldOpt.MaxConfirmations, newOpt.MaxConfirmations))
+		}
+	}
+
+	if oldKey, newKey := encodePubKey(old.DistPubKey), encodePubKey(new.DistPubKey); oldKey != newKey {
+		diffs = append(diffs, fmt.Sprintf("distributed public key changed from %v to %v", oldKey, newKey))
+	}
+	return diffs
+}
+
+// encodePubKey returns the hex encoding of the compressed public key.
+func encodePubKey(pubKey *id.PubKey) string {
+	if pubKey == nil {
+		return ""
+	}
+	return hex.EncodeToString(crypto.CompressPubkey((*ecdsa.PublicKey)(pubKey)))
+}
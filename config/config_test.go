@@ -0,0 +1,252 @@
+package config_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/lightnode/config"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/aw/wire"
+	"github.com/renproject/darknode/binding"
+	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/darknode/tx"
+	"github.com/renproject/id"
+	"github.com/renproject/lightnode/http"
+	"github.com/renproject/lightnode/testutils"
+	"github.com/renproject/multichain"
+	"github.com/renproject/pack"
+	"github.com/sirupsen/logrus"
+)
+
+// mockBootstrap is the config returned by a mock Bootstrap node.
+type mockBootstrap struct {
+	whitelist     []tx.Selector
+	confirmations pack.U64
+	pubKey        *id.PubKey
+}
+
+// initBootstraps starts a server for each mock Bootstrap node, and returns
+// their addresses along with a resolver which sends requests to them.
+func initBootstraps(nodes []mockBootstrap) ([]wire.Address, http.URLResolver, func()) {
+	addrs := make([]wire.Address, len(nodes))
+	overrides := map[string]string{}
+	servers := make([]*httptest.Server, len(nodes))
+	for i, node := range nodes {
+		servers[i] = httptest.NewServer(mockBootstrapHandler(node))
+		addrs[i] = wire.NewUnsignedAddress(wire.TCP, fmt.Sprintf("127.0.0.1:%v", 6000+i), uint64(time.Now().Unix()))
+		overrides[addrs[i].Value] = servers[i].URL
+	}
+	closeAll := func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	}
+	return addrs, http.NewURLResolver(http.DefaultURLOptions().WithOverrides(overrides)), closeAll
+}
+
+func mockBootstrapHandler(node mockBootstrap) nethttp.Handler {
+	state := testutils.MockSystemState()
+	state.Shards.Primary[0].PubKey = crypto.CompressPubkey((*ecdsa.PublicKey)(node.pubKey))
+	system, err := pack.Encode(state)
+	if err != nil {
+		panic(err)
+	}
+
+	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		var req jsonrpc.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(nethttp.StatusBadRequest)
+			return
+		}
+		var result interface{}
+		switch req.Method {
+		case jsonrpc.MethodQueryConfig:
+			result = jsonrpc.ResponseQueryConfig{
+				Whitelist:        node.whitelist,
+				Confirmations:    map[multichain.Chain]pack.U64{multichain.Bitcoin: node.confirmations},
+				MaxConfirmations: map[multichain.Chain]pack.U64{multichain.Bitcoin: node.confirmations},
+			}
+		case jsonrpc.MethodQueryBlockState:
+			result = jsonrpc.ResponseQueryBlockState{State: pack.Typed{pack.NewStructField("System", system)}}
+		}
+		json.NewEncoder(w).Encode(jsonrpc.Response{Version: "2.0", ID: req.ID, Result: result})
+	})
+}
+
+var _ = Describe("Config", func() {
+	client := http.NewClient(time.Second)
+	urls := http.NewURLResolver(http.DefaultURLOptions())
+
+	Context("when fetching the config", func() {
+		It("should fail if there are no bootstrap nodes to fetch config from", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			logger := logrus.New()
+			conf, agreement, err := Fetch(ctx, logger, client, urls, []wire.Address{}, nil, 0)
+			Expect(conf).To(BeZero())
+			Expect(agreement.Responded).To(BeZero())
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should fail if no bootstrap nodes to return configs from", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			logger := logrus.New()
+			addrs := make([]wire.Address, 3)
+			conf, agreement, err := Fetch(ctx, logger, client, urls, addrs, nil, 0)
+			Expect(conf).To(BeZero())
+			Expect(agreement.Queried).To(Equal(3))
+			Expect(agreement.Reached()).To(BeFalse())
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when the bootstrap nodes disagree", func() {
+		chains := map[multichain.Chain]binding.ChainOptions{
+			multichain.Bitcoin: {RPC: "http://bitcoin", Confirmations: 1},
+		}
+		whitelist := []tx.Selector{"BTC/toEthereum", "BTC/fromEthereum"}
+		pubKey := id.NewPrivKey().PubKey()
+
+		It("should select the config returned by the majority", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			addrs, urls, closeAll := initBootstraps([]mockBootstrap{
+				{whitelist: []tx.Selector{"ZEC/toEthereum"}, confirmations: 6, pubKey: pubKey},
+				{whitelist: whitelist, confirmations: 6, pubKey: pubKey},
+				{whitelist: whitelist, confirmations: 6, pubKey: pubKey},
+			})
+			defer closeAll()
+
+			conf, agreement, err := Fetch(ctx, logrus.New(), client, urls, addrs, chains, 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(agreement.Queried).To(Equal(3))
+			Expect(agreement.Responded).To(Equal(3))
+			Expect(agreement.Agreed).To(Equal(2))
+			Expect(agreement.Required).To(Equal(2))
+			Expect(agreement.Reached()).To(BeTrue())
+
+			Expect(conf.Whitelist).To(Equal(whitelist))
+			Expect(conf.Chains[multichain.Bitcoin].RPC).To(Equal(pack.String("http://bitcoin")))
+			Expect(conf.Chains[multichain.Bitcoin].Confirmations).To(Equal(pack.U64(6)))
+			Expect(conf.DistPubKey.X.Cmp(pubKey.X)).To(BeZero())
+
+			// The given chain options are not modified.
+			Expect(chains[multichain.Bitcoin].Confirmations).To(Equal(pack.U64(1)))
+		})
+
+		It("should not reach agreement if the confirmations differ", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			addrs, urls, closeAll := initBootstraps([]mockBootstrap{
+				{whitelist: whitelist, confirmations: 6, pubKey: pubKey},
+				{whitelist: whitelist, confirmations: 3, pubKey: pubKey},
+				{whitelist: whitelist, confirmations: 2, pubKey: pubKey},
+			})
+			defer closeAll()
+
+			conf, agreement, err := Fetch(ctx, logrus.New(), client, urls, addrs, chains, 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(agreement.Responded).To(Equal(3))
+			Expect(agreement.Agreed).To(Equal(1))
+			Expect(agreement.Reached()).To(BeFalse())
+
+			// Ties are broken by the earliest bootstrap node.
+			Expect(conf.Chains[multichain.Bitcoin].Confirmations).To(Equal(pack.U64(6)))
+		})
+
+		It("should not reach agreement if the public keys differ", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			addrs, urls, closeAll := initBootstraps([]mockBootstrap{
+				{whitelist: whitelist, confirmations: 6, pubKey: pubKey},
+				{whitelist: whitelist, confirmations: 6, pubKey: id.NewPrivKey().PubKey()},
+			})
+			defer closeAll()
+
+			_, agreement, err := Fetch(ctx, logrus.New(), client, urls, addrs, chains, 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(agreement.Agreed).To(Equal(1))
+			Expect(agreement.Required).To(Equal(2))
+			Expect(agreement.Reached()).To(BeFalse())
+		})
+
+		It("should use the threshold instead of a majority if one is given", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			addrs, urls, closeAll := initBootstraps([]mockBootstrap{
+				{whitelist: whitelist, confirmations: 6, pubKey: pubKey},
+				{whitelist: whitelist, confirmations: 3, pubKey: pubKey},
+				{whitelist: []tx.Selector{"ZEC/toEthereum"}, confirmations: 6, pubKey: pubKey},
+			})
+			defer closeAll()
+
+			_, agreement, err := Fetch(ctx, logrus.New(), client, urls, addrs, chains, 1)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(agreement.Agreed).To(Equal(1))
+			Expect(agreement.Required).To(Equal(1))
+			Expect(agreement.Reached()).To(BeTrue())
+
+			_, agreement, err = Fetch(ctx, logrus.New(), client, urls, addrs, chains, 3)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(agreement.Required).To(Equal(3))
+			Expect(agreement.Reached()).To(BeFalse())
+		})
+	})
+
+	Context("when checking the agreement", func() {
+		It("should require a majority by default", func() {
+			Expect(Required(0, 1)).To(Equal(1))
+			Expect(Required(0, 3)).To(Equal(2))
+			Expect(Required(0, 4)).To(Equal(3))
+			Expect(Required(2, 4)).To(Equal(2))
+
+			Expect(Agreement{Agreed: 2, Required: 2}.Reached()).To(BeTrue())
+			Expect(Agreement{Agreed: 1, Required: 2}.Reached()).To(BeFalse())
+		})
+	})
+
+	Context("when comparing configs", func() {
+		It("should describe each difference", func() {
+			pubKey := id.NewPrivKey().PubKey()
+			old := Config{
+				Whitelist: []tx.Selector{"BTC/toEthereum", "BTC/fromEthereum"},
+				Chains: map[multichain.Chain]binding.ChainOptions{
+					multichain.Bitcoin:  {Confirmations: 6, MaxConfirmations: 6},
+					multichain.Ethereum: {Confirmations: 30, MaxConfirmations: 30},
+				},
+				DistPubKey: pubKey,
+			}
+			Expect(Diff(old, old)).To(BeEmpty())
+
+			new := Config{
+				Whitelist: []tx.Selector{"BTC/toEthereum", "ZEC/fromEthereum"},
+				Chains: map[multichain.Chain]binding.ChainOptions{
+					multichain.Bitcoin:  {Confirmations: 6, MaxConfirmations: 6},
+					multichain.Ethereum: {Confirmations: 12, MaxConfirmations: 30},
+				},
+				DistPubKey: id.NewPrivKey().PubKey(),
+			}
+			diffs := Diff(old, new)
+			Expect(diffs).To(HaveLen(4))
+			Expect(diffs[0]).To(ContainSubstring("ZEC/fromEthereum"))
+			Expect(diffs[1]).To(ContainSubstring("BTC/fromEthereum"))
+			Expect(diffs[2]).To(ContainSubstring("Ethereum confirmations"))
+			Expect(diffs[3]).To(ContainSubstring("public key"))
+		})
+	})
+})
@@ -0,0 +1,54 @@
+package config
+
+import "time"
+
+// Enumerate default options.
+var (
+	DefaultRefreshInterval = 10 * time.Minute
+	DefaultStaticWhitelist = false
+	DefaultThreshold       = 0
+)
+
+// Options to configure the precise behaviour of the refresher.
+type Options struct {
+	// RefreshInterval is how often the config is fetched from the Bootstrap
+	// nodes. A zero value disables refreshing.
+	RefreshInterval time.Duration
+	// StaticWhitelist keeps the whitelist of the initial config, such as when
+	// it has been overridden, instead of following the network.
+	StaticWhitelist bool
+	// Threshold is the number of Bootstrap nodes which must return the same
+	// config before it is applied. A zero value means a majority of the
+	// Bootstrap nodes.
+	Threshold int
+}
+
+// DefaultOptions returns new options with default configurations that should
+// work for the majority of use cases.
+func DefaultOptions() Options {
+	return Options{
+		RefreshInterval: DefaultRefreshInterval,
+		StaticWhitelist: DefaultStaticWhitelist,
+		Threshold:       DefaultThreshold,
+	}
+}
+
+// WithRefreshInterval returns new options with the given refresh interval.
+func (opts Options) WithRefreshInterval(refreshInterval time.Duration) Options {
+	opts.RefreshInterval = refreshInterval
+	return opts
+}
+
+// WithStaticWhitelist returns new options with the whitelist kept static or
+// following the network.
+func (opts Options) WithStaticWhitelist(staticWhitelist bool) Options {
+	opts.StaticWhitelist = staticWhitelist
+	return opts
+}
+
+// WithThreshold returns new options with the given number of Bootstrap nodes
+// which must agree on the config.
+func (opts Options) WithThreshold(threshold int) Options {
+	opts.Threshold = threshold
+	return opts
+}
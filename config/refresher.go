@@ -0,0 +1,120 @@
+package config
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/renproject/aw/wire"
+	"github.com/renproject/lightnode/http"
+	"github.com/sirupsen/logrus"
+)
+
+// A Handler is called with the old and the new config whenever the config
+// changes.
+type Handler func(old, new Config)
+
+// A Refresher periodically fetches the config of the network from the Bootstrap
+// nodes, logs how it differs from the current config and passes the new config
+// to its handlers, so that the Lightnode does not keep validating against stale
+// data when the network rotates its keys or changes its whitelist.
+type Refresher struct {
+	logger  logrus.FieldLogger
+	client  http.Client
+	urls    http.URLResolver
+	addrs   []wire.Address
+	options Options
+
+	mu       *sync.RWMutex
+	current  Config
+	handlers []Handler
+}
+
+// NewRefresher returns a new `Refresher` which starts from the given config.
+// The chain options of the given config are the base to which the refreshed
+// confirmations are applied.
+func NewRefresher(logger logrus.FieldLogger, client http.Client, urls http.URLResolver, addrs []wire.Address, initial Config, options Options) *Refresher {
+	return &Refresher{
+		logger:   logger,
+		client:   client,
+		urls:     urls,
+		addrs:    addrs,
+		options:  options,
+		mu:       new(sync.RWMutex),
+		current:  initial,
+		handlers: []Handler{},
+	}
+}
+
+// Subscribe adds a handler which is called whenever the config changes.
+// Handlers are called in the order in which they were added, and must be added
+// before the `Refresher` is run.
+func (refresher *Refresher) Subscribe(handler Handler) {
+	refresher.handlers = append(refresher.handlers, handler)
+}
+
+// Current returns the current config.
+func (refresher *Refresher) Current() Config {
+	refresher.mu.RLock()
+	defer refresher.mu.RUnlock()
+
+	return refresher.current
+}
+
+// Run starts refreshing the config until the context is canceled. This function
+// is blocking.
+func (refresher *Refresher) Run(ctx context.Context) {
+	if refresher.options.RefreshInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(refresher.options.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresher.refresh(ctx)
+		}
+	}
+}
+
+// refresh fetches the config and, if it has changed, passes it to the handlers.
+func (refresher *Refresher) refresh(ctx context.Context) {
+	refreshCtx, cancel := context.WithTimeout(ctx, refresher.options.RefreshInterval)
+	defer cancel()
+
+	old := refresher.Current()
+	new, agreement, err := Fetch(refreshCtx, refresher.logger, refresher.client, refresher.urls, refresher.addrs, old.Chains, refresher.options.Threshold)
+	if err != nil {
+		refresher.logger.Errorf("[config] cannot refresh config: %v", err)
+		return
+	}
+	if !agreement.Reached() {
+		// Keep the current config rather than trusting a config which too
+		// few Bootstrap nodes agree on.
+		refresher.logger.Errorf("[config] degraded: keeping current config as %v", agreement)
+		return
+	}
+	if refresher.options.StaticWhitelist {
+		new.Whitelist = old.Whitelist
+	}
+
+	diffs := Diff(old, new)
+	if len(diffs) == 0 {
+		return
+	}
+	for _, diff := range diffs {
+		refresher.logger.Infof("[config] %v", diff)
+	}
+
+	refresher.mu.Lock()
+	refresher.current = new
+	refresher.mu.Unlock()
+
+	for _, handler := range refresher.handlers {
+		handler(old, new)
+	}
+}
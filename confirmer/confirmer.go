@@ -7,14 +7,17 @@ import (
 	"math/rand"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/renproject/darknode/binding"
 	"github.com/renproject/darknode/engine"
 	"github.com/renproject/darknode/jsonrpc"
 	"github.com/renproject/darknode/tx"
+	"github.com/renproject/lightnode/config"
 	"github.com/renproject/lightnode/db"
 	"github.com/renproject/lightnode/http"
+	"github.com/renproject/lightnode/watcher"
 	"github.com/renproject/multichain"
 	"github.com/renproject/pack"
 	"github.com/renproject/phi"
@@ -23,11 +26,27 @@ import (
 // Confirmer handles requests that have been validated. It checks if requests
 // have reached sufficient confirmations and stores those that have not to be
 // checked later.
+//
+// Chains with fallback RPC endpoints are queried in the same order as the
+// watchers query them, sharing the health of the endpoints. A tx whose
+// confirmations cannot be checked by any endpoint stays pending and is checked
+// again on the next poll.
 type Confirmer struct {
 	options    Options
 	dispatcher phi.Sender
 	database   db.DB
-	bindings   binding.Bindings
+	bindings   *config.SwappableBindings
+
+	fallbacksMu *sync.RWMutex
+	fallbacks   map[multichain.Chain]Fallbacks
+}
+
+// Fallbacks are the bindings of the fallback RPC endpoints of a chain. The
+// endpoint of the chain options is always the first endpoint, so the bindings
+// are in the same order as the remaining endpoints.
+type Fallbacks struct {
+	Endpoints *watcher.Endpoints
+	Bindings  []binding.Bindings
 }
 
 // New returns a new Confirmer.
@@ -36,8 +55,69 @@ func New(options Options, dispatcher phi.Sender, db db.DB, bindings binding.Bind
 		options:    options,
 		dispatcher: dispatcher,
 		database:   db,
-		bindings:   bindings,
+		bindings:   config.NewSwappableBindings(bindings),
+
+		fallbacksMu: new(sync.RWMutex),
+		fallbacks:   map[multichain.Chain]Fallbacks{},
+	}
+}
+
+// SetBindings replaces the bindings used to check the confirmations of
+// pending txs.
+func (confirmer *Confirmer) SetBindings(bindings binding.Bindings) {
+	confirmer.bindings.Swap(bindings)
+}
+
+// SetFallbacks replaces the bindings of the fallback RPC endpoints of each
+// chain. Chains without fallbacks only use the bindings of the chain options.
+func (confirmer *Confirmer) SetFallbacks(fallbacks map[multichain.Chain]Fallbacks) {
+	for chain, f := range fallbacks {
+		if len(f.Bindings)+1 != f.Endpoints.Len() {
+			panic(fmt.Sprintf("expected %v fallback bindings for %v, got %v", f.Endpoints.Len()-1, chain, len(f.Bindings)))
+		}
 	}
+
+	confirmer.fallbacksMu.Lock()
+	defer confirmer.fallbacksMu.Unlock()
+
+	confirmer.fallbacks = fallbacks
+}
+
+// query calls the function with the bindings of each endpoint of the chain,
+// until one of them answers. An error which is an answer from the chain, such
+// as the tx not having enough confirmations, does not fail over to the next
+// endpoint.
+func (confirmer *Confirmer) query(chain multichain.Chain, f func(bindings binding.Bindings) error) error {
+	confirmer.fallbacksMu.RLock()
+	fallbacks, ok := confirmer.fallbacks[chain]
+	confirmer.fallbacksMu.RUnlock()
+
+	if !ok {
+		return f(confirmer.bindings.Get())
+	}
+
+	var answer error
+	err := fallbacks.Endpoints.Try(func(i int) error {
+		bindings := confirmer.bindings.Get()
+		if i > 0 {
+			bindings = fallbacks.Bindings[i-1]
+		}
+		answer = f(bindings)
+		if answer != nil && !isAnswer(answer) {
+			return answer
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return answer
+}
+
+// isAnswer returns whether the error was returned by a chain which could be
+// reached.
+func isAnswer(err error) bool {
+	return strings.Contains(err.Error(), "insufficient confirmations") || strings.Contains(err.Error(), "result is nil")
 }
 
 // Run starts running the confirmer in the background which periodically checks
@@ -149,9 +229,12 @@ func (confirmer *Confirmer) lockTxConfirmed(ctx context.Context, transaction tx.
 			confirmer.options.Logger.Errorf("[confirmer] failed to decode input for tx=%v: %v", transaction.Hash.String(), err)
 			return false
 		}
-		_, err := confirmer.bindings.UTXOLockInfo(ctx, lockChain, transaction.Selector.Asset(), multichain.UTXOutpoint{
-			Hash:  input.Txid,
-			Index: input.Txindex,
+		err := confirmer.query(lockChain, func(bindings binding.Bindings) error {
+			_, err := bindings.UTXOLockInfo(ctx, lockChain, transaction.Selector.Asset(), multichain.UTXOutpoint{
+				Hash:  input.Txid,
+				Index: input.Txindex,
+			})
+			return err
 		})
 		if err != nil {
 			if !strings.Contains(err.Error(), "insufficient confirmations") {
@@ -178,7 +261,10 @@ func (confirmer *Confirmer) lockTxConfirmed(ctx context.Context, transaction tx.
 			confirmer.options.Logger.Errorf("[confirmer] failed to decode input for tx=%v: %v", transaction.Hash.String(), err)
 			return false
 		}
-		_, err := confirmer.bindings.AccountLockInfo(ctx, lockChain, transaction.Selector.Asset(), input.Txid)
+		err := confirmer.query(lockChain, func(bindings binding.Bindings) error {
+			_, err := bindings.AccountLockInfo(ctx, lockChain, transaction.Selector.Asset(), input.Txid)
+			return err
+		})
 		if err != nil {
 			if !strings.Contains(err.Error(), "insufficient confirmations") {
 				confirmer.options.Logger.Errorf("[confirmer] cannot get output for account tx=%v (%v): %v", input.Txid.String(), transaction.Selector.String(), err)
@@ -203,7 +289,10 @@ func (confirmer *Confirmer) burnTxConfirmed(ctx context.Context, transaction tx.
 		return false
 	}
 
-	_, _, _, err := confirmer.bindings.AccountBurnInfo(ctx, burnChain, transaction.Selector.Asset(), nonce)
+	err := confirmer.query(burnChain, func(bindings binding.Bindings) error {
+		_, _, _, err := bindings.AccountBurnInfo(ctx, burnChain, transaction.Selector.Asset(), nonce)
+		return err
+	})
 	if err != nil {
 		if !strings.Contains(err.Error(), "insufficient confirmations") {
 			confirmer.options.Logger.Errorf("[confirmer] cannot get burn info for tx=%v (%v): %v", transaction.Hash.String(), transaction.Selector.String(), err)
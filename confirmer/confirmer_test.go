@@ -3,6 +3,7 @@ package confirmer_test
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"math/rand"
 	"time"
 
@@ -12,13 +13,52 @@ import (
 	. "github.com/onsi/gomega"
 	. "github.com/renproject/lightnode/confirmer"
 
+	"github.com/renproject/darknode/binding"
 	"github.com/renproject/darknode/tx/txutil"
 	"github.com/renproject/id"
 	"github.com/renproject/lightnode/db"
 	"github.com/renproject/lightnode/testutils"
+	"github.com/renproject/lightnode/watcher"
+	"github.com/renproject/multichain"
+	"github.com/renproject/multichain/api/utxo"
+	"github.com/renproject/pack"
 	"github.com/sirupsen/logrus"
 )
 
+// unconfirmedBindings never consider a tx to have enough confirmations, as if
+// the chain required more confirmations than any tx has.
+func unconfirmedBindings() *binding.Callbacks {
+	err := fmt.Errorf("insufficient confirmations")
+	return &binding.Callbacks{
+		HandleAccountBurnInfo: func(ctx context.Context, chain multichain.Chain, asset multichain.Asset, nonce pack.Bytes32) (pack.U256, pack.String, pack.Bytes, error) {
+			return pack.U256{}, "", nil, err
+		},
+		HandleAccountLockInfo: func(ctx context.Context, chain multichain.Chain, asset multichain.Asset, txid pack.Bytes) (multichain.AccountTx, error) {
+			return nil, err
+		},
+		HandleUTXOLockInfo: func(ctx context.Context, chain multichain.Chain, asset multichain.Asset, outpoint multichain.UTXOutpoint) (multichain.UTXOutput, error) {
+			return utxo.Output{}, err
+		},
+	}
+}
+
+// unavailableBindings fail every request, as if the RPC endpoint of every chain
+// could not be reached.
+func unavailableBindings() *binding.Callbacks {
+	err := fmt.Errorf("connection refused")
+	return &binding.Callbacks{
+		HandleAccountBurnInfo: func(ctx context.Context, chain multichain.Chain, asset multichain.Asset, nonce pack.Bytes32) (pack.U256, pack.String, pack.Bytes, error) {
+			return pack.U256{}, "", nil, err
+		},
+		HandleAccountLockInfo: func(ctx context.Context, chain multichain.Chain, asset multichain.Asset, txid pack.Bytes) (multichain.AccountTx, error) {
+			return nil, err
+		},
+		HandleUTXOLockInfo: func(ctx context.Context, chain multichain.Chain, asset multichain.Asset, outpoint multichain.UTXOutpoint) (multichain.UTXOutput, error) {
+			return utxo.Output{}, err
+		},
+	}
+}
+
 var _ = Describe("Confirmer", func() {
 	cleanUp := func(db *sql.DB) {
 		dropTxs := "DROP TABLE IF EXISTS txs;"
@@ -83,6 +123,111 @@ var _ = Describe("Confirmer", func() {
 			}
 		})
 
+		It("should use the latest bindings", func() {
+			logger := logrus.New()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			dispatcher := testutils.NewMockDispatcher(false)
+			go dispatcher.Run(ctx)
+
+			sqlDB, err := sql.Open("sqlite3", "./test.db")
+			Expect(err).ToNot(HaveOccurred())
+			sqlDB.SetMaxOpenConns(1)
+			defer cleanUp(sqlDB)
+
+			database := db.New(sqlDB, 0)
+			Expect(database.Init()).To(Succeed())
+
+			pollInterval := 500 * time.Millisecond
+			confirmer := New(
+				DefaultOptions().
+					WithLogger(logger).
+					WithPollInterval(pollInterval).
+					WithExpiry(7*24*time.Hour),
+				dispatcher,
+				database,
+				unconfirmedBindings(),
+			)
+			go confirmer.Run(ctx)
+
+			hashes := make([]id.Hash, 10)
+			r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+			for i := range hashes {
+				transaction := txutil.RandomGoodTx(r)
+				Expect(database.InsertTx(transaction)).To(Succeed())
+				hashes[i] = transaction.Hash
+			}
+
+			// The txs do not have enough confirmations for the initial
+			// bindings.
+			time.Sleep(2 * pollInterval)
+			for i := range hashes {
+				status, err := database.TxStatus(hashes[i])
+				Expect(err).ToNot(HaveOccurred())
+				Expect(status).To(Equal(db.TxStatusConfirming))
+			}
+
+			// Lowering the confirmations of the chains confirms them.
+			confirmer.SetBindings(testutils.MockBindings(logger, 1))
+			for i := range hashes {
+				Eventually(func() (db.TxStatus, error) {
+					return database.TxStatus(hashes[i])
+				}, 5*time.Second).Should(Equal(db.TxStatusConfirmed))
+			}
+		})
+
+		It("should fail over to the fallback endpoints of a chain", func() {
+			logger := logrus.New()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			dispatcher := testutils.NewMockDispatcher(false)
+			go dispatcher.Run(ctx)
+
+			sqlDB, err := sql.Open("sqlite3", "./test.db")
+			Expect(err).ToNot(HaveOccurred())
+			sqlDB.SetMaxOpenConns(1)
+			defer cleanUp(sqlDB)
+
+			database := db.New(sqlDB, 0)
+			Expect(database.Init()).To(Succeed())
+
+			pollInterval := 500 * time.Millisecond
+			confirmer := New(
+				DefaultOptions().
+					WithLogger(logger).
+					WithPollInterval(pollInterval).
+					WithExpiry(7*24*time.Hour),
+				dispatcher,
+				database,
+				unavailableBindings(),
+			)
+
+			hashes := make([]id.Hash, 10)
+			fallbacks := map[multichain.Chain]Fallbacks{}
+			r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+			for i := range hashes {
+				transaction := txutil.RandomGoodTx(r)
+				Expect(database.InsertTx(transaction)).To(Succeed())
+				hashes[i] = transaction.Hash
+				fallbacks[transaction.Selector.Source()] = Fallbacks{
+					Endpoints: watcher.NewEndpoints(2, watcher.DefaultFailoverOptions()),
+					Bindings:  []binding.Bindings{testutils.MockBindings(logger, 1)},
+				}
+			}
+			confirmer.SetFallbacks(fallbacks)
+			go confirmer.Run(ctx)
+
+			for i := range hashes {
+				Eventually(func() (db.TxStatus, error) {
+					return database.TxStatus(hashes[i])
+				}, 5*time.Second).Should(Equal(db.TxStatusConfirmed))
+			}
+		})
+
 		It("should handle backpressure", func() {
 			// Initialise confirmer.
 			logger := logrus.New()
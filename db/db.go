@@ -30,6 +30,62 @@ const (
 	GatewayStatusUsed
 )
 
+// Peer is a Darknode in the address book, along with when it was last
+// discovered and when it last responded to a request.
+type Peer struct {
+	ID          string
+	Address     string
+	LastSeen    time.Time
+	LastSuccess time.Time
+	Failures    int
+}
+
+type BurnStatus uint8
+
+const (
+	BurnStatusNil BurnStatus = iota
+	BurnStatusFailed
+	BurnStatusSubmitted
+	BurnStatusReview
+	BurnStatusQuarantined
+)
+
+// Checkpoint is a block height up to which a watcher has checked its chain,
+// along with the hash of the block at that height. The hash is empty for
+// watchers which do not detect reorgs.
+type Checkpoint struct {
+	Selector string
+	Height   uint64
+	Hash     string
+}
+
+// Burn is a burn detected by a watcher, along with the result of forwarding
+// it to the Darknodes.
+type Burn struct {
+	Selector    string
+	Nonce       string
+	Txid        string
+	Block       uint64
+	TxHash      string
+	Status      BurnStatus
+	Error       string
+	UpdatedTime time.Time
+}
+
+// QuarantinedBurn is a burn which a watcher stopped retrying after it failed
+// to be forwarded too many times, so that the burns after it could be
+// forwarded.
+type QuarantinedBurn struct {
+	Selector        string    `json:"selector"`
+	Nonce           string    `json:"nonce"`
+	Txid            string    `json:"txid"`
+	Block           uint64    `json:"block"`
+	TxHash          string    `json:"txHash"`
+	Attempts        int       `json:"attempts"`
+	Error           string    `json:"error"`
+	QuarantinedTime time.Time `json:"quarantinedTime"`
+}
+
 type Scannable interface {
 	Scan(dest ...interface{}) error
 }
@@ -84,6 +140,61 @@ type DB interface {
 
 	// GatewayCount returns the number of gateways persisted
 	MaxGatewayCount() int
+
+	// InsertPeer inserts the peer into the database, replacing any peer with
+	// the same ID.
+	InsertPeer(peer Peer) error
+
+	// Peers returns all peers in the database.
+	Peers() ([]Peer, error)
+
+	// DeletePeer removes the peer with the given ID from the database.
+	DeletePeer(id string) error
+
+	// InsertCheckpoint inserts the checkpoint into the database, replacing any
+	// checkpoint of the same selector at the same height.
+	InsertCheckpoint(checkpoint Checkpoint) error
+
+	// Checkpoints returns up to limit checkpoints of the given selector, from
+	// the most recent.
+	Checkpoints(selector string, limit int) ([]Checkpoint, error)
+
+	// DeleteCheckpoints removes the checkpoints of the given selector which are
+	// above the given height.
+	DeleteCheckpoints(selector string, height uint64) error
+
+	// PruneCheckpoints removes all but the most recent keep checkpoints of the
+	// given selector.
+	PruneCheckpoints(selector string, keep int) error
+
+	// InsertBurn inserts the burn into the database, replacing any burn of the
+	// same selector with the same nonce.
+	InsertBurn(burn Burn) error
+
+	// Burn gets the burn of the given selector with the given nonce. It returns
+	// an `sql.ErrNoRows` if the burn cannot be found.
+	Burn(selector, nonce string) (Burn, error)
+
+	// Burns returns burns of the given selector with the given pagination
+	// options, from the most recent block and the highest nonce.
+	Burns(selector string, offset, limit int) ([]Burn, error)
+
+	// InsertQuarantinedBurn inserts the quarantined burn into the database,
+	// replacing any quarantined burn of the same selector with the same nonce.
+	InsertQuarantinedBurn(burn QuarantinedBurn) error
+
+	// QuarantinedBurn returns the quarantined burn of the given selector with
+	// the given nonce.
+	QuarantinedBurn(selector, nonce string) (QuarantinedBurn, error)
+
+	// QuarantinedBurns returns quarantined burns of the given selector with the
+	// given pagination options, from the most recent block and the highest
+	// nonce.
+	QuarantinedBurns(selector string, offset, limit int) ([]QuarantinedBurn, error)
+
+	// DeleteQuarantinedBurn removes the quarantined burn of the given selector
+	// with the given nonce, if there is one.
+	DeleteQuarantinedBurn(selector, nonce string) error
 }
 
 type database struct {
@@ -287,6 +398,42 @@ CREATE TABLE IF NOT EXISTS gateways (
 		ghash              VARCHAR,
 		version            VARCHAR
 );
+CREATE TABLE IF NOT EXISTS peers (
+		id                 VARCHAR NOT NULL PRIMARY KEY,
+		address            VARCHAR,
+		last_seen          BIGINT,
+		last_success       BIGINT,
+		failures           INT
+);
+CREATE TABLE IF NOT EXISTS watcher_checkpoints (
+		selector           VARCHAR(255) NOT NULL,
+		height             BIGINT NOT NULL,
+		hash               VARCHAR,
+		created_time       BIGINT,
+		PRIMARY KEY (selector, height)
+);
+CREATE TABLE IF NOT EXISTS watcher_burns (
+		selector           VARCHAR(255) NOT NULL,
+		nonce              VARCHAR NOT NULL,
+		txid               VARCHAR,
+		block              BIGINT,
+		tx_hash            VARCHAR,
+		status             SMALLINT,
+		error              VARCHAR,
+		updated_time       BIGINT,
+		PRIMARY KEY (selector, nonce)
+);
+CREATE TABLE IF NOT EXISTS watcher_quarantine (
+		selector           VARCHAR(255) NOT NULL,
+		nonce              VARCHAR NOT NULL,
+		txid               VARCHAR,
+		block              BIGINT,
+		tx_hash            VARCHAR,
+		attempts           INT,
+		error              VARCHAR,
+		quarantined_time   BIGINT,
+		PRIMARY KEY (selector, nonce)
+);
 `
 	_, err := db.db.Exec(script)
 	return err
@@ -467,6 +614,205 @@ func (db database) Prune(expiry time.Duration) error {
 	return err
 }
 
+// InsertPeer implements the DB interface.
+func (db database) InsertPeer(peer Peer) error {
+	script := `INSERT INTO peers (id, address, last_seen, last_success, failures)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (id) DO UPDATE SET address = $2, last_seen = $3, last_success = $4, failures = $5;`
+	_, err := db.db.Exec(script, peer.ID, peer.Address, unixOrZero(peer.LastSeen), unixOrZero(peer.LastSuccess), peer.Failures)
+	return err
+}
+
+// Peers implements the DB interface.
+func (db database) Peers() ([]Peer, error) {
+	rows, err := db.db.Query(`SELECT id, address, last_seen, last_success, failures FROM peers;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	peers := []Peer{}
+	for rows.Next() {
+		var peer Peer
+		var lastSeen, lastSuccess int64
+		if err := rows.Scan(&peer.ID, &peer.Address, &lastSeen, &lastSuccess, &peer.Failures); err != nil {
+			return nil, err
+		}
+		peer.LastSeen = timeOrZero(lastSeen)
+		peer.LastSuccess = timeOrZero(lastSuccess)
+		peers = append(peers, peer)
+	}
+	return peers, rows.Err()
+}
+
+// DeletePeer implements the DB interface.
+func (db database) DeletePeer(id string) error {
+	_, err := db.db.Exec("DELETE FROM peers WHERE id = $1;", id)
+	return err
+}
+
+// InsertCheckpoint implements the DB interface.
+func (db database) InsertCheckpoint(checkpoint Checkpoint) error {
+	script := `INSERT INTO watcher_checkpoints (selector, height, hash, created_time)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (selector, height) DO UPDATE SET hash = $3, created_time = $4;`
+	_, err := db.db.Exec(script, checkpoint.Selector, checkpoint.Height, checkpoint.Hash, time.Now().Unix())
+	return err
+}
+
+// Checkpoints implements the DB interface.
+func (db database) Checkpoints(selector string, limit int) ([]Checkpoint, error) {
+	script := `SELECT selector, height, hash FROM watcher_checkpoints WHERE selector = $1 ORDER BY height DESC LIMIT $2;`
+	rows, err := db.db.Query(script, selector, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkpoints := []Checkpoint{}
+	for rows.Next() {
+		var checkpoint Checkpoint
+		if err := rows.Scan(&checkpoint.Selector, &checkpoint.Height, &checkpoint.Hash); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, rows.Err()
+}
+
+// DeleteCheckpoints implements the DB interface.
+func (db database) DeleteCheckpoints(selector string, height uint64) error {
+	_, err := db.db.Exec(`DELETE FROM watcher_checkpoints WHERE selector = $1 AND height > $2;`, selector, height)
+	return err
+}
+
+// PruneCheckpoints implements the DB interface.
+func (db database) PruneCheckpoints(selector string, keep int) error {
+	script := `DELETE FROM watcher_checkpoints WHERE selector = $1 AND height NOT IN
+(SELECT height FROM watcher_checkpoints WHERE selector = $1 ORDER BY height DESC LIMIT $2);`
+	_, err := db.db.Exec(script, selector, keep)
+	return err
+}
+
+// InsertBurn implements the DB interface.
+func (db database) InsertBurn(burn Burn) error {
+	script := `INSERT INTO watcher_burns (selector, nonce, txid, block, tx_hash, status, error, updated_time)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (selector, nonce) DO UPDATE SET txid = $3, block = $4, tx_hash = $5, status = $6, error = $7, updated_time = $8;`
+	_, err := db.db.Exec(script, burn.Selector, burn.Nonce, burn.Txid, burn.Block, burn.TxHash, burn.Status, burn.Error, time.Now().Unix())
+	return err
+}
+
+// Burn implements the DB interface.
+func (db database) Burn(selector, nonce string) (Burn, error) {
+	script := `SELECT selector, nonce, txid, block, tx_hash, status, error, updated_time FROM watcher_burns
+WHERE selector = $1 AND nonce = $2;`
+	return scanBurn(db.db.QueryRow(script, selector, nonce))
+}
+
+// Burns implements the DB interface. Nonces are stored as decimal strings, so
+// they are ordered by length before being compared as text.
+func (db database) Burns(selector string, offset, limit int) ([]Burn, error) {
+	script := `SELECT selector, nonce, txid, block, tx_hash, status, error, updated_time FROM watcher_burns
+WHERE selector = $1 ORDER BY block DESC, LENGTH(nonce) DESC, nonce DESC LIMIT $2 OFFSET $3;`
+	rows, err := db.db.Query(script, selector, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	burns := []Burn{}
+	for rows.Next() {
+		burn, err := scanBurn(rows)
+		if err != nil {
+			return nil, err
+		}
+		burns = append(burns, burn)
+	}
+	return burns, rows.Err()
+}
+
+func scanBurn(row Scannable) (Burn, error) {
+	var burn Burn
+	var updatedTime int64
+	if err := row.Scan(&burn.Selector, &burn.Nonce, &burn.Txid, &burn.Block, &burn.TxHash, &burn.Status, &burn.Error, &updatedTime); err != nil {
+		return Burn{}, err
+	}
+	burn.UpdatedTime = timeOrZero(updatedTime)
+	return burn, nil
+}
+
+// InsertQuarantinedBurn implements the DB interface.
+func (db database) InsertQuarantinedBurn(burn QuarantinedBurn) error {
+	script := `INSERT INTO watcher_quarantine (selector, nonce, txid, block, tx_hash, attempts, error, quarantined_time)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (selector, nonce) DO UPDATE SET txid = $3, block = $4, tx_hash = $5, attempts = $6, error = $7, quarantined_time = $8;`
+	_, err := db.db.Exec(script, burn.Selector, burn.Nonce, burn.Txid, burn.Block, burn.TxHash, burn.Attempts, burn.Error, time.Now().Unix())
+	return err
+}
+
+// QuarantinedBurn implements the DB interface.
+func (db database) QuarantinedBurn(selector, nonce string) (QuarantinedBurn, error) {
+	script := `SELECT selector, nonce, txid, block, tx_hash, attempts, error, quarantined_time FROM watcher_quarantine
+WHERE selector = $1 AND nonce = $2;`
+	return scanQuarantinedBurn(db.db.QueryRow(script, selector, nonce))
+}
+
+// QuarantinedBurns implements the DB interface. Nonces are ordered in the same
+// way as in `Burns`.
+func (db database) QuarantinedBurns(selector string, offset, limit int) ([]QuarantinedBurn, error) {
+	script := `SELECT selector, nonce, txid, block, tx_hash, attempts, error, quarantined_time FROM watcher_quarantine
+WHERE selector = $1 ORDER BY block DESC, LENGTH(nonce) DESC, nonce DESC LIMIT $2 OFFSET $3;`
+	rows, err := db.db.Query(script, selector, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	burns := []QuarantinedBurn{}
+	for rows.Next() {
+		burn, err := scanQuarantinedBurn(rows)
+		if err != nil {
+			return nil, err
+		}
+		burns = append(burns, burn)
+	}
+	return burns, rows.Err()
+}
+
+func scanQuarantinedBurn(row Scannable) (QuarantinedBurn, error) {
+	var burn QuarantinedBurn
+	var quarantinedTime int64
+	if err := row.Scan(&burn.Selector, &burn.Nonce, &burn.Txid, &burn.Block, &burn.TxHash, &burn.Attempts, &burn.Error, &quarantinedTime); err != nil {
+		return QuarantinedBurn{}, err
+	}
+	burn.QuarantinedTime = timeOrZero(quarantinedTime)
+	return burn, nil
+}
+
+// DeleteQuarantinedBurn implements the DB interface.
+func (db database) DeleteQuarantinedBurn(selector, nonce string) error {
+	script := "DELETE FROM watcher_quarantine WHERE selector = $1 AND nonce = $2;"
+	_, err := db.db.Exec(script, selector, nonce)
+	return err
+}
+
+// unixOrZero returns the unix timestamp of the time, or zero for the zero time.
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// timeOrZero is the inverse of `unixOrZero`.
+func timeOrZero(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
+
 func rowToTx(row Scannable) (tx.Tx, error) {
 	var hash, selector, txidStr, amountStr, payloadStr, phashStr, toStr, nonceStr, nhashStr, gpubkeyStr, ghashStr, version string
 	var txindex int
@@ -56,7 +56,7 @@ var _ = Describe("Lightnode db", func() {
 	}
 
 	cleanUp := func(db *sql.DB) {
-		dropTxs := "DROP TABLE IF EXISTS txs; DROP TABLE IF EXISTS gateways;"
+		dropTxs := "DROP TABLE IF EXISTS txs; DROP TABLE IF EXISTS gateways; DROP TABLE IF EXISTS peers; DROP TABLE IF EXISTS watcher_checkpoints; DROP TABLE IF EXISTS watcher_burns; DROP TABLE IF EXISTS watcher_quarantine;"
 		_, err := db.Exec(dropTxs)
 		Expect(err).NotTo(HaveOccurred())
 	}
@@ -171,6 +171,178 @@ var _ = Describe("Lightnode db", func() {
 				})
 			})
 
+			Context("when interacting with peers", func() {
+				It("should insert, update and delete peers", func() {
+					sqlDB := init(dbname)
+					defer destroy(sqlDB)
+					db := New(sqlDB, 100)
+					Expect(db.Init()).Should(Succeed())
+
+					now := time.Unix(time.Now().Unix(), 0)
+					peer := Peer{
+						ID:       "peer",
+						Address:  "/ip4/127.0.0.1/tcp/18514/ren/peer",
+						LastSeen: now,
+					}
+					Expect(db.InsertPeer(peer)).Should(Succeed())
+					peers, err := db.Peers()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(peers).Should(HaveLen(1))
+					Expect(peers[0].LastSeen.Equal(now)).Should(BeTrue())
+					Expect(peers[0].LastSuccess.IsZero()).Should(BeTrue())
+
+					peer.LastSuccess = now
+					peer.Failures = 2
+					Expect(db.InsertPeer(peer)).Should(Succeed())
+					peers, err = db.Peers()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(peers).Should(HaveLen(1))
+					Expect(peers[0].LastSuccess.Equal(now)).Should(BeTrue())
+					Expect(peers[0].Failures).Should(Equal(2))
+
+					Expect(db.DeletePeer(peer.ID)).Should(Succeed())
+					peers, err = db.Peers()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(peers).Should(BeEmpty())
+				})
+			})
+
+			Context("when interacting with watcher checkpoints", func() {
+				It("should rewind and prune checkpoints", func() {
+					sqlDB := init(dbname)
+					defer destroy(sqlDB)
+					db := New(sqlDB, 100)
+					Expect(db.Init()).Should(Succeed())
+
+					selector := "BTC/fromEthereum"
+					for height := uint64(1); height <= 10; height++ {
+						Expect(db.InsertCheckpoint(Checkpoint{Selector: selector, Height: height, Hash: "old"})).Should(Succeed())
+					}
+					Expect(db.InsertCheckpoint(Checkpoint{Selector: "ZEC/fromEthereum", Height: 20})).Should(Succeed())
+
+					checkpoints, err := db.Checkpoints(selector, 3)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(checkpoints).Should(Equal([]Checkpoint{
+						{Selector: selector, Height: 10, Hash: "old"},
+						{Selector: selector, Height: 9, Hash: "old"},
+						{Selector: selector, Height: 8, Hash: "old"},
+					}))
+
+					Expect(db.DeleteCheckpoints(selector, 7)).Should(Succeed())
+					Expect(db.InsertCheckpoint(Checkpoint{Selector: selector, Height: 7, Hash: "new"})).Should(Succeed())
+					Expect(db.PruneCheckpoints(selector, 2)).Should(Succeed())
+					checkpoints, err = db.Checkpoints(selector, 10)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(checkpoints).Should(Equal([]Checkpoint{
+						{Selector: selector, Height: 7, Hash: "new"},
+						{Selector: selector, Height: 6, Hash: "old"},
+					}))
+
+					checkpoints, err = db.Checkpoints("ZEC/fromEthereum", 10)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(checkpoints).Should(HaveLen(1))
+				})
+			})
+
+			Context("when interacting with watcher burns", func() {
+				It("should record the latest result of each burn", func() {
+					sqlDB := init(dbname)
+					defer destroy(sqlDB)
+					db := New(sqlDB, 100)
+					Expect(db.Init()).Should(Succeed())
+
+					selector := "BTC/fromEthereum"
+					burn := Burn{
+						Selector: selector,
+						Nonce:    "1",
+						Txid:     "txid",
+						Block:    100,
+						Status:   BurnStatusFailed,
+						Error:    "darknodes unavailable",
+					}
+					Expect(db.InsertBurn(burn)).Should(Succeed())
+					burn.TxHash = "hash"
+					burn.Status = BurnStatusSubmitted
+					burn.Error = ""
+					Expect(db.InsertBurn(burn)).Should(Succeed())
+					Expect(db.InsertBurn(Burn{Selector: selector, Nonce: "2", Block: 101, Status: BurnStatusSubmitted})).Should(Succeed())
+					Expect(db.InsertBurn(Burn{Selector: selector, Nonce: "9", Block: 100, Status: BurnStatusSubmitted})).Should(Succeed())
+					Expect(db.InsertBurn(Burn{Selector: selector, Nonce: "10", Block: 100, Status: BurnStatusSubmitted})).Should(Succeed())
+
+					stored, err := db.Burn(selector, "1")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(stored.TxHash).Should(Equal("hash"))
+					Expect(stored.Status).Should(Equal(BurnStatusSubmitted))
+					Expect(stored.Error).Should(BeEmpty())
+					Expect(stored.UpdatedTime.IsZero()).Should(BeFalse())
+
+					burns, err := db.Burns(selector, 0, 10)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(burns).Should(HaveLen(4))
+					Expect(burns[0].Nonce).Should(Equal("2"))
+					Expect(burns[1].Nonce).Should(Equal("10"))
+					Expect(burns[2].Nonce).Should(Equal("9"))
+					Expect(burns[3].Nonce).Should(Equal("1"))
+
+					_, err = db.Burn(selector, "3")
+					Expect(err).Should(Equal(sql.ErrNoRows))
+				})
+			})
+
+			Context("when interacting with quarantined burns", func() {
+				It("should insert, list and delete quarantined burns", func() {
+					sqlDB := init(dbname)
+					defer destroy(sqlDB)
+					db := New(sqlDB, 100)
+					Expect(db.Init()).Should(Succeed())
+
+					selector := "BTC/fromEthereum"
+					burn := QuarantinedBurn{
+						Selector: selector,
+						Nonce:    "1",
+						Txid:     "txid",
+						Block:    100,
+						TxHash:   "hash",
+						Attempts: 3,
+						Error:    "invalid recipient",
+					}
+					Expect(db.InsertQuarantinedBurn(burn)).Should(Succeed())
+					burn.Attempts = 5
+					Expect(db.InsertQuarantinedBurn(burn)).Should(Succeed())
+					Expect(db.InsertQuarantinedBurn(QuarantinedBurn{Selector: selector, Nonce: "2", Block: 101, Attempts: 5})).Should(Succeed())
+					Expect(db.InsertQuarantinedBurn(QuarantinedBurn{Selector: "BTC/fromSolana", Nonce: "1", Block: 1, Attempts: 5})).Should(Succeed())
+					Expect(db.InsertQuarantinedBurn(QuarantinedBurn{Selector: "BTC/fromSolana", Nonce: "9", Block: 1, Attempts: 5})).Should(Succeed())
+					Expect(db.InsertQuarantinedBurn(QuarantinedBurn{Selector: "BTC/fromSolana", Nonce: "10", Block: 1, Attempts: 5})).Should(Succeed())
+
+					burns, err := db.QuarantinedBurns(selector, 0, 10)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(burns).Should(HaveLen(2))
+					Expect(burns[0].Nonce).Should(Equal("2"))
+					Expect(burns[1].Attempts).Should(Equal(5))
+					Expect(burns[1].Error).Should(Equal("invalid recipient"))
+					Expect(burns[1].QuarantinedTime.IsZero()).Should(BeFalse())
+
+					quarantined, err := db.QuarantinedBurn(selector, "1")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(quarantined.Attempts).Should(Equal(5))
+					Expect(quarantined.TxHash).Should(Equal("hash"))
+
+					burns, err = db.QuarantinedBurns("BTC/fromSolana", 0, 10)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(burns).Should(HaveLen(3))
+					Expect(burns[0].Nonce).Should(Equal("10"))
+					Expect(burns[1].Nonce).Should(Equal("9"))
+
+					Expect(db.DeleteQuarantinedBurn(selector, "2")).Should(Succeed())
+					burns, err = db.QuarantinedBurns(selector, 0, 10)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(burns).Should(HaveLen(1))
+					Expect(burns[0].Nonce).Should(Equal("1"))
+					_, err = db.QuarantinedBurn(selector, "2")
+					Expect(err).Should(Equal(sql.ErrNoRows))
+				})
+			})
+
 			Context("when querying gateways", func() {
 				It("should return a page of gateways", func() {
 					sqlDB := init(dbname)
@@ -0,0 +1,126 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/lightnode/http"
+)
+
+// batchResult is the outcome of a request sent as part of a batch.
+type batchResult struct {
+	response jsonrpc.Response
+	err      error
+}
+
+// pendingRequest is a request waiting for its batch to be sent.
+type pendingRequest struct {
+	request jsonrpc.Request
+	result  chan batchResult
+}
+
+// batch is the set of requests waiting to be sent to the same URL.
+type batch struct {
+	pending []pendingRequest
+}
+
+// A batcher groups requests that are sent to the same Darknode within a short
+// window into a single JSON-RPC batch, so that a burst of requests does not
+// turn into one HTTP request per request per Darknode. It is safe for
+// concurrent use.
+type batcher struct {
+	client  http.Client
+	window  time.Duration
+	maxSize int
+
+	mu     *sync.Mutex
+	nextID uint64
+	queues map[string]*batch
+}
+
+func newBatcher(client http.Client, window time.Duration, maxSize int) *batcher {
+	return &batcher{
+		client:  client,
+		window:  window,
+		maxSize: maxSize,
+		mu:      new(sync.Mutex),
+		queues:  map[string]*batch{},
+	}
+}
+
+// send the request to the URL as part of the next batch for the URL, and wait
+// for its response. The batch is sent once the window has passed or it is
+// full, regardless of whether the context is cancelled.
+func (batcher *batcher) send(ctx context.Context, url string, req jsonrpc.Request) (jsonrpc.Response, error) {
+	result := make(chan batchResult, 1)
+	id := req.ID
+
+	batcher.mu.Lock()
+	queue, ok := batcher.queues[url]
+	if !ok {
+		queue = &batch{}
+		batcher.queues[url] = queue
+		time.AfterFunc(batcher.window, func() {
+			batcher.flush(url, queue)
+		})
+	}
+
+	// Requests from different clients may have the same ID, so they are given
+	// an ID which is unique within the batch.
+	req.ID = batcher.nextID
+	batcher.nextID++
+	queue.pending = append(queue.pending, pendingRequest{request: req, result: result})
+	full := batcher.maxSize > 0 && len(queue.pending) >= batcher.maxSize
+	batcher.mu.Unlock()
+
+	if full {
+		go batcher.flush(url, queue)
+	}
+
+	select {
+	case <-ctx.Done():
+		return jsonrpc.Response{}, ctx.Err()
+	case res := <-result:
+		res.response.ID = id
+		return res.response, res.err
+	}
+}
+
+// flush sends the batch if it has not already been sent.
+func (batcher *batcher) flush(url string, queue *batch) {
+	batcher.mu.Lock()
+	if batcher.queues[url] != queue {
+		batcher.mu.Unlock()
+		return
+	}
+	delete(batcher.queues, url)
+	pending := queue.pending
+	batcher.mu.Unlock()
+
+	// The requests do not share a context, so the batch is only bounded by
+	// the timeout of the client.
+	ctx := context.Background()
+
+	// A single request is sent on its own, as not every server accepts
+	// batches.
+	if len(pending) == 1 {
+		response, err := batcher.client.SendRequest(ctx, url, pending[0].request, nil)
+		pending[0].result <- batchResult{response: response, err: err}
+		return
+	}
+
+	requests := make([]jsonrpc.Request, len(pending))
+	for i := range pending {
+		requests[i] = pending[i].request
+	}
+	responses, err := batcher.client.SendBatch(ctx, url, requests)
+	for i := range pending {
+		if err != nil {
+			pending[i].result <- batchResult{err: err}
+			continue
+		}
+		pending[i].result <- batchResult{response: responses[i]}
+	}
+}
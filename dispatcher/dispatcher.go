@@ -5,8 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/renproject/aw/wire"
@@ -23,19 +22,60 @@ import (
 // client of the lightnode. The addresses of known darknodes are stored in a
 // store that is shared by the `Updater`, which will periodically update the
 // store so that the addresses of the known darkndoes are kept up to date.
+//
+// The `Dispatcher` tracks the health of every Darknode it sends requests to,
+// and prefers healthy, low-latency Darknodes when choosing where to send a
+// request. Darknodes which repeatedly fail are temporarily excluded.
+//
+// Depending on the route for the method, a failed request is retried on a
+// different Darknode, and a request which is slower than usual is hedged by
+// also sending it to a different Darknode.
+//
+// Requests which are sent to the same Darknode at around the same time, such as
+// the requests in a batch from a client, are forwarded as a single batch.
 type Dispatcher struct {
 	logger     logrus.FieldLogger
 	client     http.Client
+	batcher    *batcher
+	urls       http.URLResolver
 	multiStore store.MultiAddrStore
+	options    Options
+	health     *HealthTracker
+	latencies  *latencies
+
+	// epoch is the current epoch, if it is known.
+	epoch *EpochChange
+}
+
+// EpochChange is a message which tells the `Dispatcher` that the network has
+// entered a new epoch. Once it knows the epoch, the `Dispatcher` only sends
+// requests for the whole network to the Darknodes which are part of the epoch,
+// and derives quorums from the size of the epoch's shard.
+type EpochChange struct {
+	Number   uint64
+	NumNodes int
+	Shards   []string
 }
 
-// New constructs a new `Dispatcher`.
-func New(logger logrus.FieldLogger, timeout time.Duration, multiStore store.MultiAddrStore, opts phi.Options) phi.Task {
+// IsMessage implements the `phi.Message` interface.
+func (EpochChange) IsMessage() {}
+
+// New constructs a new `Dispatcher` which sends requests using the given
+// client. It panics if the routes in the options cannot be served.
+func New(logger logrus.FieldLogger, client http.Client, multiStore store.MultiAddrStore, options Options, opts phi.Options) phi.Task {
+	if err := options.Validate(); err != nil {
+		logger.Panicf("[dispatcher] %v", err)
+	}
 	return phi.New(
 		&Dispatcher{
 			logger:     logger,
-			client:     http.NewClient(timeout),
+			client:     client,
+			batcher:    newBatcher(client, options.BatchWindow, options.BatchSize),
+			urls:       options.URLs,
 			multiStore: multiStore,
+			options:    options,
+			health:     NewHealthTracker(options),
+			latencies:  newLatencies(),
 		},
 		opts,
 	)
@@ -43,18 +83,29 @@ func New(logger logrus.FieldLogger, timeout time.Duration, multiStore store.Mult
 
 // Handle implements the `phi.Handler` interface.
 func (dispatcher *Dispatcher) Handle(_ phi.Task, message phi.Message) {
-	msg, ok := message.(http.RequestWithResponder)
-	if !ok {
+	switch msg := message.(type) {
+	case http.RequestWithResponder:
+		dispatcher.handleRequest(msg)
+	case EpochChange:
+		dispatcher.logger.Infof("[dispatcher] following epoch %v with %v nodes", msg.Number, msg.NumNodes)
+		dispatcher.epoch = &msg
+	default:
 		dispatcher.logger.Panicf("[dispatcher] unexpected message type %T", message)
 	}
+}
+
+func (dispatcher *Dispatcher) handleRequest(msg http.RequestWithResponder) {
+	route := dispatcher.options.route(msg.Method)
 
 	var addrs []wire.Address
+	var spares *sparePool
 	var err error
 	id := msg.Query.Get("id")
 	if id != "" {
 		addrs, err = dispatcher.multiAddr(id)
+		spares = newSparePool(nil)
 	} else {
-		addrs, err = dispatcher.multiAddrs(msg.Method)
+		addrs, spares, err = dispatcher.multiAddrs(route)
 	}
 	if err != nil {
 		dispatcher.logger.Errorf("[dispatcher] sending %v request to [%v]: getting multi-address: %v", msg.Method, id, err)
@@ -62,36 +113,34 @@ func (dispatcher *Dispatcher) Handle(_ phi.Task, message phi.Message) {
 		return
 	}
 
+	params, err := json.Marshal(msg.Params)
+	if err != nil {
+		dispatcher.logger.Errorf("[dispatcher] invalid params=%v: %v", msg.Params, err)
+		msg.RespondWithErr(jsonrpc.ErrorCodeInvalidParams, err)
+		return
+	}
+	req := jsonrpc.Request{
+		Version: "2.0",
+		ID:      msg.ID,
+		Method:  msg.Method,
+		Params:  params,
+	}
+
+	resIter, err := dispatcher.newResponseIter(route.Strategy, len(addrs))
+	if err != nil {
+		dispatcher.logger.Errorf("[dispatcher] sending %v request: %v", msg.Method, err)
+		msg.RespondWithErr(jsonrpc.ErrorCodeInternal, err)
+		return
+	}
+
 	// Send the request to the darknodes and pipe the response to the iterator
 	ctx, cancel := context.WithCancel(msg.Context)
 	responses := make(chan jsonrpc.Response, len(addrs))
-	resIter := dispatcher.newResponseIter(msg.Method)
 
+	obs := newObservations()
 	go func() {
 		phi.ParForAll(addrs, func(i int) {
-			addrParts := strings.Split(addrs[i].Value, ":")
-			if len(addrParts) != 2 {
-				dispatcher.logger.Errorf("[dispatcher] invalid address value=%v: %v", addrs[i].Value, err)
-				return
-			}
-			port, err := strconv.Atoi(addrParts[1])
-			if err != nil {
-				dispatcher.logger.Errorf("[dispatcher] invalid port=%v: %v", addrParts[1], err)
-				return
-			}
-			addrString := fmt.Sprintf("http://%s:%v", addrParts[0], port+1)
-			params, err := json.Marshal(msg.Params)
-			if err != nil {
-				dispatcher.logger.Errorf("[dispatcher] invalid params=%v: %v", msg.Params, err)
-				return
-			}
-			req := jsonrpc.Request{
-				Version: "2.0",
-				ID:      msg.ID,
-				Method:  msg.Method,
-				Params:  params,
-			}
-			response, err := dispatcher.client.SendRequest(ctx, addrString, req, nil)
+			response, addr, err := dispatcher.sendWithRetries(ctx, addrs[i], spares, req, route)
 			if err != nil {
 				// The context will be cancelled as soon as the first response
 				// is received, so this error is not worth logging.
@@ -100,9 +149,14 @@ func (dispatcher *Dispatcher) Handle(_ phi.Task, message phi.Message) {
 				}
 				return
 			}
+			obs.add(addr, response)
 			responses <- response
 		})
 		close(responses)
+
+		if dispatcher.options.Divergences != nil {
+			dispatcher.options.Divergences.check(msg.Method, params, obs)
+		}
 	}()
 
 	go func() {
@@ -110,6 +164,119 @@ func (dispatcher *Dispatcher) Handle(_ phi.Task, message phi.Message) {
 	}()
 }
 
+// sendWithRetries sends the request to the Darknode, retrying on spare
+// Darknodes if the route allows it. It returns the response along with the
+// Darknode that returned it.
+func (dispatcher *Dispatcher) sendWithRetries(ctx context.Context, addr wire.Address, spares *sparePool, req jsonrpc.Request, route Route) (jsonrpc.Response, wire.Address, error) {
+	interval := time.Duration(0)
+	if route.Retry != nil {
+		interval = route.Retry.Base
+	}
+	for attempt := 1; ; attempt++ {
+		response, from, err := dispatcher.sendWithHedge(ctx, addr, spares, req, route)
+		if err == nil || errors.Is(err, context.Canceled) {
+			return response, from, err
+		}
+		if route.Retry == nil || (route.Retry.Attempts > 0 && attempt >= route.Retry.Attempts) {
+			return response, from, err
+		}
+		next, ok := spares.next()
+		if !ok {
+			return response, from, err
+		}
+		dispatcher.logger.Warnf("[dispatcher] retrying %v request on %v: %v", req.Method, next.Value, err)
+		addr = next
+
+		select {
+		case <-ctx.Done():
+			return jsonrpc.Response{}, addr, ctx.Err()
+		case <-time.After(interval):
+			interval = route.Retry.Next(interval)
+		}
+	}
+}
+
+// sendWithHedge sends the request to the Darknode. If the route allows it and
+// the Darknode has not responded after the configured percentile of recent
+// latencies, the request is also sent to a spare Darknode. The first
+// successful response is returned, along with the Darknode that returned it.
+func (dispatcher *Dispatcher) sendWithHedge(ctx context.Context, addr wire.Address, spares *sparePool, req jsonrpc.Request, route Route) (jsonrpc.Response, wire.Address, error) {
+	if route.Hedge == nil {
+		response, err := dispatcher.send(ctx, addr, req)
+		return response, addr, err
+	}
+	delay, ok := dispatcher.latencies.percentile(req.Method, route.Hedge.Percentile)
+	if !ok {
+		response, err := dispatcher.send(ctx, addr, req)
+		return response, addr, err
+	}
+	if delay < route.Hedge.MinDelay {
+		delay = route.Hedge.MinDelay
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		response jsonrpc.Response
+		addr     wire.Address
+		err      error
+	}
+	results := make(chan result, 2)
+	send := func(addr wire.Address) {
+		response, err := dispatcher.send(ctx, addr, req)
+		results <- result{response, addr, err}
+	}
+	go send(addr)
+	inflight := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case res := <-results:
+			inflight--
+			if res.err == nil || inflight == 0 {
+				return res.response, res.addr, res.err
+			}
+		case <-timer.C:
+			if hedge, ok := spares.next(); ok {
+				go send(hedge)
+				inflight++
+			}
+		}
+	}
+}
+
+// send the request to the Darknode once, and record the outcome in its
+// health.
+func (dispatcher *Dispatcher) send(ctx context.Context, addr wire.Address, req jsonrpc.Request) (jsonrpc.Response, error) {
+	addrString, err := dispatcher.urls.URL(addr)
+	if err != nil {
+		return jsonrpc.Response{}, err
+	}
+
+	start := time.Now()
+	var response jsonrpc.Response
+	if dispatcher.options.BatchWindow > 0 {
+		response, err = dispatcher.batcher.send(ctx, addrString, req)
+	} else {
+		response, err = dispatcher.client.SendRequest(ctx, addrString, req, nil)
+	}
+	if err != nil {
+		// A cancelled request says nothing about the health of the Darknode.
+		if !errors.Is(err, context.Canceled) {
+			dispatcher.health.Failure(addr, time.Now())
+		}
+		return jsonrpc.Response{}, err
+	}
+	latency := time.Since(start)
+	dispatcher.health.Success(addr, latency)
+	dispatcher.latencies.record(req.Method, latency)
+	return response, nil
+}
+
 // multiAddrs returns the multi-address for the given Darknode ID.
 func (dispatcher *Dispatcher) multiAddr(darknodeID string) ([]wire.Address, error) {
 	multi, err := dispatcher.multiStore.Get(darknodeID)
@@ -120,26 +287,106 @@ func (dispatcher *Dispatcher) multiAddr(darknodeID string) ([]wire.Address, erro
 }
 
 // multiAddrs returns the multi-addresses for the Darknodes based on the given
-// method.
-func (dispatcher *Dispatcher) multiAddrs(method string) ([]wire.Address, error) {
-	switch method {
-	case jsonrpc.MethodSubmitTx:
-		return dispatcher.multiStore.RandomBootstrapAddrs(3)
-	case jsonrpc.MethodQueryTx:
-		return dispatcher.multiStore.BootstrapAll()
-	case jsonrpc.MethodQueryStat:
-		return dispatcher.multiStore.RandomAddrs(3)
+// route, preferring healthy Darknodes, along with the remaining Darknodes in
+// the pool which can be used for retries and hedged requests.
+func (dispatcher *Dispatcher) multiAddrs(route Route) ([]wire.Address, *sparePool, error) {
+	var pool []wire.Address
+	var err error
+	switch route.Pool {
+	case PoolAll:
+		pool, err = dispatcher.addrsAll()
 	default:
-		return dispatcher.multiStore.RandomBootstrapAddrs(5)
+		pool, err = dispatcher.multiStore.BootstrapAll()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// When sending to the whole pool, Darknodes with an open circuit breaker
+	// are left out as there is nothing to replace them with.
+	n := route.FanOut
+	if n <= 0 {
+		pool = dispatcher.health.Available(pool, time.Now())
+		n = len(pool)
 	}
+	if n > len(pool) {
+		n = len(pool)
+	}
+	ordered := dispatcher.health.Select(pool, len(pool), time.Now())
+	return ordered[:n], newSparePool(ordered[n:]), nil
 }
 
-// newResponseIter returns the iterator type for the given method.
-func (dispatcher *Dispatcher) newResponseIter(method string) Iterator {
-	switch method {
-	case jsonrpc.MethodQueryTx:
-		return NewMajorityResponseIterator(dispatcher.logger)
+// newResponseIter returns the iterator for the given strategy. It returns an
+// error if the strategy requires a quorum which cannot be reached by the given
+// number of Darknodes. Retries and hedged requests do not count towards it as
+// they replace a response rather than adding one.
+func (dispatcher *Dispatcher) newResponseIter(strategy Strategy, available int) (Iterator, error) {
+	switch strategy {
+	case StrategyMajority:
+		return NewMajorityResponseIterator(dispatcher.logger), nil
+	case StrategyQuorum:
+		networkSize, err := dispatcher.networkSize()
+		if err != nil {
+			return nil, fmt.Errorf("getting network size: %v", err)
+		}
+		quorum := Quorum(networkSize)
+		if available < quorum {
+			return nil, fmt.Errorf("unable to reach quorum: %v darknodes available, %v required", available, quorum)
+		}
+		return NewQuorumResponseIterator(quorum), nil
 	default:
-		return NewFirstResponseIterator()
+		return NewFirstResponseIterator(), nil
+	}
+}
+
+// addrsAll returns the multi-addresses of the Darknodes in the current epoch,
+// or all known Darknodes if the epoch or its Darknodes are not known yet.
+func (dispatcher *Dispatcher) addrsAll() ([]wire.Address, error) {
+	if dispatcher.epoch != nil {
+		addrs, err := dispatcher.multiStore.AddrsInEpoch(dispatcher.epoch.Number)
+		if err == nil && len(addrs) > 0 {
+			return addrs, nil
+		}
+	}
+	return dispatcher.multiStore.AddrsAll()
+}
+
+// networkSize returns the number of Darknodes in the network, preferring the
+// configured size over the size of the current epoch's shard.
+func (dispatcher *Dispatcher) networkSize() (int, error) {
+	if dispatcher.options.NetworkSize > 0 {
+		return dispatcher.options.NetworkSize, nil
+	}
+	if dispatcher.epoch != nil && dispatcher.epoch.NumNodes > 0 {
+		return dispatcher.epoch.NumNodes, nil
+	}
+	return dispatcher.multiStore.Size()
+}
+
+// sparePool hands out Darknodes which have not yet been sent a request, so that
+// retries and hedged requests go to different Darknodes. It is safe for
+// concurrent use.
+type sparePool struct {
+	mu    *sync.Mutex
+	addrs []wire.Address
+}
+
+func newSparePool(addrs []wire.Address) *sparePool {
+	return &sparePool{
+		mu:    new(sync.Mutex),
+		addrs: addrs,
+	}
+}
+
+// next returns the next spare Darknode, and false if there are none left.
+func (pool *sparePool) next() (wire.Address, bool) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if len(pool.addrs) == 0 {
+		return wire.Address{}, false
 	}
+	addr := pool.addrs[0]
+	pool.addrs = pool.addrs[1:]
+	return addr, true
 }
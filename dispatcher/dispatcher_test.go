@@ -2,8 +2,12 @@ package dispatcher_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	nethttp "net/http"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -22,23 +26,27 @@ import (
 )
 
 func initDispatcher(ctx context.Context, bootstrapAddrs []wire.Address, timeout time.Duration) phi.Sender {
+	return initDispatcherWithOptions(ctx, bootstrapAddrs, timeout, dispatcher.DefaultOptions())
+}
+
+func initDispatcherWithOptions(ctx context.Context, bootstrapAddrs []wire.Address, timeout time.Duration, options dispatcher.Options) phi.Sender {
 	opts := phi.Options{Cap: 10}
 	logger := logrus.New()
 	table := kv.NewTable(kv.NewMemDB(kv.JSONCodec), "addresses")
 	multiStore := store.New(table, bootstrapAddrs)
-	dispatcher := dispatcher.New(logger, timeout, multiStore, opts)
+	dispatcher := dispatcher.New(logger, http.NewClient(timeout), multiStore, options, opts)
 
 	go dispatcher.Run(ctx)
 
 	return dispatcher
 }
 
-func initDarknodes(ctx context.Context, n int) []*MockDarknode {
+func initDarknodes(ctx context.Context, n, port int) []*MockDarknode {
 	dns := make([]*MockDarknode, n)
 	store := store.New(kv.NewTable(kv.NewMemDB(kv.JSONCodec), "multi"), nil)
 	for i := 0; i < n; i++ {
 		server := jsonrpc.NewServer(jsonrpc.DefaultOptions(), jsonrpcresolver.OkResponder(), jsonrpc.NewValidator())
-		url := fmt.Sprintf("0.0.0.0:%v", 3333+i)
+		url := fmt.Sprintf("0.0.0.0:%v", port+i)
 		go server.Listen(ctx, url)
 
 		dns[i] = NewMockDarknode(url, store)
@@ -46,13 +54,73 @@ func initDarknodes(ctx context.Context, n int) []*MockDarknode {
 	return dns
 }
 
+// initStaticDarknode starts a server which responds to every request with the
+// given result.
+func initStaticDarknode(ctx context.Context, port int, result string) {
+	server := &nethttp.Server{
+		Addr: fmt.Sprintf("0.0.0.0:%v", port),
+		Handler: nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			var req jsonrpc.Request
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(nethttp.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(jsonrpc.NewResponse(req.ID, json.RawMessage(result), nil))
+		}),
+	}
+	go server.ListenAndServe()
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+}
+
+// initBatchDarknode starts a server which responds to every request, including
+// the requests in a batch, with an empty result. It returns the number of HTTP
+// requests it has received.
+func initBatchDarknode(ctx context.Context, port int) *int64 {
+	calls := new(int64)
+	server := &nethttp.Server{
+		Addr: fmt.Sprintf("0.0.0.0:%v", port),
+		Handler: nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			atomic.AddInt64(calls, 1)
+			var raw json.RawMessage
+			if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+				w.WriteHeader(nethttp.StatusBadRequest)
+				return
+			}
+			var reqs []jsonrpc.Request
+			if err := json.Unmarshal(raw, &reqs); err != nil {
+				var req jsonrpc.Request
+				if err := json.Unmarshal(raw, &req); err != nil {
+					w.WriteHeader(nethttp.StatusBadRequest)
+					return
+				}
+				json.NewEncoder(w).Encode(jsonrpc.NewResponse(req.ID, json.RawMessage(`{}`), nil))
+				return
+			}
+			responses := make([]jsonrpc.Response, len(reqs))
+			for i := range reqs {
+				responses[len(reqs)-1-i] = jsonrpc.NewResponse(reqs[i].ID, json.RawMessage(`{}`), nil)
+			}
+			json.NewEncoder(w).Encode(responses)
+		}),
+	}
+	go server.ListenAndServe()
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	return calls
+}
+
 var _ = Describe("Dispatcher", func() {
 	Context("When running", func() {
 		It("Should send valid requests to the darknodes based on their policy", func() {
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 
-			darknodes := initDarknodes(ctx, 13)
+			darknodes := initDarknodes(ctx, 13, 3333)
 			multis := make([]wire.Address, 13)
 			for i := range multis {
 				multis[i] = darknodes[i].Me
@@ -70,4 +138,180 @@ var _ = Describe("Dispatcher", func() {
 			}
 		})
 	})
+
+	Context("When some of the darknodes are offline", func() {
+		It("Should retry the request on other darknodes", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Requests are sent to the port after the one in the address of a
+			// darknode, which is served by the next darknode.
+			darknodes := initDarknodes(ctx, 3, 3400)
+			multis := []wire.Address{darknodes[0].Me, darknodes[1].Me}
+			for i := 0; i < 3; i++ {
+				offline := wire.NewUnsignedAddress(wire.TCP, fmt.Sprintf("0.0.0.0:%v", 3410+2*i), uint64(time.Now().Unix()))
+				multis = append(multis, offline)
+			}
+			options := dispatcher.DefaultOptions().
+				WithFailureThreshold(0).
+				WithRoutes(map[string]dispatcher.Route{
+					jsonrpc.MethodSubmitTx: {
+						Pool:     dispatcher.PoolBootstrap,
+						FanOut:   3,
+						Strategy: dispatcher.StrategyFirst,
+						Retry:    &http.RetryOptions{Base: 10 * time.Millisecond, Max: 10 * time.Millisecond, Attempts: 3},
+					},
+				})
+			dispatcher := initDispatcherWithOptions(ctx, multis, time.Second, options)
+
+			for i := 0; i < 20; i++ {
+				id, params := ValidRequest(jsonrpc.MethodSubmitTx)
+				req := http.NewRequestWithResponder(ctx, id, jsonrpc.MethodSubmitTx, params, url.Values{})
+				Expect(dispatcher.Send(req)).To(BeTrue())
+
+				var response jsonrpc.Response
+				Eventually(req.Responder, 5*time.Second).Should(Receive(&response))
+				Expect(response.Error).Should(BeNil())
+			}
+		})
+	})
+
+	Context("When a route is configured for a method", func() {
+		It("Should combine the responses using the strategy of the route", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			darknodes := initDarknodes(ctx, 4, 3500)
+			multis := []wire.Address{darknodes[0].Me, darknodes[1].Me, darknodes[2].Me}
+			options := dispatcher.DefaultOptions().
+				WithRoutes(map[string]dispatcher.Route{
+					jsonrpc.MethodQueryBlockState: {
+						Pool:     dispatcher.PoolBootstrap,
+						FanOut:   0,
+						Strategy: dispatcher.StrategyMajority,
+					},
+				})
+			dispatcher := initDispatcherWithOptions(ctx, multis, time.Second, options)
+
+			id, params := ValidRequest(jsonrpc.MethodQueryBlockState)
+			req := http.NewRequestWithResponder(ctx, id, jsonrpc.MethodQueryBlockState, params, url.Values{})
+			Expect(dispatcher.Send(req)).To(BeTrue())
+
+			var response jsonrpc.Response
+			Eventually(req.Responder).Should(Receive(&response))
+			Expect(response.Error).Should(BeNil())
+		})
+
+		It("Should reject unknown pools and strategies", func() {
+			_, err := dispatcher.ParsePool("bootstrap")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = dispatcher.ParsePool("unknown")
+			Expect(err).To(HaveOccurred())
+			_, err = dispatcher.ParseStrategy("majority")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = dispatcher.ParseStrategy("unknown")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should reject quorum routes which cannot reach the quorum", func() {
+			route := dispatcher.Route{Pool: dispatcher.PoolAll, FanOut: 0, Strategy: dispatcher.StrategyQuorum}
+			options := dispatcher.DefaultOptions().WithRoutes(map[string]dispatcher.Route{jsonrpc.MethodQueryBlock: route})
+			Expect(options.Validate()).To(Succeed())
+
+			route.Pool = dispatcher.PoolBootstrap
+			options = options.WithRoutes(map[string]dispatcher.Route{jsonrpc.MethodQueryBlock: route})
+			Expect(options.Validate()).ToNot(Succeed())
+
+			route.Pool = dispatcher.PoolAll
+			route.FanOut = 5
+			options = options.WithRoutes(map[string]dispatcher.Route{jsonrpc.MethodQueryBlock: route})
+			Expect(options.Validate()).ToNot(Succeed())
+			Expect(options.WithNetworkSize(13).Validate()).ToNot(Succeed())
+			Expect(options.WithNetworkSize(7).Validate()).To(Succeed())
+		})
+	})
+
+	Context("When the darknodes return different responses", func() {
+		It("Should record the divergence", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Requests are sent to the port after the one in the address. The
+			// darknodes are split evenly so that the majority is never reached
+			// and every response is collected.
+			multis := make([]wire.Address, 4)
+			for i := range multis {
+				multis[i] = wire.NewUnsignedAddress(wire.TCP, fmt.Sprintf("0.0.0.0:%v", 3600+2*i), uint64(time.Now().Unix()))
+				initStaticDarknode(ctx, 3601+2*i, fmt.Sprintf(`{"height":%v}`, i%2))
+			}
+
+			divergences := dispatcher.NewDivergenceDetector(logrus.New(), dispatcher.DefaultDivergenceOptions())
+			options := dispatcher.DefaultOptions().
+				WithRoutes(map[string]dispatcher.Route{
+					jsonrpc.MethodQueryBlockState: {
+						Pool:     dispatcher.PoolBootstrap,
+						FanOut:   0,
+						Strategy: dispatcher.StrategyMajority,
+					},
+				}).
+				WithDivergences(divergences)
+			dispatcher := initDispatcherWithOptions(ctx, multis, time.Second, options)
+
+			id, params := ValidRequest(jsonrpc.MethodQueryBlockState)
+			req := http.NewRequestWithResponder(ctx, id, jsonrpc.MethodQueryBlockState, params, url.Values{})
+			Expect(dispatcher.Send(req)).To(BeTrue())
+
+			var response jsonrpc.Response
+			Eventually(req.Responder).Should(Receive(&response))
+			Expect(response.Error).Should(BeNil())
+
+			Eventually(func() uint64 {
+				return divergences.Stats().Divergences[jsonrpc.MethodQueryBlockState]
+			}).Should(Equal(uint64(1)))
+			recent := divergences.Recent(jsonrpc.MethodQueryBlockState)
+			Expect(recent).To(HaveLen(1))
+			Expect(recent[0].Groups).To(HaveLen(2))
+			for _, group := range recent[0].Groups {
+				Expect(group.Darknodes).To(HaveLen(2))
+			}
+			Expect(divergences.Stats().Minority).To(HaveLen(2))
+		})
+	})
+
+	Context("When requests are sent to the same darknode at the same time", func() {
+		It("Should forward them as a single batch", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Requests are sent to the port after the one in the address.
+			multis := []wire.Address{wire.NewUnsignedAddress(wire.TCP, "0.0.0.0:3700", uint64(time.Now().Unix()))}
+			calls := initBatchDarknode(ctx, 3701)
+
+			options := dispatcher.DefaultOptions().
+				WithBatchWindow(50 * time.Millisecond).
+				WithBatchSize(10)
+			dispatcher := initDispatcherWithOptions(ctx, multis, time.Second, options)
+
+			wg := new(sync.WaitGroup)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					// Every client uses the same ID.
+					_, params := ValidRequest(jsonrpc.MethodQueryTx)
+					req := http.NewRequestWithResponder(ctx, 1.0, jsonrpc.MethodQueryTx, params, url.Values{})
+					Expect(dispatcher.Send(req)).To(BeTrue())
+
+					var response jsonrpc.Response
+					Eventually(req.Responder).Should(Receive(&response))
+					Expect(response.Error).Should(BeNil())
+					Expect(response.ID).Should(Equal(1.0))
+				}(i)
+			}
+			wg.Wait()
+			Expect(atomic.LoadInt64(calls)).Should(BeNumerically("<", 10))
+		})
+	})
 })
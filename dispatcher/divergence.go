@@ -0,0 +1,299 @@
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/renproject/aw/wire"
+	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/lightnode/admin"
+	"github.com/sirupsen/logrus"
+)
+
+// Enumerate default divergence options.
+var (
+	DefaultDivergenceMaxRecent     = 100
+	DefaultDivergenceAlertURL      = ""
+	DefaultDivergenceAlertInterval = 10 * time.Minute
+)
+
+// DivergenceOptions configure how divergences are recorded and reported.
+type DivergenceOptions struct {
+	// MaxRecent is the number of recent divergences that are kept.
+	MaxRecent int
+	// AlertURL is a webhook that divergences are posted to as JSON. No alerts
+	// are sent if it is empty.
+	AlertURL string
+	// AlertInterval is the minimum time between two alerts for the same
+	// method.
+	AlertInterval time.Duration
+}
+
+// DefaultDivergenceOptions returns new divergence options with default
+// configurations that should work for the majority of use cases.
+func DefaultDivergenceOptions() DivergenceOptions {
+	return DivergenceOptions{
+		MaxRecent:     DefaultDivergenceMaxRecent,
+		AlertURL:      DefaultDivergenceAlertURL,
+		AlertInterval: DefaultDivergenceAlertInterval,
+	}
+}
+
+// WithMaxRecent returns new options with the given number of recent
+// divergences to keep.
+func (opts DivergenceOptions) WithMaxRecent(maxRecent int) DivergenceOptions {
+	opts.MaxRecent = maxRecent
+	return opts
+}
+
+// WithAlertURL returns new options with the given webhook for alerts.
+func (opts DivergenceOptions) WithAlertURL(alertURL string) DivergenceOptions {
+	opts.AlertURL = alertURL
+	return opts
+}
+
+// WithAlertInterval returns new options with the given minimum time between
+// alerts for the same method.
+func (opts DivergenceOptions) WithAlertInterval(alertInterval time.Duration) DivergenceOptions {
+	opts.AlertInterval = alertInterval
+	return opts
+}
+
+// DivergentGroup is a set of Darknodes which returned the same response.
+type DivergentGroup struct {
+	Darknodes []string         `json:"darknodes"`
+	Response  jsonrpc.Response `json:"response"`
+}
+
+// Divergence is a disagreement between the Darknodes that were sent the same
+// request. The groups are sorted from the largest to the smallest.
+type Divergence struct {
+	Time   time.Time        `json:"time"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+	Groups []DivergentGroup `json:"groups"`
+}
+
+// DivergenceStats count the divergences seen since the detector started.
+type DivergenceStats struct {
+	// Requests is the number of requests answered by more than one Darknode,
+	// by method.
+	Requests map[string]uint64 `json:"requests"`
+	// Divergences is the number of requests for which the Darknodes did not
+	// agree, by method.
+	Divergences map[string]uint64 `json:"divergences"`
+	// Minority is the number of times each Darknode was not in the largest
+	// group of a divergence.
+	Minority map[string]uint64 `json:"minority"`
+}
+
+// observation is a response along with the Darknode that returned it.
+type observation struct {
+	addr     wire.Address
+	response jsonrpc.Response
+}
+
+// observations collects the responses to a single request. It is safe for
+// concurrent use.
+type observations struct {
+	mu   *sync.Mutex
+	list []observation
+}
+
+func newObservations() *observations {
+	return &observations{mu: new(sync.Mutex)}
+}
+
+func (obs *observations) add(addr wire.Address, response jsonrpc.Response) {
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+
+	obs.list = append(obs.list, observation{addr: addr, response: response})
+}
+
+// A DivergenceDetector compares the responses returned by different Darknodes
+// for the same request, and records the requests for which they disagree, so
+// that misbehaving or out-of-sync Darknodes can be found. It is safe for
+// concurrent use.
+type DivergenceDetector struct {
+	logger  logrus.FieldLogger
+	options DivergenceOptions
+	client  *nethttp.Client
+
+	mu         *sync.Mutex
+	recent     []Divergence
+	stats      DivergenceStats
+	lastAlerts map[string]time.Time
+}
+
+// NewDivergenceDetector returns a new `DivergenceDetector`.
+func NewDivergenceDetector(logger logrus.FieldLogger, options DivergenceOptions) *DivergenceDetector {
+	return &DivergenceDetector{
+		logger:  logger,
+		options: options,
+		client:  &nethttp.Client{Timeout: 10 * time.Second},
+		mu:      new(sync.Mutex),
+		recent:  []Divergence{},
+		stats: DivergenceStats{
+			Requests:    map[string]uint64{},
+			Divergences: map[string]uint64{},
+			Minority:    map[string]uint64{},
+		},
+		lastAlerts: map[string]time.Time{},
+	}
+}
+
+// check the responses for a request, recording a divergence if they are not
+// all equal.
+func (detector *DivergenceDetector) check(method string, params json.RawMessage, obs *observations) {
+	obs.mu.Lock()
+	list := obs.list
+	obs.mu.Unlock()
+	if len(list) < 2 {
+		return
+	}
+
+	// Responses are compared the same way as in the `interfaceMap`, ignoring
+	// their IDs.
+	groups := []DivergentGroup{}
+	for _, o := range list {
+		response := o.response
+		response.ID = nil
+		found := false
+		for i := range groups {
+			if reflect.DeepEqual(groups[i].Response, response) {
+				groups[i].Darknodes = append(groups[i].Darknodes, o.addr.Value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, DivergentGroup{Darknodes: []string{o.addr.Value}, Response: response})
+		}
+	}
+
+	detector.mu.Lock()
+	detector.stats.Requests[method]++
+	if len(groups) == 1 {
+		detector.mu.Unlock()
+		return
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].Darknodes) > len(groups[j].Darknodes)
+	})
+	divergence := Divergence{
+		Time:   time.Now(),
+		Method: method,
+		Params: params,
+		Groups: groups,
+	}
+	detector.stats.Divergences[method]++
+	for _, group := range groups[1:] {
+		for _, darknode := range group.Darknodes {
+			detector.stats.Minority[darknode]++
+		}
+	}
+	detector.recent = append(detector.recent, divergence)
+	if len(detector.recent) > detector.options.MaxRecent {
+		detector.recent = detector.recent[len(detector.recent)-detector.options.MaxRecent:]
+	}
+	alert := detector.options.AlertURL != "" && divergence.Time.Sub(detector.lastAlerts[method]) >= detector.options.AlertInterval
+	if alert {
+		detector.lastAlerts[method] = divergence.Time
+	}
+	detector.mu.Unlock()
+
+	detector.logger.Warnf("[dispatcher] darknodes returned %v different responses to %v request", len(groups), method)
+	if alert {
+		go detector.alert(divergence)
+	}
+}
+
+// alert posts the divergence to the webhook.
+func (detector *DivergenceDetector) alert(divergence Divergence) {
+	body, err := json.Marshal(divergence)
+	if err != nil {
+		detector.logger.Errorf("[dispatcher] cannot marshal divergence: %v", err)
+		return
+	}
+	response, err := detector.client.Post(detector.options.AlertURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		detector.logger.Errorf("[dispatcher] cannot send divergence alert: %v", err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		detector.logger.Errorf("[dispatcher] cannot send divergence alert: status %v", response.StatusCode)
+	}
+}
+
+// Recent returns the most recent divergences for the given method, or for all
+// methods if the method is empty, from the newest to the oldest.
+func (detector *DivergenceDetector) Recent(method string) []Divergence {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	divergences := []Divergence{}
+	for i := len(detector.recent) - 1; i >= 0; i-- {
+		if method == "" || detector.recent[i].Method == method {
+			divergences = append(divergences, detector.recent[i])
+		}
+	}
+	return divergences
+}
+
+// Stats returns the divergence counters.
+func (detector *DivergenceDetector) Stats() DivergenceStats {
+	detector.mu.Lock()
+	defer detector.mu.Unlock()
+
+	stats := DivergenceStats{
+		Requests:    make(map[string]uint64, len(detector.stats.Requests)),
+		Divergences: make(map[string]uint64, len(detector.stats.Divergences)),
+		Minority:    make(map[string]uint64, len(detector.stats.Minority)),
+	}
+	for k, v := range detector.stats.Requests {
+		stats.Requests[k] = v
+	}
+	for k, v := range detector.stats.Divergences {
+		stats.Divergences[k] = v
+	}
+	for k, v := range detector.stats.Minority {
+		stats.Minority[k] = v
+	}
+	return stats
+}
+
+// Enumerate the admin methods served by the `DivergenceDetector`.
+const (
+	MethodDivergences     = "dispatcher_divergences"
+	MethodDivergenceStats = "dispatcher_divergenceStats"
+)
+
+// ParamsDivergences are the params of the `dispatcher_divergences` admin
+// method.
+type ParamsDivergences struct {
+	Method string `json:"method"`
+}
+
+// RegisterAdmin registers the admin methods for reporting divergences with the
+// admin server.
+func (detector *DivergenceDetector) RegisterAdmin(server *admin.Server) {
+	server.Register(MethodDivergences, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var params ParamsDivergences
+		if err := admin.UnmarshalParams(raw, &params); err != nil {
+			return nil, err
+		}
+		return detector.Recent(params.Method), nil
+	})
+	server.Register(MethodDivergenceStats, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		return detector.Stats(), nil
+	})
+}
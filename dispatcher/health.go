@@ -0,0 +1,175 @@
+package dispatcher
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/renproject/aw/wire"
+)
+
+// NodeHealth is the health of a Darknode as observed by the `Dispatcher`.
+type NodeHealth struct {
+	Successes           uint64        `json:"successes"`
+	Failures            uint64        `json:"failures"`
+	ConsecutiveFailures int           `json:"consecutiveFailures"`
+	Latency             time.Duration `json:"latency"`
+	OpenUntil           time.Time     `json:"openUntil"`
+}
+
+// SuccessRate returns the smoothed fraction of requests to the Darknode which
+// have succeeded. Darknodes without any requests have a success rate of one
+// half.
+func (health NodeHealth) SuccessRate() float64 {
+	return float64(health.Successes+1) / float64(health.Successes+health.Failures+2)
+}
+
+// Score returns how preferable the Darknode is, favouring a high success rate
+// and a low latency.
+func (health NodeHealth) Score() float64 {
+	return health.SuccessRate() / (1 + health.Latency.Seconds())
+}
+
+// Available returns whether the circuit breaker of the Darknode is closed at
+// the given time.
+func (health NodeHealth) Available(now time.Time) bool {
+	return !now.Before(health.OpenUntil)
+}
+
+// A HealthTracker records the outcome of requests to Darknodes, and uses them
+// to select healthy Darknodes. Darknodes which fail too many consecutive
+// requests have their circuit breaker opened, and are not selected until it
+// closes again. It is safe for concurrent use.
+type HealthTracker struct {
+	options Options
+
+	nodesMu *sync.RWMutex
+	nodes   map[string]NodeHealth
+}
+
+// NewHealthTracker returns a new `HealthTracker` without any observations.
+func NewHealthTracker(options Options) *HealthTracker {
+	return &HealthTracker{
+		options: options,
+		nodesMu: new(sync.RWMutex),
+		nodes:   map[string]NodeHealth{},
+	}
+}
+
+// Success records a successful request to the Darknode with the given latency.
+func (tracker *HealthTracker) Success(addr wire.Address, latency time.Duration) {
+	tracker.nodesMu.Lock()
+	defer tracker.nodesMu.Unlock()
+
+	health := tracker.nodes[addr.Value]
+	health.Successes++
+	health.ConsecutiveFailures = 0
+	health.OpenUntil = time.Time{}
+	if health.Latency == 0 {
+		health.Latency = latency
+	} else {
+		alpha := tracker.options.LatencyAlpha
+		health.Latency = time.Duration(alpha*float64(latency) + (1-alpha)*float64(health.Latency))
+	}
+	tracker.nodes[addr.Value] = health
+}
+
+// Failure records a failed request to the Darknode at the given time, opening
+// its circuit breaker if it has reached the failure threshold.
+func (tracker *HealthTracker) Failure(addr wire.Address, now time.Time) {
+	tracker.nodesMu.Lock()
+	defer tracker.nodesMu.Unlock()
+
+	health := tracker.nodes[addr.Value]
+	health.Failures++
+	health.ConsecutiveFailures++
+	if tracker.options.FailureThreshold > 0 && health.ConsecutiveFailures >= tracker.options.FailureThreshold {
+		health.OpenUntil = now.Add(tracker.options.BreakerCooldown)
+	}
+	tracker.nodes[addr.Value] = health
+}
+
+// Health returns the health of the Darknode.
+func (tracker *HealthTracker) Health(addr wire.Address) NodeHealth {
+	tracker.nodesMu.RLock()
+	defer tracker.nodesMu.RUnlock()
+
+	return tracker.nodes[addr.Value]
+}
+
+// Available returns the given Darknodes which have a closed circuit breaker. If
+// all of them are open, all of the Darknodes are returned.
+func (tracker *HealthTracker) Available(addrs []wire.Address, now time.Time) []wire.Address {
+	tracker.nodesMu.RLock()
+	defer tracker.nodesMu.RUnlock()
+
+	available := make([]wire.Address, 0, len(addrs))
+	for _, addr := range addrs {
+		if tracker.nodes[addr.Value].Available(now) {
+			available = append(available, addr)
+		}
+	}
+	if len(available) == 0 {
+		return addrs
+	}
+	return available
+}
+
+// Select returns up to n of the given Darknodes. Darknodes with a closed
+// circuit breaker are chosen at random, weighted by their score, so that
+// healthy low-latency Darknodes are preferred without sending all requests to
+// the same ones. If there are not enough of them, the Darknodes whose circuit
+// breakers close the soonest are used to make up the difference.
+func (tracker *HealthTracker) Select(addrs []wire.Address, n int, now time.Time) []wire.Address {
+	if n > len(addrs) {
+		n = len(addrs)
+	}
+
+	tracker.nodesMu.RLock()
+	available := make([]wire.Address, 0, len(addrs))
+	weights := make([]float64, 0, len(addrs))
+	unavailable := make([]wire.Address, 0)
+	for _, addr := range addrs {
+		health := tracker.nodes[addr.Value]
+		if health.Available(now) {
+			available = append(available, addr)
+			weights = append(weights, health.Score())
+		} else {
+			unavailable = append(unavailable, addr)
+		}
+	}
+	sort.SliceStable(unavailable, func(i, j int) bool {
+		return tracker.nodes[unavailable[i].Value].OpenUntil.Before(tracker.nodes[unavailable[j].Value].OpenUntil)
+	})
+	tracker.nodesMu.RUnlock()
+
+	selected := make([]wire.Address, 0, n)
+	for len(selected) < n && len(available) > 0 {
+		i := weightedIndex(weights)
+		selected = append(selected, available[i])
+		available = append(available[:i], available[i+1:]...)
+		weights = append(weights[:i], weights[i+1:]...)
+	}
+	for i := 0; len(selected) < n; i++ {
+		selected = append(selected, unavailable[i])
+	}
+	return selected
+}
+
+// weightedIndex returns a random index, where the probability of each index is
+// proportional to its weight.
+func weightedIndex(weights []float64) int {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+	r := rand.Float64() * total
+	for i, weight := range weights {
+		if r < weight {
+			return i
+		}
+		r -= weight
+	}
+	return len(weights) - 1
+}
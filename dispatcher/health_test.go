@@ -0,0 +1,86 @@
+package dispatcher_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/lightnode/dispatcher"
+
+	"github.com/renproject/aw/wire"
+)
+
+var _ = Describe("Health tracker", func() {
+	addrs := func(n int) []wire.Address {
+		addrs := make([]wire.Address, n)
+		for i := range addrs {
+			addrs[i] = wire.NewUnsignedAddress(wire.TCP, fmt.Sprintf("0.0.0.0:%v", 4000+i), uint64(time.Now().Unix()))
+		}
+		return addrs
+	}
+
+	Context("when a darknode fails consecutive requests", func() {
+		It("should open its circuit breaker after the failure threshold", func() {
+			tracker := NewHealthTracker(DefaultOptions().WithFailureThreshold(2).WithBreakerCooldown(time.Minute))
+			nodes := addrs(3)
+			now := time.Now()
+
+			tracker.Failure(nodes[0], now)
+			Expect(tracker.Health(nodes[0]).Available(now)).To(BeTrue())
+			tracker.Failure(nodes[0], now)
+			Expect(tracker.Health(nodes[0]).Available(now)).To(BeFalse())
+			Expect(tracker.Health(nodes[0]).Available(now.Add(2 * time.Minute))).To(BeTrue())
+
+			for i := 0; i < 100; i++ {
+				Expect(tracker.Select(nodes, 2, now)).ToNot(ContainElement(nodes[0]))
+			}
+			Expect(tracker.Available(nodes, now)).To(ConsistOf(nodes[1], nodes[2]))
+		})
+
+		It("should close its circuit breaker after a successful request", func() {
+			tracker := NewHealthTracker(DefaultOptions().WithFailureThreshold(1))
+			nodes := addrs(1)
+			now := time.Now()
+
+			tracker.Failure(nodes[0], now)
+			Expect(tracker.Health(nodes[0]).Available(now)).To(BeFalse())
+			tracker.Success(nodes[0], time.Millisecond)
+			Expect(tracker.Health(nodes[0]).Available(now)).To(BeTrue())
+			Expect(tracker.Health(nodes[0]).ConsecutiveFailures).To(Equal(0))
+		})
+	})
+
+	Context("when there are not enough healthy darknodes", func() {
+		It("should fill the selection with the darknodes that recover first", func() {
+			tracker := NewHealthTracker(DefaultOptions().WithFailureThreshold(1).WithBreakerCooldown(time.Minute))
+			nodes := addrs(3)
+			now := time.Now()
+
+			tracker.Failure(nodes[1], now)
+			tracker.Failure(nodes[2], now.Add(time.Second))
+
+			selected := tracker.Select(nodes, 2, now)
+			Expect(selected).To(ConsistOf(nodes[0], nodes[1]))
+		})
+	})
+
+	Context("when selecting darknodes", func() {
+		It("should prefer darknodes with a low latency", func() {
+			tracker := NewHealthTracker(DefaultOptions())
+			nodes := addrs(2)
+			for i := 0; i < 10; i++ {
+				tracker.Success(nodes[0], 10*time.Millisecond)
+				tracker.Success(nodes[1], 10*time.Second)
+			}
+
+			fast := 0
+			for i := 0; i < 1000; i++ {
+				if tracker.Select(nodes, 1, time.Now())[0] == nodes[0] {
+					fast++
+				}
+			}
+			Expect(fast).To(BeNumerically(">", 800))
+		})
+	})
+})
@@ -2,6 +2,7 @@ package dispatcher
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 
 	"github.com/renproject/darknode/jsonrpc"
@@ -84,6 +85,48 @@ func (iter majorityResponseIterator) Collect(id interface{}, cancel context.Canc
 	return most.(jsonrpc.Response)
 }
 
+// Quorum returns the number of Darknodes that must agree on a response in a
+// network of the given size, such that two conflicting responses cannot both
+// reach a quorum while at most a third of the network is faulty.
+func Quorum(networkSize int) int {
+	faults := (networkSize - 1) / 3
+	if faults < 0 {
+		faults = 0
+	}
+	return (networkSize+faults)/2 + 1
+}
+
+// quorumResponseIterator returns the response returned by a quorum of
+// Darknodes, and an error if the quorum is not reached.
+type quorumResponseIterator struct {
+	quorum    int
+	responses *interfaceMap
+}
+
+// NewQuorumResponseIterator returns a new quorumResponseIterator which requires
+// the given number of Darknodes to return the same response.
+func NewQuorumResponseIterator(quorum int) Iterator {
+	return quorumResponseIterator{
+		quorum: quorum,
+	}
+}
+
+// Collect implements the `Iterator` interface.
+func (iter quorumResponseIterator) Collect(id interface{}, cancel context.CancelFunc, responses <-chan jsonrpc.Response) jsonrpc.Response {
+	iter.responses = newInterfaceMap(cap(responses))
+	iter.responses.threshold = iter.quorum - 1
+	defer cancel()
+
+	for response := range responses {
+		if ok := iter.responses.store(response); ok {
+			return response
+		}
+	}
+
+	jsonErr := jsonrpc.NewError(jsonrpc.ErrorCodeInternal, fmt.Sprintf("unable to reach quorum: %v of %v darknodes agree", iter.responses.max(), iter.quorum), nil)
+	return jsonrpc.NewResponse(id, nil, &jsonErr)
+}
+
 // interfaceMap use to is a customized map for storing interface{}. It uses
 // reflect.Deepequal function to compare interface{}.
 type interfaceMap struct {
@@ -116,6 +159,17 @@ func (m *interfaceMap) store(key interface{}) bool {
 	return 1 > m.threshold
 }
 
+// max returns the highest counter, or zero if nothing has been stored.
+func (m *interfaceMap) max() int {
+	max := 0
+	for i := range m.counter {
+		if m.counter[i] > max {
+			max = m.counter[i]
+		}
+	}
+	return max
+}
+
 func (m *interfaceMap) most() interface{} {
 	if len(m.data) == 0 {
 		return nil
@@ -170,4 +170,59 @@ var _ = Describe("iterator", func() {
 			Expect(quick.Check(test, nil)).NotTo(HaveOccurred())
 		})
 	})
+	Context("quorum response iterator", func() {
+		It("should derive the quorum from the network size", func() {
+			Expect(Quorum(1)).Should(Equal(1))
+			Expect(Quorum(4)).Should(Equal(3))
+			Expect(Quorum(7)).Should(Equal(5))
+			Expect(Quorum(13)).Should(Equal(9))
+		})
+
+		It("should return the response once the quorum is reached", func() {
+			iter := NewQuorumResponseIterator(Quorum(13))
+
+			responses := make(chan jsonrpc.Response, 13)
+			ctx, cancel := context.WithCancel(context.Background())
+
+			// Simulate piping responses from Darknodes to the channel.
+			data, err := json.Marshal(0)
+			Expect(err).NotTo(HaveOccurred())
+			for i := 0; i < 13; i++ {
+				responses <- RandomResponse(true, data)
+			}
+			close(responses)
+
+			// Collect the response selected by the iterator.
+			res := iter.Collect(0.0, cancel, responses)
+			Expect(res.Error).Should(BeNil())
+			Expect(len(responses)).Should(Equal(13 - Quorum(13)))
+
+			// Ensure the context is canceled by the iterator.
+			_, ok := <-ctx.Done()
+			Expect(ok).Should(BeFalse())
+		})
+
+		It("should return an error instead of the most common response if the quorum is not reached", func() {
+			iter := NewQuorumResponseIterator(Quorum(13))
+
+			responses := make(chan jsonrpc.Response, 13)
+			ctx, cancel := context.WithCancel(context.Background())
+
+			// Only a simple majority of the Darknodes agree.
+			for i := 0; i < 13; i++ {
+				data, err := json.Marshal(i % 2)
+				Expect(err).NotTo(HaveOccurred())
+				responses <- RandomResponse(true, data)
+			}
+			close(responses)
+
+			// Collect the response selected by the iterator.
+			res := iter.Collect(0.0, cancel, responses)
+			Expect(res.Error).ShouldNot(BeNil())
+
+			// Ensure the context is canceled by the iterator.
+			_, ok := <-ctx.Done()
+			Expect(ok).Should(BeFalse())
+		})
+	})
 })
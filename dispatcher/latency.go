@@ -0,0 +1,64 @@
+package dispatcher
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencySamples is the number of recent latencies kept for each method.
+const latencySamples = 128
+
+// latencies records the most recent latencies of successful requests for each
+// method. It is safe for concurrent use.
+type latencies struct {
+	mu      *sync.Mutex
+	samples map[string][]time.Duration
+	next    map[string]int
+}
+
+func newLatencies() *latencies {
+	return &latencies{
+		mu:      new(sync.Mutex),
+		samples: map[string][]time.Duration{},
+		next:    map[string]int{},
+	}
+}
+
+// record a latency for the method, replacing the oldest one once there are
+// enough samples.
+func (l *latencies) record(method string, latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	samples := l.samples[method]
+	if len(samples) < latencySamples {
+		l.samples[method] = append(samples, latency)
+		return
+	}
+	samples[l.next[method]] = latency
+	l.next[method] = (l.next[method] + 1) % latencySamples
+}
+
+// percentile returns the given percentile, between 0 and 1, of the recent
+// latencies for the method, and false if there are none.
+func (l *latencies) percentile(method string, p float64) (time.Duration, bool) {
+	l.mu.Lock()
+	samples := make([]time.Duration, len(l.samples[method]))
+	copy(samples, l.samples[method])
+	l.mu.Unlock()
+
+	if len(samples) == 0 {
+		return 0, false
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	i := int(math.Ceil(p*float64(len(samples)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(samples) {
+		i = len(samples) - 1
+	}
+	return samples[i], true
+}
@@ -0,0 +1,295 @@
+package dispatcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/lightnode/http"
+)
+
+// Enumerate default options.
+var (
+	DefaultFailureThreshold = 3
+	DefaultBreakerCooldown  = 30 * time.Second
+	DefaultLatencyAlpha     = 0.2
+	DefaultNetworkSize      = 0
+	DefaultBatchWindow      = time.Duration(0)
+	DefaultBatchSize        = 10
+	DefaultRoute            = Route{
+		Pool:     PoolBootstrap,
+		FanOut:   5,
+		Strategy: StrategyFirst,
+		Retry: &http.RetryOptions{
+			Base:     100 * time.Millisecond,
+			Max:      time.Second,
+			Factor:   1.0,
+			Attempts: 2,
+		},
+		Hedge: &HedgeOptions{
+			Percentile: 0.95,
+			MinDelay:   100 * time.Millisecond,
+		},
+	}
+	DefaultRoutes = map[string]Route{
+		jsonrpc.MethodSubmitTx: {
+			Pool:     PoolBootstrap,
+			FanOut:   3,
+			Strategy: StrategyFirst,
+			Retry: &http.RetryOptions{
+				Base:     100 * time.Millisecond,
+				Max:      time.Second,
+				Factor:   1.0,
+				Attempts: 3,
+			},
+		},
+		jsonrpc.MethodQueryTx: {
+			Pool:     PoolBootstrap,
+			FanOut:   0,
+			Strategy: StrategyMajority,
+		},
+		jsonrpc.MethodQueryStat: {
+			Pool:     PoolAll,
+			FanOut:   3,
+			Strategy: StrategyFirst,
+			Retry:    DefaultRoute.Retry,
+			Hedge:    DefaultRoute.Hedge,
+		},
+	}
+)
+
+// Pool is a set of Darknodes that requests can be sent to.
+type Pool string
+
+// Enumerate the pools of Darknodes.
+const (
+	// PoolBootstrap is the set of Bootstrap nodes.
+	PoolBootstrap = Pool("bootstrap")
+	// PoolAll is the set of all known Darknodes.
+	PoolAll = Pool("all")
+)
+
+// Strategy is the way responses from multiple Darknodes are combined into a
+// single response.
+type Strategy string
+
+// Enumerate the strategies for combining responses.
+const (
+	// StrategyFirst returns the first successful response.
+	StrategyFirst = Strategy("first")
+	// StrategyMajority returns the response returned by a majority of the
+	// Darknodes.
+	StrategyMajority = Strategy("majority")
+	// StrategyQuorum returns the response returned by a BFT quorum of the
+	// network, and an error if the quorum cannot be reached. Unlike the
+	// majority, the quorum depends on the size of the network rather than the
+	// number of Darknodes that were sent the request.
+	StrategyQuorum = Strategy("quorum")
+)
+
+// ParsePool returns the pool with the given name.
+func ParsePool(name string) (Pool, error) {
+	switch pool := Pool(name); pool {
+	case PoolBootstrap, PoolAll:
+		return pool, nil
+	default:
+		return "", fmt.Errorf("unknown pool %v", name)
+	}
+}
+
+// ParseStrategy returns the strategy with the given name.
+func ParseStrategy(name string) (Strategy, error) {
+	switch strategy := Strategy(name); strategy {
+	case StrategyFirst, StrategyMajority, StrategyQuorum:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown strategy %v", name)
+	}
+}
+
+// HedgeOptions are used for hedging requests that are slow to respond, by
+// sending them to another Darknode without cancelling the first attempt.
+type HedgeOptions struct {
+	// Percentile, between 0 and 1, of the recent latencies for the method
+	// after which the request is sent to another Darknode.
+	Percentile float64
+	// MinDelay is the minimum time to wait before hedging a request.
+	MinDelay time.Duration
+}
+
+// Route describes how requests for a method are sent to the Darknodes. A
+// request is sent to `FanOut` Darknodes from the `Pool`, or all of them if the
+// fan-out is zero, and their responses are combined using the `Strategy`.
+// Retries and hedged requests are always sent to Darknodes in the pool which
+// have not yet been sent the request. A nil `Retry` or `Hedge` disables
+// retrying or hedging.
+type Route struct {
+	Pool     Pool
+	FanOut   int
+	Strategy Strategy
+	Retry    *http.RetryOptions
+	Hedge    *HedgeOptions
+}
+
+// Options to configure the precise behaviour of the dispatcher.
+type Options struct {
+	// FailureThreshold is the number of consecutive failed requests after
+	// which a Darknode is excluded from selection. A zero value disables
+	// circuit breaking.
+	FailureThreshold int
+	// BreakerCooldown is how long a Darknode is excluded from selection once
+	// it has reached the failure threshold. After the cooldown, the Darknode is
+	// tried again and excluded immediately if it fails.
+	BreakerCooldown time.Duration
+	// LatencyAlpha is the smoothing factor of the exponentially weighted moving
+	// average of the latency of each Darknode.
+	LatencyAlpha float64
+	// Routes overrides the route for specific methods.
+	Routes map[string]Route
+	// DefaultRoute is the route for methods which are not in the routes.
+	DefaultRoute Route
+	// URLs resolves the multi-addresses of the Darknodes to the URLs that
+	// requests are sent to.
+	URLs http.URLResolver
+	// BatchWindow is how long a request waits for other requests to the same
+	// Darknode, so that they can be sent as a single batch. A zero value
+	// disables batching, which is the default.
+	BatchWindow time.Duration
+	// BatchSize is the maximum number of requests in a batch. A batch is sent
+	// as soon as it is full.
+	BatchSize int
+	// NetworkSize is the number of Darknodes in the network, from which the
+	// quorum is derived. A zero value means the size of the current epoch's
+	// shard if it is known, or the number of Darknodes in the store.
+	NetworkSize int
+	// Divergences records disagreements between the Darknodes that respond to
+	// the same request. A nil detector disables divergence detection.
+	Divergences *DivergenceDetector
+}
+
+// DefaultOptions returns new options with default configurations that should
+// work for the majority of use cases.
+func DefaultOptions() Options {
+	routes := make(map[string]Route, len(DefaultRoutes))
+	for method, route := range DefaultRoutes {
+		routes[method] = route
+	}
+	return Options{
+		FailureThreshold: DefaultFailureThreshold,
+		BreakerCooldown:  DefaultBreakerCooldown,
+		LatencyAlpha:     DefaultLatencyAlpha,
+		Routes:           routes,
+		DefaultRoute:     DefaultRoute,
+		URLs:             http.NewURLResolver(http.DefaultURLOptions()),
+		NetworkSize:      DefaultNetworkSize,
+		BatchWindow:      DefaultBatchWindow,
+		BatchSize:        DefaultBatchSize,
+	}
+}
+
+// WithFailureThreshold returns new options with the given failure threshold.
+func (opts Options) WithFailureThreshold(failureThreshold int) Options {
+	opts.FailureThreshold = failureThreshold
+	return opts
+}
+
+// WithBreakerCooldown returns new options with the given breaker cooldown.
+func (opts Options) WithBreakerCooldown(breakerCooldown time.Duration) Options {
+	opts.BreakerCooldown = breakerCooldown
+	return opts
+}
+
+// WithLatencyAlpha returns new options with the given latency smoothing
+// factor.
+func (opts Options) WithLatencyAlpha(latencyAlpha float64) Options {
+	opts.LatencyAlpha = latencyAlpha
+	return opts
+}
+
+// WithRoutes returns new options with the given per-method routes. Methods not
+// present in the routes use the default route.
+func (opts Options) WithRoutes(routes map[string]Route) Options {
+	opts.Routes = routes
+	return opts
+}
+
+// WithDefaultRoute returns new options with the given default route.
+func (opts Options) WithDefaultRoute(route Route) Options {
+	opts.DefaultRoute = route
+	return opts
+}
+
+// WithURLs returns new options with the given resolver for the URLs of the
+// Darknodes.
+func (opts Options) WithURLs(urls http.URLResolver) Options {
+	opts.URLs = urls
+	return opts
+}
+
+// WithBatchWindow returns new options with the given batching window.
+func (opts Options) WithBatchWindow(batchWindow time.Duration) Options {
+	opts.BatchWindow = batchWindow
+	return opts
+}
+
+// WithBatchSize returns new options with the given maximum batch size.
+func (opts Options) WithBatchSize(batchSize int) Options {
+	opts.BatchSize = batchSize
+	return opts
+}
+
+// WithNetworkSize returns new options with the given network size for deriving
+// the quorum.
+func (opts Options) WithNetworkSize(networkSize int) Options {
+	opts.NetworkSize = networkSize
+	return opts
+}
+
+// WithDivergences returns new options with the given divergence detector.
+func (opts Options) WithDivergences(divergences *DivergenceDetector) Options {
+	opts.Divergences = divergences
+	return opts
+}
+
+// Validate returns an error if the routes cannot be served. The quorum is
+// derived from the size of the whole network, so a quorum route must send
+// requests to all Darknodes, and must not fan out to fewer Darknodes than the
+// quorum.
+func (opts Options) Validate() error {
+	if err := opts.validateRoute(opts.DefaultRoute); err != nil {
+		return fmt.Errorf("invalid default route: %v", err)
+	}
+	for method, route := range opts.Routes {
+		if err := opts.validateRoute(route); err != nil {
+			return fmt.Errorf("invalid route for %v: %v", method, err)
+		}
+	}
+	return nil
+}
+
+func (opts Options) validateRoute(route Route) error {
+	if route.Strategy != StrategyQuorum {
+		return nil
+	}
+	if route.Pool != PoolAll {
+		return fmt.Errorf("%v strategy requires the %v pool, got %v", StrategyQuorum, PoolAll, route.Pool)
+	}
+	if route.FanOut <= 0 {
+		return nil
+	}
+	if opts.NetworkSize <= 0 {
+		return fmt.Errorf("%v strategy with a fan-out of %v requires a network size", StrategyQuorum, route.FanOut)
+	}
+	if quorum := Quorum(opts.NetworkSize); route.FanOut < quorum {
+		return fmt.Errorf("fan-out of %v is below the quorum of %v", route.FanOut, quorum)
+	}
+	return nil
+}
+
+// route returns the route for the given method.
+func (opts Options) route(method string) Route {
+	if route, ok := opts.Routes[method]; ok {
+		return route
+	}
+	return opts.DefaultRoute
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"time"
 
@@ -13,32 +15,56 @@ import (
 
 // RetryOptions are used for retrying failed requests sent using the client.
 type RetryOptions struct {
-	Base   time.Duration // Time interval before first retry.
-	Max    time.Duration // Maximum time interval between two retries.
-	Factor float64       // next_interval = previous_interval * (1 + factor)
+	Base     time.Duration // Time interval before first retry.
+	Max      time.Duration // Maximum time interval between two retries.
+	Factor   float64       // next_interval = previous_interval * (1 + factor)
+	Attempts int           // Maximum number of attempts, or zero for no limit.
 }
 
 // DefaultRetryOptions are the recommended retry settings.
 var DefaultRetryOptions = RetryOptions{
-	Base:   time.Second,
-	Max:    5 * time.Second,
-	Factor: 0.2,
+	Base:     time.Second,
+	Max:      5 * time.Second,
+	Factor:   0.2,
+	Attempts: 0,
+}
+
+// Next returns the interval to wait before the retry following one which
+// waited for the given interval.
+func (options RetryOptions) Next(interval time.Duration) time.Duration {
+	interval = time.Duration(float64(interval) * (1 + options.Factor))
+	if interval > options.Max {
+		interval = options.Max
+	}
+	return interval
 }
 
 // DefaultClientTimeout is the recommended timeout for the client.
 var DefaultClientTimeout = 5 * time.Second
 
-// Client is a http.Client with a fixed timeout.
+// Client is a http.Client with a fixed timeout. Clients are safe for
+// concurrent use and should be shared, so that connections to the same
+// Darknodes are reused.
 type Client struct {
 	*http.Client
+	maxResponseSize int64
 }
 
-// NewClient returns a new client with the given timeout.
+// NewClient returns a new client with the given timeout and otherwise default
+// options.
 func NewClient(timeout time.Duration) Client {
+	return NewClientWithOptions(DefaultClientOptions().WithTimeout(timeout))
+}
+
+// NewClientWithOptions returns a new client with its own connection pool
+// configured by the given options.
+func NewClientWithOptions(options ClientOptions) Client {
 	return Client{
 		Client: &http.Client{
-			Timeout: timeout,
+			Timeout:   options.Timeout,
+			Transport: NewTransport(options),
 		},
+		maxResponseSize: options.MaxResponseSize,
 	}
 }
 
@@ -65,34 +91,129 @@ func (c Client) SendRequest(ctx context.Context, url string, request jsonrpc.Req
 	return c.retry(ctx, r, options)
 }
 
+// SendBatch sends the requests to the given URL as a single JSON-RPC batch. The
+// requests must have distinct IDs. The responses are returned in the same order
+// as the requests, and a request without a response in the batch gets an error
+// response.
+func (c Client) SendBatch(ctx context.Context, url string, requests []jsonrpc.Request) ([]jsonrpc.Response, error) {
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return nil, fmt.Errorf("[client] could not marshal batch: %v", err)
+	}
+	r, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("[client] could not create http request: %v", err)
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Content-Type", "application/json")
+
+	var raw json.RawMessage
+	if err := c.do(r, &raw); err != nil {
+		return nil, err
+	}
+
+	// A server which rejects the batch as a whole returns a single response.
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '{' {
+		var response jsonrpc.Response
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return nil, fmt.Errorf("[client] could not decode batch response: %v", err)
+		}
+		if response.Error != nil {
+			return nil, fmt.Errorf("[client] batch rejected: %v", response.Error.Message)
+		}
+		return nil, fmt.Errorf("[client] batch rejected")
+	}
+	var batch []jsonrpc.Response
+	if err := json.Unmarshal(raw, &batch); err != nil {
+		return nil, fmt.Errorf("[client] could not decode batch response: %v", err)
+	}
+
+	// Match the responses to the requests using their IDs, which may have
+	// been decoded into a different type.
+	byID := make(map[string]jsonrpc.Response, len(batch))
+	for _, response := range batch {
+		id, err := json.Marshal(response.ID)
+		if err != nil {
+			continue
+		}
+		byID[string(id)] = response
+	}
+	responses := make([]jsonrpc.Response, len(requests))
+	for i, request := range requests {
+		id, err := json.Marshal(request.ID)
+		if err != nil {
+			return nil, fmt.Errorf("[client] could not marshal request id: %v", err)
+		}
+		response, ok := byID[string(id)]
+		if !ok {
+			jsonErr := jsonrpc.NewError(jsonrpc.ErrorCodeInternal, "no response in batch", nil)
+			response = jsonrpc.NewResponse(request.ID, nil, &jsonErr)
+		}
+		responses[i] = response
+	}
+	return responses, nil
+}
+
 // send the request without retrying.
 func (c Client) send(r *http.Request) (jsonrpc.Response, error) {
+	var resp jsonrpc.Response
+	err := c.do(r, &resp)
+	return resp, err
+}
+
+// maxDrain is the maximum number of unread bytes discarded from a response
+// body so that its connection can be reused.
+const maxDrain = 64 << 10
+
+// do sends the request and decodes the response body into the given value.
+func (c Client) do(r *http.Request, v interface{}) error {
 	response, err := c.Do(r)
 	if err != nil {
-		return jsonrpc.Response{}, err
+		return err
 	}
-	defer response.Body.Close()
-	var resp jsonrpc.Response
-	err = json.NewDecoder(response.Body).Decode(&resp)
-	return resp, err
+	defer func() {
+		io.Copy(ioutil.Discard, io.LimitReader(response.Body, maxDrain))
+		response.Body.Close()
+	}()
+
+	if c.maxResponseSize <= 0 {
+		return json.NewDecoder(response.Body).Decode(v)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, c.maxResponseSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > c.maxResponseSize {
+		return fmt.Errorf("[client] response exceeds %v bytes", c.maxResponseSize)
+	}
+	return json.Unmarshal(body, v)
 }
 
 // send the request with the given retry options.
 func (c Client) retry(ctx context.Context, r *http.Request, options *RetryOptions) (jsonrpc.Response, error) {
 	interval := options.Base
-	for {
+	for attempt := 1; ; attempt++ {
+		// The body is consumed by each attempt, so it must be reset.
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return jsonrpc.Response{}, fmt.Errorf("[client] could not reset http request body: %v", err)
+			}
+			r.Body = body
+		}
 		response, err := c.send(r)
 		if err == nil {
 			return response, err
 		}
+		if options.Attempts > 0 && attempt >= options.Attempts {
+			return jsonrpc.Response{}, err
+		}
 		select {
 		case <-ctx.Done():
 			return jsonrpc.Response{}, fmt.Errorf("%v, last error = %v", ctx.Err(), err)
 		case <-time.After(interval):
-			interval = time.Duration(float64(interval) * (1 + options.Factor))
-			if interval > options.Max {
-				interval = options.Max
-			}
+			interval = options.Next(interval)
 		}
 	}
 }
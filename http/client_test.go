@@ -2,6 +2,8 @@ package http_test
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
 	"net/http/httptest"
 	"testing/quick"
 	"time"
@@ -66,5 +68,56 @@ var _ = Describe("Client", func() {
 			_, err := client.SendRequest(ctx, "http://0.0.0.0:12315", request, &retryOpts)
 			Expect(err).Should(HaveOccurred())
 		})
+
+		It("should reject responses larger than the maximum response size", func() {
+			reqChan := make(chan jsonrpc.Request, 2)
+			server := httptest.NewServer(SimpleHandler(true, reqChan))
+			defer server.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			request := RandomRequest(RandomMethod())
+
+			client := NewClientWithOptions(DefaultClientOptions().WithMaxResponseSize(8))
+			_, err := client.SendRequest(ctx, server.URL, request, nil)
+			Expect(err).Should(HaveOccurred())
+
+			client = NewClientWithOptions(DefaultClientOptions())
+			_, err = client.SendRequest(ctx, server.URL, request, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should match the responses of a batch to its requests", func() {
+			// The server responds to the requests in the reverse order.
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var requests []jsonrpc.Request
+				if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				responses := make([]jsonrpc.Response, 0, len(requests))
+				for i := len(requests) - 1; i >= 0; i-- {
+					responses = append(responses, jsonrpc.NewResponse(requests[i].ID, requests[i].Method, nil))
+				}
+				json.NewEncoder(w).Encode(responses)
+			}))
+			defer server.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			requests := BatchRequest(5)
+			for i := range requests {
+				requests[i].ID = float64(i)
+			}
+
+			client := NewClient(DefaultClientTimeout)
+			responses, err := client.SendBatch(ctx, server.URL, requests)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(responses).To(HaveLen(len(requests)))
+			for i := range responses {
+				Expect(responses[i].ID).To(Equal(requests[i].ID))
+				Expect(responses[i].Result).To(Equal(requests[i].Method))
+			}
+		})
 	})
 })
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Enumerate default client options.
+var (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 32
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultKeepAlive           = 30 * time.Second
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultHTTP2               = true
+	DefaultMaxResponseSize     = int64(32 * 1024 * 1024)
+	DefaultGzip                = true
+)
+
+// ClientOptions configure a client and the pool of connections it keeps to
+// the Darknodes.
+type ClientOptions struct {
+	// Timeout is the time limit for a request, including reading the response.
+	Timeout time.Duration
+	// MaxIdleConns is the maximum number of idle connections across all hosts.
+	// A zero value means no limit.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept to a
+	// single host.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept before being
+	// closed.
+	IdleConnTimeout time.Duration
+	// KeepAlive is the interval between TCP keep-alive probes. A negative
+	// value disables keep-alive probes.
+	KeepAlive time.Duration
+	// DialTimeout is the time limit for establishing a connection.
+	DialTimeout time.Duration
+	// HTTP2 enables HTTP/2 for Darknodes served over HTTPS.
+	HTTP2 bool
+	// MaxResponseSize is the maximum size of a response body in bytes. A zero
+	// value means no limit.
+	MaxResponseSize int64
+	// Gzip requests compressed responses and transparently decompresses them.
+	Gzip bool
+	// TLS is the TLS configuration for Darknodes served over HTTPS. A nil
+	// configuration uses the system defaults.
+	TLS *tls.Config
+}
+
+// DefaultClientOptions returns new client options with default configurations
+// that should work for the majority of use cases.
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		Timeout:             DefaultClientTimeout,
+		MaxIdleConns:        DefaultMaxIdleConns,
+		MaxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     DefaultIdleConnTimeout,
+		KeepAlive:           DefaultKeepAlive,
+		DialTimeout:         DefaultDialTimeout,
+		HTTP2:               DefaultHTTP2,
+		MaxResponseSize:     DefaultMaxResponseSize,
+		Gzip:                DefaultGzip,
+	}
+}
+
+// WithTimeout returns new options with the given request timeout.
+func (opts ClientOptions) WithTimeout(timeout time.Duration) ClientOptions {
+	opts.Timeout = timeout
+	return opts
+}
+
+// WithMaxIdleConns returns new options with the given maximum number of idle
+// connections.
+func (opts ClientOptions) WithMaxIdleConns(maxIdleConns int) ClientOptions {
+	opts.MaxIdleConns = maxIdleConns
+	return opts
+}
+
+// WithMaxIdleConnsPerHost returns new options with the given maximum number of
+// idle connections per host.
+func (opts ClientOptions) WithMaxIdleConnsPerHost(maxIdleConnsPerHost int) ClientOptions {
+	opts.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	return opts
+}
+
+// WithIdleConnTimeout returns new options with the given idle connection
+// timeout.
+func (opts ClientOptions) WithIdleConnTimeout(idleConnTimeout time.Duration) ClientOptions {
+	opts.IdleConnTimeout = idleConnTimeout
+	return opts
+}
+
+// WithKeepAlive returns new options with the given keep-alive interval.
+func (opts ClientOptions) WithKeepAlive(keepAlive time.Duration) ClientOptions {
+	opts.KeepAlive = keepAlive
+	return opts
+}
+
+// WithDialTimeout returns new options with the given dial timeout.
+func (opts ClientOptions) WithDialTimeout(dialTimeout time.Duration) ClientOptions {
+	opts.DialTimeout = dialTimeout
+	return opts
+}
+
+// WithHTTP2 returns new options with HTTP/2 enabled or disabled.
+func (opts ClientOptions) WithHTTP2(http2 bool) ClientOptions {
+	opts.HTTP2 = http2
+	return opts
+}
+
+// WithMaxResponseSize returns new options with the given maximum response
+// size.
+func (opts ClientOptions) WithMaxResponseSize(maxResponseSize int64) ClientOptions {
+	opts.MaxResponseSize = maxResponseSize
+	return opts
+}
+
+// WithGzip returns new options with compressed responses enabled or disabled.
+func (opts ClientOptions) WithGzip(gzip bool) ClientOptions {
+	opts.Gzip = gzip
+	return opts
+}
+
+// WithTLS returns new options with the given TLS configuration.
+func (opts ClientOptions) WithTLS(config *tls.Config) ClientOptions {
+	opts.TLS = config
+	return opts
+}
+
+// NewTransport returns a new transport with a connection pool configured by
+// the given options.
+func NewTransport(options ClientOptions) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   options.DialTimeout,
+		KeepAlive: options.KeepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     options.HTTP2,
+		MaxIdleConns:          options.MaxIdleConns,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		IdleConnTimeout:       options.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		DisableCompression:    !options.Gzip,
+		TLSClientConfig:       options.TLS,
+	}
+	if !options.HTTP2 {
+		// A non-nil map disables the automatic upgrade to HTTP/2.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+
+	"github.com/renproject/aw/wire"
+)
+
+// Enumerate default URL options.
+var (
+	DefaultURLScheme     = "http"
+	DefaultURLPortOffset = 1
+)
+
+// URLOptions configure how the JSON-RPC URL of a Darknode is derived from its
+// multi-address. By default, the URL is `http://host:(port+1)`.
+type URLOptions struct {
+	// Scheme is the scheme of the URL, either "http" or "https".
+	Scheme string
+	// PortOffset is added to the port of the multi-address to get the port of
+	// the URL, unless the port is in the port map.
+	PortOffset int
+	// Ports maps the port of a multi-address to the port of the URL.
+	Ports map[int]int
+	// Overrides maps the ID or the "host:port" value of a multi-address to the
+	// full URL of the Darknode, for Darknodes behind a reverse proxy.
+	Overrides map[string]string
+	// TLS is the TLS configuration used when connecting to Darknodes over
+	// HTTPS. A nil configuration uses the system defaults.
+	TLS *tls.Config
+}
+
+// DefaultURLOptions returns new URL options which follow the convention of the
+// JSON-RPC server of a Darknode listening on the port after its P2P port.
+func DefaultURLOptions() URLOptions {
+	return URLOptions{
+		Scheme:     DefaultURLScheme,
+		PortOffset: DefaultURLPortOffset,
+		Ports:      map[int]int{},
+		Overrides:  map[string]string{},
+	}
+}
+
+// WithScheme returns new options with the given URL scheme.
+func (opts URLOptions) WithScheme(scheme string) URLOptions {
+	opts.Scheme = scheme
+	return opts
+}
+
+// WithPortOffset returns new options with the given port offset.
+func (opts URLOptions) WithPortOffset(offset int) URLOptions {
+	opts.PortOffset = offset
+	return opts
+}
+
+// WithPorts returns new options with the given port mapping.
+func (opts URLOptions) WithPorts(ports map[int]int) URLOptions {
+	opts.Ports = ports
+	return opts
+}
+
+// WithOverrides returns new options with the given per-Darknode URLs.
+func (opts URLOptions) WithOverrides(overrides map[string]string) URLOptions {
+	opts.Overrides = overrides
+	return opts
+}
+
+// WithTLS returns new options with the given TLS configuration.
+func (opts URLOptions) WithTLS(config *tls.Config) URLOptions {
+	opts.TLS = config
+	return opts
+}
+
+// URLResolver resolves the multi-address of a Darknode to the URL of its
+// JSON-RPC server. Every component that sends requests to Darknodes should use
+// it rather than building URLs itself.
+type URLResolver struct {
+	options URLOptions
+}
+
+// NewURLResolver returns a new `URLResolver`.
+func NewURLResolver(options URLOptions) URLResolver {
+	return URLResolver{options: options}
+}
+
+// URL returns the JSON-RPC URL of the Darknode with the given multi-address.
+func (resolver URLResolver) URL(addr wire.Address) (string, error) {
+	if url, ok := resolver.options.Overrides[addr.Value]; ok {
+		return url, nil
+	}
+	if signatory, err := addr.Signatory(); err == nil {
+		if url, ok := resolver.options.Overrides[signatory.String()]; ok {
+			return url, nil
+		}
+	}
+
+	host, portString, err := net.SplitHostPort(addr.Value)
+	if err != nil {
+		return "", fmt.Errorf("invalid address value=%v: %v", addr.Value, err)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return "", fmt.Errorf("invalid port=%v: %v", portString, err)
+	}
+	if mapped, ok := resolver.options.Ports[port]; ok {
+		port = mapped
+	} else {
+		port += resolver.options.PortOffset
+	}
+	return fmt.Sprintf("%v://%v", resolver.options.Scheme, net.JoinHostPort(host, strconv.Itoa(port))), nil
+}
+
+// TLS returns the TLS configuration for connecting to the Darknodes.
+func (resolver URLResolver) TLS() *tls.Config {
+	return resolver.options.TLS
+}
+
+// LoadCABundle returns a TLS configuration which trusts the PEM encoded
+// certificates in the file at the given path, in addition to the system
+// certificates.
+func LoadCABundle(path string) (*tls.Config, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ca bundle: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in ca bundle %v", path)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
@@ -0,0 +1,64 @@
+package http_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/renproject/lightnode/http"
+
+	"github.com/renproject/aw/wire"
+)
+
+var _ = Describe("URL resolver", func() {
+	addr := func(value string) wire.Address {
+		return wire.NewUnsignedAddress(wire.TCP, value, uint64(time.Now().Unix()))
+	}
+
+	Context("when using the default options", func() {
+		It("should use the port after the p2p port", func() {
+			resolver := NewURLResolver(DefaultURLOptions())
+			url, err := resolver.URL(addr("127.0.0.1:18514"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(url).To(Equal("http://127.0.0.1:18515"))
+		})
+
+		It("should return an error for invalid addresses", func() {
+			resolver := NewURLResolver(DefaultURLOptions())
+			_, err := resolver.URL(addr("127.0.0.1"))
+			Expect(err).To(HaveOccurred())
+			_, err = resolver.URL(addr("127.0.0.1:port"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when the scheme and ports are configured", func() {
+		It("should map the ports before applying the offset", func() {
+			resolver := NewURLResolver(
+				DefaultURLOptions().
+					WithScheme("https").
+					WithPortOffset(0).
+					WithPorts(map[int]int{18514: 443}),
+			)
+			url, err := resolver.URL(addr("darknode.renproject.io:18514"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(url).To(Equal("https://darknode.renproject.io:443"))
+
+			url, err = resolver.URL(addr("darknode.renproject.io:8080"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(url).To(Equal("https://darknode.renproject.io:8080"))
+		})
+	})
+
+	Context("when a darknode has an override", func() {
+		It("should use the override", func() {
+			resolver := NewURLResolver(
+				DefaultURLOptions().
+					WithOverrides(map[string]string{"127.0.0.1:18514": "https://proxy.renproject.io/darknode"}),
+			)
+			url, err := resolver.URL(addr("127.0.0.1:18514"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(url).To(Equal("https://proxy.renproject.io/darknode"))
+		})
+	})
+})
@@ -4,40 +4,48 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"reflect"
 
 	"github.com/go-redis/redis/v7"
 	"github.com/renproject/darknode/binding"
 	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/darknode/tx"
 	"github.com/renproject/kv"
+	"github.com/renproject/lightnode/admin"
 	"github.com/renproject/lightnode/cacher"
 	v0 "github.com/renproject/lightnode/compat/v0"
 	v1 "github.com/renproject/lightnode/compat/v1"
+	"github.com/renproject/lightnode/config"
 	"github.com/renproject/lightnode/confirmer"
 	"github.com/renproject/lightnode/db"
 	"github.com/renproject/lightnode/dispatcher"
+	"github.com/renproject/lightnode/http"
 	"github.com/renproject/lightnode/resolver"
 	"github.com/renproject/lightnode/store"
 	"github.com/renproject/lightnode/updater"
 	"github.com/renproject/lightnode/watcher"
 	"github.com/renproject/multichain"
+	"github.com/renproject/pack"
 	"github.com/renproject/phi"
 	"github.com/sirupsen/logrus"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-
-	solanaRPC "github.com/dfuse-io/solana-go/rpc"
 )
 
 // Lightnode is the top level container that encapsulates the functionality of
 // the lightnode.
 type Lightnode struct {
-	options   Options
-	logger    logrus.FieldLogger
-	db        db.DB
-	server    *jsonrpc.Server
-	updater   updater.Updater
-	confirmer confirmer.Confirmer
-	watchers  map[multichain.Chain]map[multichain.Asset]watcher.Watcher
+	options    Options
+	logger     logrus.FieldLogger
+	db         db.DB
+	server     *jsonrpc.Server
+	updater    updater.Updater
+	confirmer  confirmer.Confirmer
+	watchers   *watcher.Manager
+	newWatcher watcher.Factory
+	refresher  *config.Refresher
+	prefetcher cacher.Prefetcher
+	admin      *admin.Server
 
 	// Tasks
 	cacher     phi.Task
@@ -78,7 +86,10 @@ func New(options Options, ctx context.Context, logger logrus.FieldLogger, sqlDB
 
 	// Initialise the multi-address store.
 	table := kv.NewTable(kv.NewMemDB(kv.JSONCodec), "addresses")
-	multiStore := store.New(table, options.BootstrapAddrs)
+	multiStore, err := store.NewPersistent(table, options.BootstrapAddrs, db)
+	if err != nil {
+		logger.Panicf("failed to initialise multi-address store: %v", err)
+	}
 
 	// Initialise the blockchain adapter.
 	loggerConfig := zap.NewProductionConfig()
@@ -91,13 +102,7 @@ func New(options Options, ctx context.Context, logger logrus.FieldLogger, sqlDB
 	if err != nil {
 		panic(fmt.Errorf("cannot init logger: %v", err))
 	}
-	bindingsOpts := binding.DefaultOptions().
-		WithLogger(bindingsLogger).
-		WithNetwork(options.Network)
-	for chain, chainOpts := range options.Chains {
-		bindingsOpts = bindingsOpts.WithChainOptions(chain, chainOpts)
-	}
-	bindings := binding.New(bindingsOpts)
+	bindings := newBindings(bindingsLogger, options.Network, options.Chains)
 
 	// ==== BEGIN GROSS HACK
 	//
@@ -107,40 +112,87 @@ func New(options Options, ctx context.Context, logger logrus.FieldLogger, sqlDB
 	// confirmations.
 	//
 
-	verifierBindingsOpts := binding.DefaultOptions().
-		WithLogger(bindingsLogger).
-		WithNetwork(options.Network)
-	for chain, chainOpts := range options.Chains {
-		chainOpts.Confirmations = 0
-		verifierBindingsOpts = verifierBindingsOpts.WithChainOptions(chain, chainOpts)
-	}
-	verifierBindings := binding.New(verifierBindingsOpts)
+	verifierBindings := newVerifierBindings(bindingsLogger, options.Network, options.Chains)
 
 	// ==== END GROSS HACK
 	//
 
-	updater := updater.New(logger, multiStore, options.UpdaterPollRate, options.ClientTimeout)
-	dispatcher := dispatcher.New(logger, options.ClientTimeout, multiStore, opts)
-	ttlCache := kv.NewTTLCache(ctx, kv.NewMemDB(kv.JSONCodec), "cacher", options.TTL)
-	cacher := cacher.New(dispatcher, logger, ttlCache, opts, db)
+	// Both sets of bindings are rebuilt when the network changes the options
+	// of a chain, so watchers created afterwards read them from here.
+	currentBindings := config.NewSwappableBindings(bindings)
+	currentVerifierBindings := config.NewSwappableBindings(verifierBindings)
 
-	versionStore := v0.NewCompatStore(db, client, options.TransactionExpiry)
-	gpubkeyStore := v1.NewCompatStore(client)
-	hostChains := map[multichain.Chain]bool{}
-	for _, selector := range options.Whitelist {
-		if selector.IsLock() && selector.IsMint() {
-			hostChains[selector.Destination()] = true
+	urls := http.NewURLResolver(options.DarknodeURLs)
+	darknodeClient := http.NewClientWithOptions(
+		options.DarknodeClient.
+			WithTimeout(options.ClientTimeout).
+			WithTLS(urls.TLS()),
+	)
+	divergences := dispatcher.NewDivergenceDetector(
+		logger,
+		dispatcher.DefaultDivergenceOptions().
+			WithAlertURL(options.DivergenceAlertURL).
+			WithAlertInterval(options.DivergenceAlertInterval),
+	)
+	dispatcherOpts := dispatcher.DefaultOptions().
+		WithFailureThreshold(options.DispatcherFailureThreshold).
+		WithBreakerCooldown(options.DispatcherBreakerCooldown).
+		WithRoutes(options.DispatcherRoutes).
+		WithURLs(urls).
+		WithNetworkSize(options.DispatcherNetworkSize).
+		WithBatchWindow(options.DispatcherBatchWindow).
+		WithBatchSize(options.DispatcherBatchSize).
+		WithDivergences(divergences)
+	dispatcher := dispatcher.New(logger, darknodeClient, multiStore, dispatcherOpts, opts)
+	updaterOpts := updater.DefaultOptions().
+		WithPollRate(options.UpdaterPollRate).
+		WithProbeInterval(options.UpdaterProbeInterval).
+		WithMaxFailures(options.UpdaterMaxFailures)
+	updater := updater.New(logger, multiStore, darknodeClient, urls, dispatcher, updaterOpts)
+	prefetch := make([]cacher.PrefetchRequest, 0, len(options.CachePrefetch))
+	for _, method := range options.CachePrefetch {
+		req, err := cacher.NewPrefetchRequest(method)
+		if err != nil {
+			logger.Panicf("invalid prefetch method: %v", err)
 		}
+		prefetch = append(prefetch, req)
 	}
-	verifier := resolver.NewVerifier(hostChains, verifierBindings)
-	resolverI := resolver.New(options.Network, logger, cacher, multiStore, db, serverOptions, versionStore, gpubkeyStore, bindings, verifier)
+	cacheTable := kv.NewTable(kv.NewMemDB(kv.JSONCodec), "cacher")
+	cacherOpts := cacher.DefaultOptions().
+		WithTTL(options.TTL).
+		WithMethodTTLs(options.CacheTTLs).
+		WithNotFoundTTL(options.CacheNotFoundTTL).
+		WithCacheFinalTxs(options.CacheFinalTxs).
+		WithServeStale(options.CacheServeStale).
+		WithMaxStaleness(options.CacheMaxStaleness).
+		WithPrefetch(prefetch).
+		WithPrefetchInterval(options.CachePrefetchInterval).
+		WithMaxEntries(options.CacheMaxEntries).
+		WithMaxBytes(options.CacheMaxBytes)
+	cacherTask := cacher.New(dispatcher, logger, cacheTable, cacherOpts, opts, db)
+	prefetcher := cacher.NewPrefetcher(logger, cacherTask, cacherOpts.Prefetch, cacherOpts.PrefetchInterval)
+
+	adminServer := admin.New(
+		admin.DefaultOptions().
+			WithLogger(logger).
+			WithPort(options.AdminPort).
+			WithToken(options.AdminToken),
+	)
+	cacher.RegisterAdmin(adminServer, cacherTask)
+	divergences.RegisterAdmin(adminServer)
+
+	versionStore := v0.NewCompatStore(db, client, options.TransactionExpiry)
+	gpubkeyStore := v1.NewCompatStore(client)
+	verifier := resolver.NewSwappableVerifier(resolver.NewVerifier(hostChains(options.Whitelist), verifierBindings))
+	resolverI := resolver.New(options.Network, logger, cacherTask, multiStore, db, serverOptions, versionStore, gpubkeyStore, bindings, verifier)
 	limiter := resolver.NewRateLimiter(resolver.RateLimiterConf{
 		GlobalMethodRate: options.LimiterGlobalRates,
 		IpMethodRate:     options.LimiterIPRates,
 		Ttl:              options.LimiterTTL,
 		MaxClients:       options.LimiterMaxClients,
 	})
-	server := jsonrpc.NewServer(serverOptions, resolverI, resolver.NewValidator(options.Network, verifierBindings, options.DistPubKey, versionStore, gpubkeyStore, &limiter, logger))
+	validator := resolver.NewValidator(options.Network, verifierBindings, options.DistPubKey, versionStore, gpubkeyStore, &limiter, logger)
+	server := jsonrpc.NewServer(serverOptions, resolverI, validator)
 	confirmer := confirmer.New(
 		confirmer.DefaultOptions().
 			WithLogger(logger).
@@ -150,36 +202,97 @@ func New(options Options, ctx context.Context, logger logrus.FieldLogger, sqlDB
 		db,
 		bindings,
 	)
+	rpcs := newWatcherRPCs(options)
+	confirmer.SetFallbacks(newConfirmerFallbacks(bindingsLogger, options.Network, options.Chains, rpcs))
 
-	watchers := map[multichain.Chain]map[multichain.Asset]watcher.Watcher{}
-	solClient := solanaRPC.NewClient(bindingsOpts.Chains[multichain.Solana].RPC.String())
-	for _, selector := range options.Whitelist {
-		if !selector.IsBurn() || !selector.IsRelease() {
-			continue
-		}
+	registry := watcher.DefaultRegistry()
+	newWatcher := func(selector tx.Selector) (watcher.Watcher, error) {
 		chain := selector.Source()
 		asset := selector.Asset()
-		if watchers[chain] == nil {
-			watchers[chain] = map[multichain.Asset]watcher.Watcher{}
+		family, err := registry.Family(chain)
+		if err != nil {
+			return watcher.Watcher{}, err
 		}
-		if _, ok := watchers[chain][asset]; ok {
-			continue
+		gateway := string(currentBindings.Get().ContractGateway(chain, asset))
+		if gateway == "" {
+			return watcher.Watcher{}, fmt.Errorf("no gateway for %v on %v", asset, chain)
 		}
-		var burnLogFetcher watcher.BurnLogFetcher
-		var blockHeightFetcher watcher.BlockHeightFetcher
-		if chain == multichain.Solana {
-			if bindings.ContractGateway(chain, asset) == "" {
-				continue
+
+		// The endpoint in the chain options is always the first endpoint.
+		urls := append([]string{options.Chains[chain].RPC.String()}, rpcs.urls[chain]...)
+		burnLogFetchers := make([]watcher.BurnLogFetcher, 0, len(urls))
+		blockHeightFetchers := make([]watcher.BlockHeightFetcher, 0, len(urls))
+		for _, url := range urls {
+			burnLogFetcher, blockHeightFetcher, err := family.NewFetchers(url, gateway)
+			if err != nil {
+				return watcher.Watcher{}, fmt.Errorf("connecting to %v: %v", chain, err)
 			}
-			burnLogFetcher = watcher.NewSolFetcher(solClient, string(bindings.ContractGateway(chain, asset)))
-			blockHeightFetcher = watcher.NewSolFetcher(solClient, string(bindings.ContractGateway(chain, asset)))
-		} else {
-			burnLogFetcher = watcher.NewEthBurnLogFetcher(bindings.EthereumGateway(chain, asset))
-			blockHeightFetcher = watcher.NewEthBlockHeightFetcher(bindings.EthereumClient(chain))
+			burnLogFetchers = append(burnLogFetchers, burnLogFetcher)
+			blockHeightFetchers = append(blockHeightFetchers, blockHeightFetcher)
+		}
+		burnLogFetcher, blockHeightFetcher := burnLogFetchers[0], blockHeightFetchers[0]
+		if endpoints, ok := rpcs.endpoints[chain]; ok && len(burnLogFetchers) > 1 {
+			burnLogFetcher = watcher.NewFailoverBurnLogFetcher(endpoints, burnLogFetchers)
+			blockHeightFetcher = watcher.NewFailoverBlockHeightFetcher(endpoints, blockHeightFetchers)
+		}
+
+		// Chains whose burns are counted by nonce cannot be reorganised in
+		// the same way, so they do not need a confidence interval.
+		confidenceInterval, ok := options.WatcherConfidenceIntervals[chain]
+		if !ok {
+			confidenceInterval = options.WatcherConfidenceInterval
+		}
+		if family.Indexed {
+			confidenceInterval = 0
 		}
-		watchers[chain][selector.Asset()] = watcher.NewWatcher(logger, options.Network, selector, verifierBindings, burnLogFetcher, blockHeightFetcher, resolverI, client, options.WatcherPollRate, options.WatcherMaxBlockAdvance, options.WatcherConfidenceInterval)
-		logger.Info("watching", selector)
+		w := watcher.NewWatcher(logger, options.Network, selector, currentVerifierBindings.Get(), burnLogFetcher, blockHeightFetcher, resolverI, client, db, options.WatcherPollRate, options.WatcherMaxBlockAdvance, confidenceInterval)
+		return w.
+			WithBackpressure(watcher.MaxAttempts(options.WatcherMaxAttempts)).
+			WithMappingExpiry(options.TransactionExpiry), nil
 	}
+	watchers := watcher.NewManager(logger, newWatcher)
+	watchers.RegisterAdmin(adminServer)
+	watchers.SetWhitelist(options.Whitelist)
+
+	// Keep the whitelist, confirmations and distributed public key up to date
+	// with the network.
+	refresher := config.NewRefresher(
+		logger,
+		darknodeClient,
+		urls,
+		options.BootstrapAddrs,
+		config.Config{
+			Whitelist:  options.Whitelist,
+			Chains:     options.Chains,
+			DistPubKey: options.DistPubKey,
+		},
+		config.DefaultOptions().
+			WithRefreshInterval(options.ConfigRefreshInterval).
+			WithStaticWhitelist(options.StaticWhitelist).
+			WithThreshold(options.ConfigThreshold),
+	)
+	refresher.Subscribe(func(old, new config.Config) {
+		if new.DistPubKey != nil {
+			validator.SetPubKey(new.DistPubKey)
+		}
+		chainsChanged := !reflect.DeepEqual(old.Chains, new.Chains)
+		if chainsChanged {
+			bindings := newBindings(bindingsLogger, options.Network, new.Chains)
+			currentBindings.Swap(bindings)
+			resolverI.SetBindings(bindings)
+			confirmer.SetBindings(bindings)
+			confirmer.SetFallbacks(newConfirmerFallbacks(bindingsLogger, options.Network, new.Chains, rpcs))
+
+			verifierBindings := newVerifierBindings(bindingsLogger, options.Network, new.Chains)
+			currentVerifierBindings.Swap(verifierBindings)
+			validator.SetBindings(verifierBindings)
+			watchers.SetBindings(verifierBindings)
+		}
+		if chainsChanged || !reflect.DeepEqual(old.Whitelist, new.Whitelist) {
+			verifier.Swap(resolver.NewVerifier(hostChains(new.Whitelist), currentVerifierBindings.Get()))
+		}
+		watchers.SetWhitelist(new.Whitelist)
+	})
 
 	return Lightnode{
 		options:    options,
@@ -187,10 +300,14 @@ func New(options Options, ctx context.Context, logger logrus.FieldLogger, sqlDB
 		db:         db,
 		updater:    updater,
 		dispatcher: dispatcher,
-		cacher:     cacher,
+		cacher:     cacherTask,
 		server:     server,
 		confirmer:  confirmer,
 		watchers:   watchers,
+		newWatcher: newWatcher,
+		refresher:  refresher,
+		prefetcher: prefetcher,
+		admin:      adminServer,
 	}
 }
 
@@ -198,15 +315,124 @@ func New(options Options, ctx context.Context, logger logrus.FieldLogger, sqlDB
 func (lightnode Lightnode) Run(ctx context.Context) {
 	go lightnode.updater.Run(ctx)
 	go lightnode.cacher.Run(ctx)
+	go lightnode.prefetcher.Run(ctx)
 	go lightnode.dispatcher.Run(ctx)
+	go lightnode.admin.Run(ctx)
+	go lightnode.refresher.Run(ctx)
 
 	// Note: the following should be disabled when running locally.
 	go lightnode.confirmer.Run(ctx)
-	for _, assetMap := range lightnode.watchers {
-		for _, watcher := range assetMap {
-			go watcher.Run(ctx)
+	go lightnode.watchers.Run(ctx)
+
+	lightnode.server.Listen(ctx, fmt.Sprintf(":%s", lightnode.options.Port))
+}
+
+// Backfill replays the burns of the given selector between the given heights
+// without running the server or the watchers. Unless it is a dry run, the
+// burns which have not been forwarded yet are submitted to the Darknodes.
+func (lightnode Lightnode) Backfill(ctx context.Context, selector tx.Selector, from, to uint64, dryRun bool) ([]watcher.BackfillResult, error) {
+	w, err := lightnode.newWatcher(selector)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		go lightnode.cacher.Run(ctx)
+		go lightnode.dispatcher.Run(ctx)
+	}
+	return w.Backfill(ctx, from, to, dryRun)
+}
+
+// watcherRPCs are the fallback RPC endpoints of each chain, along with the
+// health of all the endpoints of the chain, which is shared by every watcher on
+// the chain.
+type watcherRPCs struct {
+	urls      map[multichain.Chain][]string
+	endpoints map[multichain.Chain]*watcher.Endpoints
+}
+
+// newWatcherRPCs returns the fallback RPC endpoints in the options. The
+// endpoint in the chain options is always the first endpoint of a chain.
+func newWatcherRPCs(options Options) watcherRPCs {
+	rpcs := watcherRPCs{
+		urls:      map[multichain.Chain][]string{},
+		endpoints: map[multichain.Chain]*watcher.Endpoints{},
+	}
+	for chain, urls := range options.WatcherRPCs {
+		if len(urls) == 0 {
+			continue
+		}
+		for _, url := range urls {
+			rpcs.urls[chain] = append(rpcs.urls[chain], url.String())
+		}
+
+		n := len(urls) + 1
+		quorum := options.WatcherRPCQuorum
+		if quorum > n {
+			quorum = n
 		}
+		rpcs.endpoints[chain] = watcher.NewEndpoints(n, watcher.DefaultFailoverOptions().
+			WithMaxFailures(options.WatcherRPCMaxFailures).
+			WithBackoff(options.WatcherRPCBackoff).
+			WithQuorum(quorum))
 	}
+	return rpcs
+}
 
-	lightnode.server.Listen(ctx, fmt.Sprintf(":%s", lightnode.options.Port))
+// newConfirmerFallbacks returns the bindings of the fallback RPC endpoints of
+// each chain, so that the confirmer fails over between the same endpoints as
+// the watchers. Each binding only connects to the chain of its endpoint.
+func newConfirmerFallbacks(logger *zap.Logger, network multichain.Network, chains map[multichain.Chain]binding.ChainOptions, rpcs watcherRPCs) map[multichain.Chain]confirmer.Fallbacks {
+	fallbacks := map[multichain.Chain]confirmer.Fallbacks{}
+	for chain, urls := range rpcs.urls {
+		chainOpts, ok := chains[chain]
+		if !ok {
+			continue
+		}
+		bindings := make([]binding.Bindings, 0, len(urls))
+		for _, url := range urls {
+			chainOpts.RPC = pack.String(url)
+			bindings = append(bindings, newBindings(logger, network, map[multichain.Chain]binding.ChainOptions{chain: chainOpts}))
+		}
+		fallbacks[chain] = confirmer.Fallbacks{
+			Endpoints: rpcs.endpoints[chain],
+			Bindings:  bindings,
+		}
+	}
+	return fallbacks
+}
+
+// newVerifierBindings returns the bindings used to verify transactions, which
+// require no confirmations so that the initial verification succeeds even if
+// the transaction has not received any confirmations.
+func newVerifierBindings(logger *zap.Logger, network multichain.Network, chains map[multichain.Chain]binding.ChainOptions) binding.Bindings {
+	verifierChains := make(map[multichain.Chain]binding.ChainOptions, len(chains))
+	for chain, chainOpts := range chains {
+		chainOpts.Confirmations = 0
+		verifierChains[chain] = chainOpts
+	}
+	return newBindings(logger, network, verifierChains)
+}
+
+// newBindings returns the bindings for the given chains, which are used to
+// check the confirmations of txs and to query the chains.
+func newBindings(logger *zap.Logger, network multichain.Network, chains map[multichain.Chain]binding.ChainOptions) binding.Bindings {
+	bindingsOpts := binding.DefaultOptions().
+		WithLogger(logger).
+		WithNetwork(network)
+	for chain, chainOpts := range chains {
+		bindingsOpts = bindingsOpts.WithChainOptions(chain, chainOpts)
+	}
+	return binding.New(bindingsOpts)
+}
+
+// hostChains returns the chains which are the destination of a lock and mint
+// selector in the whitelist.
+func hostChains(whitelist []tx.Selector) map[multichain.Chain]bool {
+	chains := map[multichain.Chain]bool{}
+	for _, selector := range whitelist {
+		if selector.IsLock() && selector.IsMint() {
+			chains[selector.Destination()] = true
+		}
+	}
+	return chains
 }
@@ -7,85 +7,189 @@ import (
 	"github.com/renproject/darknode/binding"
 	"github.com/renproject/darknode/tx"
 	"github.com/renproject/id"
+	"github.com/renproject/lightnode/admin"
+	"github.com/renproject/lightnode/cacher"
+	"github.com/renproject/lightnode/config"
 	"github.com/renproject/lightnode/confirmer"
+	"github.com/renproject/lightnode/dispatcher"
+	"github.com/renproject/lightnode/http"
 	"github.com/renproject/lightnode/resolver"
+	"github.com/renproject/lightnode/updater"
+	"github.com/renproject/lightnode/watcher"
 	"github.com/renproject/multichain"
+	"github.com/renproject/pack"
 	"golang.org/x/time/rate"
 )
 
 // Enumerate default options.
 var (
-	DefaultPort                      = "5000"
-	DefaultCap                       = 128
-	DefaultMaxBatchSize              = 10
-	DefaultMaxPageSize               = 10
-	DefaultMaxGatewayCount           = 10000
-	DefaultServerTimeout             = 15 * time.Second
-	DefaultClientTimeout             = 15 * time.Second
-	DefaultTTL                       = 3 * time.Second
-	DefaultUpdaterPollRate           = 5 * time.Minute
-	DefaultConfirmerPollRate         = confirmer.DefaultPollInterval
-	DefaultWatcherPollRate           = 15 * time.Second
-	DefaultWatcherMaxBlockAdvance    = uint64(1000)
-	DefaultWatcherConfidenceInterval = uint64(6)
-	DefaultTransactionExpiry         = confirmer.DefaultExpiry
-	DefaultBootstrapAddrs            = []wire.Address{}
-	DefaultLimiterIPRates            = map[string]rate.Limit{"fallback": resolver.LimiterDefaultIPRate}
-	DefaultLimiterGlobalRates        = map[string]rate.Limit{"fallback": resolver.LimiterDefaultGlobalRate}
-	DefaultLimiterTTL                = resolver.LimiterDefaultTTL
-	DefaultLimiterMaxClients         = resolver.LimiterDefaultMaxClients
+	DefaultPort                       = "5000"
+	DefaultCap                        = 128
+	DefaultMaxBatchSize               = 10
+	DefaultMaxPageSize                = 10
+	DefaultMaxGatewayCount            = 10000
+	DefaultServerTimeout              = 15 * time.Second
+	DefaultClientTimeout              = 15 * time.Second
+	DefaultTTL                        = cacher.DefaultTTL
+	DefaultCacheTTLs                  = cacher.DefaultOptions().MethodTTLs
+	DefaultCacheNotFoundTTL           = cacher.DefaultNotFoundTTL
+	DefaultCacheFinalTxs              = cacher.DefaultCacheFinalTxs
+	DefaultCacheServeStale            = cacher.DefaultOptions().ServeStale
+	DefaultCacheMaxStaleness          = cacher.DefaultMaxStaleness
+	DefaultCachePrefetch              = []string{}
+	DefaultCachePrefetchInterval      = cacher.DefaultPrefetchInterval
+	DefaultCacheMaxEntries            = cacher.DefaultMaxEntries
+	DefaultCacheMaxBytes              = cacher.DefaultMaxBytes
+	DefaultDispatcherFailureThreshold = dispatcher.DefaultFailureThreshold
+	DefaultDispatcherBreakerCooldown  = dispatcher.DefaultBreakerCooldown
+	DefaultDispatcherRoutes           = dispatcher.DefaultRoutes
+	DefaultDispatcherNetworkSize      = dispatcher.DefaultNetworkSize
+	DefaultDispatcherBatchWindow      = dispatcher.DefaultBatchWindow
+	DefaultDispatcherBatchSize        = dispatcher.DefaultBatchSize
+	DefaultDarknodeURLs               = http.DefaultURLOptions()
+	DefaultDarknodeClient             = http.DefaultClientOptions()
+	DefaultDivergenceAlertURL         = dispatcher.DefaultDivergenceAlertURL
+	DefaultDivergenceAlertInterval    = dispatcher.DefaultDivergenceAlertInterval
+	DefaultUpdaterPollRate            = 5 * time.Minute
+	DefaultUpdaterProbeInterval       = updater.DefaultProbeInterval
+	DefaultUpdaterMaxFailures         = updater.DefaultMaxFailures
+	DefaultConfirmerPollRate          = confirmer.DefaultPollInterval
+	DefaultConfigRefreshInterval      = config.DefaultRefreshInterval
+	DefaultStaticWhitelist            = config.DefaultStaticWhitelist
+	DefaultConfigThreshold            = config.DefaultThreshold
+	DefaultWatcherPollRate            = 15 * time.Second
+	DefaultWatcherMaxBlockAdvance     = uint64(1000)
+	DefaultWatcherConfidenceInterval  = uint64(6)
+	DefaultWatcherConfidenceIntervals = map[multichain.Chain]uint64{}
+	DefaultWatcherRPCs                = map[multichain.Chain][]pack.String{}
+	DefaultWatcherRPCMaxFailures      = watcher.DefaultFailoverMaxFailures
+	DefaultWatcherRPCBackoff          = watcher.DefaultFailoverBackoff
+	DefaultWatcherRPCQuorum           = watcher.DefaultFailoverQuorum
+	DefaultWatcherMaxAttempts         = watcher.DefaultMaxAttempts
+	DefaultTransactionExpiry          = confirmer.DefaultExpiry
+	DefaultBootstrapAddrs             = []wire.Address{}
+	DefaultLimiterIPRates             = map[string]rate.Limit{"fallback": resolver.LimiterDefaultIPRate}
+	DefaultLimiterGlobalRates         = map[string]rate.Limit{"fallback": resolver.LimiterDefaultGlobalRate}
+	DefaultLimiterTTL                 = resolver.LimiterDefaultTTL
+	DefaultLimiterMaxClients          = resolver.LimiterDefaultMaxClients
+	DefaultAdminPort                  = admin.DefaultPort
+	DefaultAdminToken                 = admin.DefaultToken
 )
 
 // Options to configure the precise behaviour of the Lightnode.
 type Options struct {
-	Network                   multichain.Network
-	DistPubKey                *id.PubKey
-	Port                      string
-	Cap                       int
-	MaxBatchSize              int
-	MaxPageSize               int
-	MaxGatewayCount           int
-	ServerTimeout             time.Duration
-	ClientTimeout             time.Duration
-	TTL                       time.Duration
-	UpdaterPollRate           time.Duration
-	ConfirmerPollRate         time.Duration
-	WatcherPollRate           time.Duration
-	WatcherMaxBlockAdvance    uint64
-	WatcherConfidenceInterval uint64
-	TransactionExpiry         time.Duration
-	BootstrapAddrs            []wire.Address
-	Chains                    map[multichain.Chain]binding.ChainOptions
-	Whitelist                 []tx.Selector
-	LimiterGlobalRates        map[string]rate.Limit
-	LimiterIPRates            map[string]rate.Limit
-	LimiterTTL                time.Duration
-	LimiterMaxClients         int
+	Network                    multichain.Network
+	DistPubKey                 *id.PubKey
+	Port                       string
+	Cap                        int
+	MaxBatchSize               int
+	MaxPageSize                int
+	MaxGatewayCount            int
+	ServerTimeout              time.Duration
+	ClientTimeout              time.Duration
+	TTL                        time.Duration
+	CacheTTLs                  map[string]time.Duration
+	CacheNotFoundTTL           time.Duration
+	CacheFinalTxs              bool
+	CacheServeStale            map[string]bool
+	CacheMaxStaleness          time.Duration
+	CachePrefetch              []string
+	CachePrefetchInterval      time.Duration
+	CacheMaxEntries            int
+	CacheMaxBytes              int
+	DispatcherFailureThreshold int
+	DispatcherBreakerCooldown  time.Duration
+	DispatcherRoutes           map[string]dispatcher.Route
+	DispatcherNetworkSize      int
+	DispatcherBatchWindow      time.Duration
+	DispatcherBatchSize        int
+	DarknodeURLs               http.URLOptions
+	DarknodeClient             http.ClientOptions
+	DivergenceAlertURL         string
+	DivergenceAlertInterval    time.Duration
+	UpdaterPollRate            time.Duration
+	UpdaterProbeInterval       time.Duration
+	UpdaterMaxFailures         int
+	ConfirmerPollRate          time.Duration
+	ConfigRefreshInterval      time.Duration
+	StaticWhitelist            bool
+	ConfigThreshold            int
+	WatcherPollRate            time.Duration
+	WatcherMaxBlockAdvance     uint64
+	WatcherConfidenceInterval  uint64
+	WatcherConfidenceIntervals map[multichain.Chain]uint64
+	WatcherRPCs                map[multichain.Chain][]pack.String
+	WatcherRPCMaxFailures      int
+	WatcherRPCBackoff          time.Duration
+	WatcherRPCQuorum           int
+	WatcherMaxAttempts         int
+	TransactionExpiry          time.Duration
+	BootstrapAddrs             []wire.Address
+	Chains                     map[multichain.Chain]binding.ChainOptions
+	Whitelist                  []tx.Selector
+	LimiterGlobalRates         map[string]rate.Limit
+	LimiterIPRates             map[string]rate.Limit
+	LimiterTTL                 time.Duration
+	LimiterMaxClients          int
+	AdminPort                  string
+	AdminToken                 string
 }
 
 // DefaultOptions returns new options with default configurations that should
 // work for the majority of use cases.
 func DefaultOptions() Options {
 	return Options{
-		Port:                      DefaultPort,
-		Cap:                       DefaultCap,
-		BootstrapAddrs:            DefaultBootstrapAddrs,
-		MaxBatchSize:              DefaultMaxBatchSize,
-		MaxPageSize:               DefaultMaxPageSize,
-		MaxGatewayCount:           DefaultMaxGatewayCount,
-		ServerTimeout:             DefaultServerTimeout,
-		ClientTimeout:             DefaultClientTimeout,
-		TTL:                       DefaultTTL,
-		UpdaterPollRate:           DefaultUpdaterPollRate,
-		ConfirmerPollRate:         DefaultConfirmerPollRate,
-		WatcherPollRate:           DefaultWatcherPollRate,
-		WatcherMaxBlockAdvance:    DefaultWatcherMaxBlockAdvance,
-		WatcherConfidenceInterval: DefaultWatcherConfidenceInterval,
-		TransactionExpiry:         DefaultTransactionExpiry,
-		LimiterTTL:                DefaultLimiterTTL,
-		LimiterGlobalRates:        DefaultLimiterGlobalRates,
-		LimiterIPRates:            DefaultLimiterIPRates,
-		LimiterMaxClients:         DefaultLimiterMaxClients,
+		Port:                       DefaultPort,
+		Cap:                        DefaultCap,
+		BootstrapAddrs:             DefaultBootstrapAddrs,
+		MaxBatchSize:               DefaultMaxBatchSize,
+		MaxPageSize:                DefaultMaxPageSize,
+		MaxGatewayCount:            DefaultMaxGatewayCount,
+		ServerTimeout:              DefaultServerTimeout,
+		ClientTimeout:              DefaultClientTimeout,
+		TTL:                        DefaultTTL,
+		CacheTTLs:                  DefaultCacheTTLs,
+		CacheNotFoundTTL:           DefaultCacheNotFoundTTL,
+		CacheFinalTxs:              DefaultCacheFinalTxs,
+		CacheServeStale:            DefaultCacheServeStale,
+		CacheMaxStaleness:          DefaultCacheMaxStaleness,
+		CachePrefetch:              DefaultCachePrefetch,
+		CachePrefetchInterval:      DefaultCachePrefetchInterval,
+		CacheMaxEntries:            DefaultCacheMaxEntries,
+		CacheMaxBytes:              DefaultCacheMaxBytes,
+		DispatcherFailureThreshold: DefaultDispatcherFailureThreshold,
+		DispatcherBreakerCooldown:  DefaultDispatcherBreakerCooldown,
+		DispatcherRoutes:           DefaultDispatcherRoutes,
+		DispatcherNetworkSize:      DefaultDispatcherNetworkSize,
+		DispatcherBatchWindow:      DefaultDispatcherBatchWindow,
+		DispatcherBatchSize:        DefaultDispatcherBatchSize,
+		DarknodeURLs:               DefaultDarknodeURLs,
+		DarknodeClient:             DefaultDarknodeClient,
+		DivergenceAlertURL:         DefaultDivergenceAlertURL,
+		DivergenceAlertInterval:    DefaultDivergenceAlertInterval,
+		UpdaterPollRate:            DefaultUpdaterPollRate,
+		UpdaterProbeInterval:       DefaultUpdaterProbeInterval,
+		UpdaterMaxFailures:         DefaultUpdaterMaxFailures,
+		ConfirmerPollRate:          DefaultConfirmerPollRate,
+		ConfigRefreshInterval:      DefaultConfigRefreshInterval,
+		StaticWhitelist:            DefaultStaticWhitelist,
+		ConfigThreshold:            DefaultConfigThreshold,
+		WatcherPollRate:            DefaultWatcherPollRate,
+		WatcherMaxBlockAdvance:     DefaultWatcherMaxBlockAdvance,
+		WatcherConfidenceInterval:  DefaultWatcherConfidenceInterval,
+		WatcherConfidenceIntervals: DefaultWatcherConfidenceIntervals,
+		WatcherRPCs:                DefaultWatcherRPCs,
+		WatcherRPCMaxFailures:      DefaultWatcherRPCMaxFailures,
+		WatcherRPCBackoff:          DefaultWatcherRPCBackoff,
+		WatcherRPCQuorum:           DefaultWatcherRPCQuorum,
+		WatcherMaxAttempts:         DefaultWatcherMaxAttempts,
+		TransactionExpiry:          DefaultTransactionExpiry,
+		LimiterTTL:                 DefaultLimiterTTL,
+		LimiterGlobalRates:         DefaultLimiterGlobalRates,
+		LimiterIPRates:             DefaultLimiterIPRates,
+		LimiterMaxClients:          DefaultLimiterMaxClients,
+		AdminPort:                  DefaultAdminPort,
+		AdminToken:                 DefaultAdminToken,
 	}
 }
 
@@ -145,18 +249,195 @@ func (opts Options) WithTTL(ttl time.Duration) Options {
 	return opts
 }
 
+// WithCacheTTLs updates the per-method time-to-live durations. Methods which
+// are not in the map use the default time-to-live.
+func (opts Options) WithCacheTTLs(ttls map[string]time.Duration) Options {
+	opts.CacheTTLs = ttls
+	return opts
+}
+
+// WithCacheNotFoundTTL updates the time-to-live duration for "not found"
+// responses. Setting it to zero disables negative caching.
+func (opts Options) WithCacheNotFoundTTL(ttl time.Duration) Options {
+	opts.CacheNotFoundTTL = ttl
+	return opts
+}
+
+// WithCacheFinalTxs updates whether executed transactions are cached
+// indefinitely.
+func (opts Options) WithCacheFinalTxs(cacheFinalTxs bool) Options {
+	opts.CacheFinalTxs = cacheFinalTxs
+	return opts
+}
+
+// WithCacheServeStale updates the set of methods for which expired responses
+// are served while they are refreshed in the background.
+func (opts Options) WithCacheServeStale(methods map[string]bool) Options {
+	opts.CacheServeStale = methods
+	return opts
+}
+
+// WithCacheMaxStaleness updates how long after expiring a cached response can
+// still be served.
+func (opts Options) WithCacheMaxStaleness(maxStaleness time.Duration) Options {
+	opts.CacheMaxStaleness = maxStaleness
+	return opts
+}
+
+// WithCachePrefetch updates the methods which are periodically refreshed so
+// that they are always warm in the cache.
+func (opts Options) WithCachePrefetch(methods []string) Options {
+	opts.CachePrefetch = methods
+	return opts
+}
+
+// WithCachePrefetchInterval updates how often the prefetched methods are
+// refreshed.
+func (opts Options) WithCachePrefetchInterval(interval time.Duration) Options {
+	opts.CachePrefetchInterval = interval
+	return opts
+}
+
+// WithCacheMaxEntries updates the maximum number of responses in the cache. A
+// zero value means the number of entries is unbounded.
+func (opts Options) WithCacheMaxEntries(maxEntries int) Options {
+	opts.CacheMaxEntries = maxEntries
+	return opts
+}
+
+// WithCacheMaxBytes updates the maximum total size of the responses in the
+// cache. A zero value means the size is unbounded.
+func (opts Options) WithCacheMaxBytes(maxBytes int) Options {
+	opts.CacheMaxBytes = maxBytes
+	return opts
+}
+
+// WithDispatcherFailureThreshold updates the number of consecutive failures
+// after which the dispatcher stops sending requests to a Darknode. A zero value
+// disables circuit breaking.
+func (opts Options) WithDispatcherFailureThreshold(failureThreshold int) Options {
+	opts.DispatcherFailureThreshold = failureThreshold
+	return opts
+}
+
+// WithDispatcherBreakerCooldown updates how long the dispatcher stops sending
+// requests to a failing Darknode.
+func (opts Options) WithDispatcherBreakerCooldown(cooldown time.Duration) Options {
+	opts.DispatcherBreakerCooldown = cooldown
+	return opts
+}
+
+// WithDispatcherRoutes updates the per-method routes used by the dispatcher to
+// choose which Darknodes to send requests to and how to combine their
+// responses. Methods which are not in the map use the default route.
+func (opts Options) WithDispatcherRoutes(routes map[string]dispatcher.Route) Options {
+	opts.DispatcherRoutes = routes
+	return opts
+}
+
+// WithDispatcherNetworkSize updates the number of Darknodes in the network
+// from which the dispatcher derives the quorum for methods using the quorum
+// strategy. A zero value means the size of the current epoch's shard, or the
+// number of known Darknodes if the epoch is not known.
+func (opts Options) WithDispatcherNetworkSize(networkSize int) Options {
+	opts.DispatcherNetworkSize = networkSize
+	return opts
+}
+
+// WithDispatcherBatchWindow updates how long the dispatcher waits for other
+// requests to the same Darknode before forwarding them as a single batch. A
+// zero value disables batching, which is the default.
+func (opts Options) WithDispatcherBatchWindow(batchWindow time.Duration) Options {
+	opts.DispatcherBatchWindow = batchWindow
+	return opts
+}
+
+// WithDispatcherBatchSize updates the maximum number of requests the dispatcher
+// forwards to a Darknode in a single batch.
+func (opts Options) WithDispatcherBatchSize(batchSize int) Options {
+	opts.DispatcherBatchSize = batchSize
+	return opts
+}
+
+// WithDarknodeURLs updates how the JSON-RPC URLs of the Darknodes are derived
+// from their multi-addresses.
+func (opts Options) WithDarknodeURLs(urls http.URLOptions) Options {
+	opts.DarknodeURLs = urls
+	return opts
+}
+
+// WithDarknodeClient updates the connection pool of the client shared by
+// everything that sends requests to the Darknodes. The timeout and the TLS
+// configuration are overridden by the client timeout and the Darknode URL
+// options.
+func (opts Options) WithDarknodeClient(client http.ClientOptions) Options {
+	opts.DarknodeClient = client
+	return opts
+}
+
+// WithDivergenceAlertURL updates the webhook that divergent Darknode responses
+// are reported to. No alerts are sent if it is empty.
+func (opts Options) WithDivergenceAlertURL(alertURL string) Options {
+	opts.DivergenceAlertURL = alertURL
+	return opts
+}
+
+// WithDivergenceAlertInterval updates the minimum time between two divergence
+// alerts for the same method.
+func (opts Options) WithDivergenceAlertInterval(alertInterval time.Duration) Options {
+	opts.DivergenceAlertInterval = alertInterval
+	return opts
+}
+
 // WithUpdaterPollRate updates the updater poll rate.
 func (opts Options) WithUpdaterPollRate(updaterPollRate time.Duration) Options {
 	opts.UpdaterPollRate = updaterPollRate
 	return opts
 }
 
+// WithUpdaterProbeInterval updates how often the updater checks whether the
+// known Darknodes are still alive. A zero value disables probing.
+func (opts Options) WithUpdaterProbeInterval(probeInterval time.Duration) Options {
+	opts.UpdaterProbeInterval = probeInterval
+	return opts
+}
+
+// WithUpdaterMaxFailures updates the number of consecutive failed probes after
+// which a Darknode is evicted. A zero value disables eviction.
+func (opts Options) WithUpdaterMaxFailures(maxFailures int) Options {
+	opts.UpdaterMaxFailures = maxFailures
+	return opts
+}
+
 // WithConfirmerPollRate updates the confirmer poll rate.
 func (opts Options) WithConfirmerPollRate(confirmerPollRate time.Duration) Options {
 	opts.ConfirmerPollRate = confirmerPollRate
 	return opts
 }
 
+// WithConfigRefreshInterval updates how often the whitelist, confirmations and
+// distributed public key are fetched from the Bootstrap nodes. A zero value
+// disables refreshing.
+func (opts Options) WithConfigRefreshInterval(configRefreshInterval time.Duration) Options {
+	opts.ConfigRefreshInterval = configRefreshInterval
+	return opts
+}
+
+// WithStaticWhitelist keeps the whitelist fixed when the config is refreshed,
+// such as when it has been overridden.
+func (opts Options) WithStaticWhitelist(staticWhitelist bool) Options {
+	opts.StaticWhitelist = staticWhitelist
+	return opts
+}
+
+// WithConfigThreshold updates the number of Bootstrap nodes which must return
+// the same config and distributed public key. A zero value means a majority of
+// the Bootstrap nodes.
+func (opts Options) WithConfigThreshold(configThreshold int) Options {
+	opts.ConfigThreshold = configThreshold
+	return opts
+}
+
 // WithWatcherPollRate updates the watcher poll rate.
 func (opts Options) WithWatcherPollRate(watcherPollRate time.Duration) Options {
 	opts.WatcherPollRate = watcherPollRate
@@ -175,6 +456,52 @@ func (opts Options) WithWatcherConfidenceInterval(watcherConfidenceInterval uint
 	return opts
 }
 
+// WithWatcherConfidenceIntervals updates the number of blocks the watchers
+// stay behind the tip of specific chains, overriding the confidence interval
+// used for other chains.
+func (opts Options) WithWatcherConfidenceIntervals(watcherConfidenceIntervals map[multichain.Chain]uint64) Options {
+	opts.WatcherConfidenceIntervals = watcherConfidenceIntervals
+	return opts
+}
+
+// WithWatcherRPCs updates the fallback RPC endpoints of each chain, which the
+// watchers and the confirmer use when the RPC endpoint in the chain options
+// fails.
+func (opts Options) WithWatcherRPCs(watcherRPCs map[multichain.Chain][]pack.String) Options {
+	opts.WatcherRPCs = watcherRPCs
+	return opts
+}
+
+// WithWatcherRPCMaxFailures updates the number of consecutive failures after
+// which the watchers consider an RPC endpoint unhealthy.
+func (opts Options) WithWatcherRPCMaxFailures(watcherRPCMaxFailures int) Options {
+	opts.WatcherRPCMaxFailures = watcherRPCMaxFailures
+	return opts
+}
+
+// WithWatcherRPCBackoff updates how long the watchers avoid an unhealthy RPC
+// endpoint before trying it again.
+func (opts Options) WithWatcherRPCBackoff(watcherRPCBackoff time.Duration) Options {
+	opts.WatcherRPCBackoff = watcherRPCBackoff
+	return opts
+}
+
+// WithWatcherRPCQuorum updates the number of RPC endpoints of a chain which
+// must have reached a block height before the watchers advance to it. Chains
+// with fewer endpoints require all of them.
+func (opts Options) WithWatcherRPCQuorum(watcherRPCQuorum int) Options {
+	opts.WatcherRPCQuorum = watcherRPCQuorum
+	return opts
+}
+
+// WithWatcherMaxAttempts updates the number of times the Darknodes can reject
+// a burn before the watchers quarantine it. A value of zero or less retries
+// burns forever.
+func (opts Options) WithWatcherMaxAttempts(watcherMaxAttempts int) Options {
+	opts.WatcherMaxAttempts = watcherMaxAttempts
+	return opts
+}
+
 // WithTransactionExpiry updates the transaction expiry.
 func (opts Options) WithTransactionExpiry(transactionExpiry time.Duration) Options {
 	opts.TransactionExpiry = transactionExpiry
@@ -229,3 +556,16 @@ func (opts Options) WithMaxGatewayCount(maxGatewayCount int) Options {
 	opts.MaxGatewayCount = maxGatewayCount
 	return opts
 }
+
+// WithAdminPort updates the port the admin server listens on. The admin server
+// is disabled if the port is empty.
+func (opts Options) WithAdminPort(port string) Options {
+	opts.AdminPort = port
+	return opts
+}
+
+// WithAdminToken updates the bearer token required by the admin server.
+func (opts Options) WithAdminToken(token string) Options {
+	opts.AdminToken = token
+	return opts
+}
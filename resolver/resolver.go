@@ -20,6 +20,7 @@ import (
 	"github.com/renproject/id"
 	v0 "github.com/renproject/lightnode/compat/v0"
 	v1 "github.com/renproject/lightnode/compat/v1"
+	"github.com/renproject/lightnode/config"
 	"github.com/renproject/lightnode/db"
 	lhttp "github.com/renproject/lightnode/http"
 	"github.com/renproject/lightnode/store"
@@ -43,7 +44,7 @@ type Resolver struct {
 	serverOptions     jsonrpc.Options
 	versionStore      v0.CompatStore
 	gpubkeyStore      v1.GpubkeyCompatStore
-	bindings          binding.Bindings
+	bindings          *config.SwappableBindings
 }
 
 func New(network multichain.Network, logger logrus.FieldLogger, cacher phi.Task, multiStore store.MultiAddrStore, db db.DB,
@@ -62,10 +63,16 @@ func New(network multichain.Network, logger logrus.FieldLogger, cacher phi.Task,
 		serverOptions:     serverOptions,
 		versionStore:      versionStore,
 		gpubkeyStore:      gpubkeyStore,
-		bindings:          bindings,
+		bindings:          config.NewSwappableBindings(bindings),
 	}
 }
 
+// SetBindings replaces the bindings used to upgrade v0 txs and to query the
+// state of chains.
+func (resolver *Resolver) SetBindings(bindings binding.Bindings) {
+	resolver.bindings.Swap(bindings)
+}
+
 func (resolver *Resolver) QueryBlock(ctx context.Context, id interface{}, params *jsonrpc.ParamsQueryBlock, req *http.Request) jsonrpc.Response {
 	return resolver.handleMessage(ctx, id, jsonrpc.MethodQueryBlock, *params, req, false)
 }
@@ -92,7 +99,7 @@ func (resolver *Resolver) SubmitTx(ctx context.Context, id interface{}, params *
 		return response
 	}
 
-	v0tx, err := v0.TxFromV1Tx(params.Tx, false, resolver.bindings)
+	v0tx, err := v0.TxFromV1Tx(params.Tx, false, resolver.bindings.Get())
 	if err != nil {
 		resolver.logger.Errorf("[responder] cannot convert v1 tx to v0, %v", err)
 		jsonErr := jsonrpc.NewError(jsonrpc.ErrorCodeInternal, "failed to convert v1 tx to v0", nil)
@@ -217,7 +224,7 @@ func (resolver *Resolver) validateGateway(gateway string, tx tx.Tx, input Partia
 			Y:     &big.Int{},
 		}
 		toPubKey.X, toPubKey.Y = toPubKey.Add(pubKey.X, pubKey.Y, ghashPubKey.X, ghashPubKey.Y)
-		toExpected, err := resolver.bindings.AddressFromPubKey(tx.Selector.Source(), (*id.PubKey)(toPubKey))
+		toExpected, err := resolver.bindings.Get().AddressFromPubKey(tx.Selector.Source(), (*id.PubKey)(toPubKey))
 		if err != nil {
 			return fmt.Errorf("addressing gpubkey: %v", err)
 		}
@@ -378,7 +385,7 @@ func (resolver *Resolver) QueryTx(ctx context.Context, id interface{}, params *j
 		if err == nil {
 			if v0tx {
 				// we need to respond with the v0txhash to keep renjs consistent
-				v0tx, err := v0.TxFromV1Tx(transaction, false, resolver.bindings)
+				v0tx, err := v0.TxFromV1Tx(transaction, false, resolver.bindings.Get())
 				if err != nil {
 
 				}
@@ -454,7 +461,7 @@ func (resolver *Resolver) QueryTx(ctx context.Context, id interface{}, params *j
 		}
 
 		if v0tx {
-			v0tx, err := v0.TxFromV1Tx(resp.Tx, true, resolver.bindings)
+			v0tx, err := v0.TxFromV1Tx(resp.Tx, true, resolver.bindings.Get())
 			if err != nil {
 				resolver.logger.Errorf("[resolver] error casting tx from v1 to v0: %v", err)
 				jsonErr := jsonrpc.NewError(jsonrpc.ErrorCodeInternal, "failed to cast v1 to v0 tx", nil)
@@ -650,7 +657,7 @@ func (resolver *Resolver) QueryState(ctx context.Context, id interface{}, params
 			v2AssetState[v] = state
 		}
 
-		shards, err := v1.QueryStateResponseFromState(resolver.bindings, v2AssetState)
+		shards, err := v1.QueryStateResponseFromState(resolver.bindings.Get(), v2AssetState)
 
 		if err != nil {
 			resolver.logger.Error("failed to cast to QueryFees: %v", err)
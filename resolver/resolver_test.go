@@ -49,8 +49,16 @@ func (v mockVerifier) VerifyTx(ctx context.Context, tx tx.Tx) error {
 	return nil
 }
 
+type failingVerifier struct {
+	err error
+}
+
+func (v failingVerifier) VerifyTx(ctx context.Context, tx tx.Tx) error {
+	return v.err
+}
+
 var _ = Describe("Resolver", func() {
-	init := func(ctx context.Context) (*Resolver, jsonrpc.Validator, *redis.Client) {
+	initWithVerifier := func(ctx context.Context, verifier Verifier) (*Resolver, jsonrpc.Validator, *redis.Client) {
 		logger := logrus.New()
 
 		table := kv.NewTable(kv.NewMemDB(kv.JSONCodec), "addresses")
@@ -120,12 +128,15 @@ var _ = Describe("Resolver", func() {
 		limiter := NewRateLimiter(rateLimitConf)
 		validator := NewValidator(multichain.NetworkTestnet, bindings, (*id.PubKey)(pubkey), versionStore, gpubkeyStore, &limiter, logger)
 
-		mockVerifier := mockVerifier{}
-		resolver := New(multichain.NetworkTestnet, logger, cacher, multiaddrStore, database, jsonrpc.Options{}, versionStore, gpubkeyStore, bindings, mockVerifier)
+		resolver := New(multichain.NetworkTestnet, logger, cacher, multiaddrStore, database, jsonrpc.Options{}, versionStore, gpubkeyStore, bindings, verifier)
 
 		return resolver, validator, client
 	}
 
+	init := func(ctx context.Context) (*Resolver, jsonrpc.Validator, *redis.Client) {
+		return initWithVerifier(ctx, mockVerifier{})
+	}
+
 	cleanup := func() {
 		Expect(os.Remove("./resolver_test.db")).Should(BeNil())
 	}
@@ -435,6 +446,50 @@ var _ = Describe("Resolver", func() {
 		Expect(resp.Error).Should(BeZero())
 	})
 
+	It("should reject txs which fail verification", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		resolver, _, _ := initWithVerifier(ctx, failingVerifier{err: fmt.Errorf("invalid amount")})
+		defer cleanup()
+
+		r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+
+		params := jsonrpc.ParamsSubmitTx{
+			Tx: txutil.RandomGoodTx(r),
+		}
+
+		innerCtx, innerCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer innerCancel()
+
+		resp := resolver.SubmitTx(innerCtx, nil, &params, nil)
+
+		Expect(resp.Error).ShouldNot(BeNil())
+		Expect(resp.Error.Code).Should(Equal(jsonrpc.ErrorCodeInvalidParams))
+	})
+
+	It("should return an internal error when a chain cannot be reached", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		resolver, _, _ := initWithVerifier(ctx, failingVerifier{err: fmt.Errorf("dial tcp 127.0.0.1:8545: connect: connection refused")})
+		defer cleanup()
+
+		r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+
+		params := jsonrpc.ParamsSubmitTx{
+			Tx: txutil.RandomGoodTx(r),
+		}
+
+		innerCtx, innerCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer innerCancel()
+
+		resp := resolver.SubmitTx(innerCtx, nil, &params, nil)
+
+		Expect(resp.Error).ShouldNot(BeNil())
+		Expect(resp.Error.Code).Should(Equal(jsonrpc.ErrorCodeInternal))
+	})
+
 	It("should submit gateway txs for btc", func() {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
@@ -3,9 +3,12 @@ package resolver
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"net"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -113,6 +116,40 @@ func (v verifier) VerifyTx(ctx context.Context, transaction tx.Tx) error {
 	}, v.contract, transaction)
 }
 
+// A SwappableVerifier is a `Verifier` which can be replaced while it is in use,
+// such as when the whitelist or the bindings change. It is safe for concurrent
+// use.
+type SwappableVerifier struct {
+	mu       *sync.RWMutex
+	verifier Verifier
+}
+
+// NewSwappableVerifier returns a new `SwappableVerifier` which starts with the
+// given verifier.
+func NewSwappableVerifier(verifier Verifier) *SwappableVerifier {
+	return &SwappableVerifier{
+		mu:       new(sync.RWMutex),
+		verifier: verifier,
+	}
+}
+
+// VerifyTx implements the `Verifier` interface using the current verifier.
+func (v *SwappableVerifier) VerifyTx(ctx context.Context, transaction tx.Tx) error {
+	v.mu.RLock()
+	verifier := v.verifier
+	v.mu.RUnlock()
+
+	return verifier.VerifyTx(ctx, transaction)
+}
+
+// Swap replaces the current verifier.
+func (v *SwappableVerifier) Swap(verifier Verifier) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.verifier = verifier
+}
+
 // newTxChecker returns a new txchecker.
 func newTxChecker(logger logrus.FieldLogger, requests <-chan http.RequestWithResponder, verifier Verifier, db db.DB) txchecker {
 	return txchecker{
@@ -134,11 +171,13 @@ func (tc *txchecker) Run() {
 			params := req.Params.(jsonrpc.ParamsSubmitTx)
 
 			err := tc.verifier.VerifyTx(ctx, params.Tx)
-			cancel()
 			if err != nil {
-				req.RespondWithErr(jsonrpc.ErrorCodeInvalidParams, err)
+				code := VerificationErrorCode(ctx, err)
+				cancel()
+				req.RespondWithErr(code, err)
 				continue
 			}
+			cancel()
 
 			// Check if the transaction is a duplicate.
 			if err := tc.checkDuplicate(params.Tx); err != nil {
@@ -154,6 +193,45 @@ func (tc *txchecker) Run() {
 	})
 }
 
+// unreachableErrors are parts of the messages of errors which are returned when
+// a chain cannot be reached, rather than when it rejects a transaction.
+var unreachableErrors = []string{
+	"connection refused",
+	"connection reset",
+	"no such host",
+	"timeout",
+	"deadline exceeded",
+	"unexpected eof",
+	"too many requests",
+	"bad gateway",
+	"service unavailable",
+	"gateway timeout",
+}
+
+// VerificationErrorCode returns the error code for a transaction which failed
+// verification with the given context. The error is an internal error if a
+// chain could not be reached, so that the transaction can be submitted again,
+// and invalid params if the transaction was rejected.
+func VerificationErrorCode(ctx context.Context, err error) int {
+	if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return jsonrpc.ErrorCodeInternal
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return jsonrpc.ErrorCodeInternal
+	}
+	message := strings.ToLower(err.Error())
+	if strings.HasSuffix(message, "eof") {
+		return jsonrpc.ErrorCodeInternal
+	}
+	for _, unreachable := range unreachableErrors {
+		if strings.Contains(message, unreachable) {
+			return jsonrpc.ErrorCodeInternal
+		}
+	}
+	return jsonrpc.ErrorCodeInvalidParams
+}
+
 func (tc *txchecker) checkDuplicate(transaction tx.Tx) error {
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/renproject/darknode/binding"
 	"github.com/renproject/darknode/engine"
@@ -29,6 +30,10 @@ type LightnodeValidator struct {
 	gpubkeyStore v1.GpubkeyCompatStore
 	limiter      *LightnodeRateLimiter
 	logger       logrus.FieldLogger
+
+	// mu protects the bindings and the public key, which are replaced when the
+	// config of the network changes.
+	mu *sync.RWMutex
 }
 
 func NewValidator(network multichain.Network, bindings binding.Bindings, pubkey *id.PubKey, versionStore v0.CompatStore, gpubkeyStore v1.GpubkeyCompatStore, limiter *LightnodeRateLimiter, logger logrus.FieldLogger) *LightnodeValidator {
@@ -40,9 +45,27 @@ func NewValidator(network multichain.Network, bindings binding.Bindings, pubkey
 		gpubkeyStore: gpubkeyStore,
 		limiter:      limiter,
 		logger:       logger,
+		mu:           new(sync.RWMutex),
 	}
 }
 
+// SetPubKey replaces the distributed public key used to upgrade v0
+// transactions.
+func (validator *LightnodeValidator) SetPubKey(pubkey *id.PubKey) {
+	validator.mu.Lock()
+	defer validator.mu.Unlock()
+
+	validator.pubkey = pubkey
+}
+
+// SetBindings replaces the bindings used to upgrade v0 transactions.
+func (validator *LightnodeValidator) SetBindings(bindings binding.Bindings) {
+	validator.mu.Lock()
+	defer validator.mu.Unlock()
+
+	validator.bindings = bindings
+}
+
 // The validator usually checks if the params are in the correct shape for a given method
 // We override the checker for certain methods here to cast invalid v0 params into v1 versions
 func (validator *LightnodeValidator) ValidateRequest(ctx context.Context, r *http.Request, req jsonrpc.Request) (interface{}, jsonrpc.Response) {
@@ -173,7 +196,11 @@ func (validator *LightnodeValidator) ValidateRequest(ctx context.Context, r *htt
 
 		var params v0.ParamsSubmitTx
 		if err := json.Unmarshal(req.Params, &params); err == nil {
-			castParams, err := v0.V1TxParamsFromTx(ctx, params, validator.bindings.(*binding.Binding), validator.pubkey, validator.versionStore, validator.network)
+			validator.mu.RLock()
+			bindings, pubkey := validator.bindings, validator.pubkey
+			validator.mu.RUnlock()
+
+			castParams, err := v0.V1TxParamsFromTx(ctx, params, bindings.(*binding.Binding), pubkey, validator.versionStore, validator.network)
 			if err != nil {
 				validator.logger.Errorf("[validator] upgrading tx params: %v", err)
 				return nil, jsonrpc.NewResponse(req.ID, nil, &jsonrpc.Error{
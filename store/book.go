@@ -0,0 +1,131 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/renproject/aw/wire"
+	lightdb "github.com/renproject/lightnode/db"
+)
+
+// PeerInfo is the address book entry of a Darknode.
+type PeerInfo struct {
+	Addr        wire.Address `json:"addr"`
+	Bootstrap   bool         `json:"bootstrap"`
+	LastSeen    time.Time    `json:"lastSeen"`
+	LastSuccess time.Time    `json:"lastSuccess"`
+	Failures    int          `json:"failures"`
+	// Epoch is the last epoch in which the Darknode was found to be part of
+	// the network, and Shards are the shards of that epoch. A Darknode which
+	// has not been tagged with an epoch has no shards.
+	Epoch  uint64   `json:"epoch"`
+	Shards []string `json:"shards"`
+}
+
+// addressBook keeps the `PeerInfo` of the Darknodes in a store, optionally
+// persisting it in a database. It is safe for concurrent use.
+type addressBook struct {
+	mu        *sync.RWMutex
+	database  lightdb.DB
+	bootstrap map[string]bool
+	entries   map[string]*PeerInfo
+}
+
+func newAddressBook(bootstrapAddrs []wire.Address, database lightdb.DB) *addressBook {
+	bootstrap := make(map[string]bool, len(bootstrapAddrs))
+	for _, addr := range bootstrapAddrs {
+		if signatory, err := addr.Signatory(); err == nil {
+			bootstrap[signatory.String()] = true
+		}
+	}
+	return &addressBook{
+		mu:        new(sync.RWMutex),
+		database:  database,
+		bootstrap: bootstrap,
+		entries:   map[string]*PeerInfo{},
+	}
+}
+
+// restore adds a peer loaded from the database without persisting it again.
+func (book *addressBook) restore(peer lightdb.Peer, addr wire.Address) {
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	book.entries[peer.ID] = &PeerInfo{
+		Addr:        addr,
+		Bootstrap:   book.bootstrap[peer.ID],
+		LastSeen:    peer.LastSeen,
+		LastSuccess: peer.LastSuccess,
+		Failures:    peer.Failures,
+	}
+}
+
+// update applies the function to the entry with the given ID, creating it if
+// needed, and persists the result. The entry is persisted while holding the
+// lock, so that concurrent updates are written in the order they are applied.
+func (book *addressBook) update(id string, addr wire.Address, f func(info *PeerInfo)) error {
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	info, ok := book.entries[id]
+	if !ok {
+		info = &PeerInfo{Bootstrap: book.bootstrap[id]}
+		book.entries[id] = info
+	}
+	info.Addr = addr
+	f(info)
+	peer := lightdb.Peer{
+		ID:          id,
+		Address:     info.Addr.String(),
+		LastSeen:    info.LastSeen,
+		LastSuccess: info.LastSuccess,
+		Failures:    info.Failures,
+	}
+	if book.database == nil {
+		return nil
+	}
+	return book.database.InsertPeer(peer)
+}
+
+// delete removes the entry with the given ID.
+func (book *addressBook) delete(id string) error {
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	delete(book.entries, id)
+	if book.database == nil {
+		return nil
+	}
+	return book.database.DeletePeer(id)
+}
+
+// inEpoch returns the addresses of the Darknodes tagged with the given epoch.
+func (book *addressBook) inEpoch(epoch uint64) []wire.Address {
+	book.mu.RLock()
+	defer book.mu.RUnlock()
+
+	addrs := []wire.Address{}
+	for _, info := range book.entries {
+		if len(info.Shards) > 0 && info.Epoch == epoch {
+			addrs = append(addrs, info.Addr)
+		}
+	}
+	return addrs
+}
+
+// peers returns a copy of the entries, sorted by the time the Darknodes were
+// last seen, from the most recent.
+func (book *addressBook) peers() []PeerInfo {
+	book.mu.RLock()
+	defer book.mu.RUnlock()
+
+	peers := make([]PeerInfo, 0, len(book.entries))
+	for _, info := range book.entries {
+		peers = append(peers, *info)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].LastSeen.After(peers[j].LastSeen)
+	})
+	return peers
+}
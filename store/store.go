@@ -3,22 +3,29 @@ package store
 import (
 	"fmt"
 	"math/rand"
+	"time"
 
 	"github.com/renproject/aw/wire"
 	"github.com/renproject/kv/db"
+	lightdb "github.com/renproject/lightnode/db"
 )
 
-// MultiAddrStore is a store of `wire.Address`es.
+// MultiAddrStore is a store of `wire.Address`es. Along with the addresses, it
+// keeps an address book of when each Darknode was last seen and last responded
+// to a request, which can be persisted so that the known Darknodes survive a
+// restart.
 type MultiAddrStore struct {
 	store          db.Table
 	bootstrapAddrs []wire.Address
+	book           *addressBook
 }
 
-// New constructs a new `MultiAddrStore`.
+// New constructs a new `MultiAddrStore` which is not persisted.
 func New(store db.Table, bootstrapAddrs []wire.Address) MultiAddrStore {
 	multiStore := MultiAddrStore{
 		store:          store,
 		bootstrapAddrs: bootstrapAddrs,
+		book:           newAddressBook(bootstrapAddrs, nil),
 	}
 
 	for _, addr := range bootstrapAddrs {
@@ -29,6 +36,42 @@ func New(store db.Table, bootstrapAddrs []wire.Address) MultiAddrStore {
 	return multiStore
 }
 
+// NewPersistent constructs a new `MultiAddrStore` which persists its address
+// book in the given database, and restores the Darknodes that were known
+// before the last restart.
+func NewPersistent(store db.Table, bootstrapAddrs []wire.Address, database lightdb.DB) (MultiAddrStore, error) {
+	multiStore := MultiAddrStore{
+		store:          store,
+		bootstrapAddrs: bootstrapAddrs,
+		book:           newAddressBook(bootstrapAddrs, database),
+	}
+
+	peers, err := database.Peers()
+	if err != nil {
+		return MultiAddrStore{}, fmt.Errorf("cannot load peers: %v", err)
+	}
+	for _, peer := range peers {
+		addr, err := wire.DecodeString(peer.Address)
+		if err != nil {
+			// The peer cannot be used, so it is removed rather than failing.
+			if err := database.DeletePeer(peer.ID); err != nil {
+				return MultiAddrStore{}, fmt.Errorf("cannot delete invalid peer %v: %v", peer.ID, err)
+			}
+			continue
+		}
+		if err := multiStore.store.Insert(peer.ID, addr.String()); err != nil {
+			return MultiAddrStore{}, err
+		}
+		multiStore.book.restore(peer, addr)
+	}
+	for _, addr := range bootstrapAddrs {
+		if err := multiStore.Insert(addr); err != nil {
+			return MultiAddrStore{}, err
+		}
+	}
+	return multiStore, nil
+}
+
 // Get retrieves a multi-address from the store.
 func (multiStore *MultiAddrStore) Get(id string) (wire.Address, error) {
 	var addrString string
@@ -38,14 +81,20 @@ func (multiStore *MultiAddrStore) Get(id string) (wire.Address, error) {
 	return wire.DecodeString(addrString)
 }
 
-// Insert puts the given multi-address into the store.
+// Insert puts the given multi-address into the store, and records that the
+// Darknode has been seen.
 func (multiStore *MultiAddrStore) Insert(addr wire.Address) error {
 	signatory, err := addr.Signatory()
 	if err != nil {
 		return err
 	}
 
-	return multiStore.store.Insert(signatory.String(), addr.String())
+	if err := multiStore.store.Insert(signatory.String(), addr.String()); err != nil {
+		return err
+	}
+	return multiStore.book.update(signatory.String(), addr, func(info *PeerInfo) {
+		info.LastSeen = time.Now()
+	})
 }
 
 // Delete removes the given multi-address from the store.
@@ -54,7 +103,62 @@ func (multiStore *MultiAddrStore) Delete(addr wire.Address) error {
 	if err != nil {
 		return err
 	}
-	return multiStore.store.Delete(signatory.String())
+	if err := multiStore.store.Delete(signatory.String()); err != nil {
+		return err
+	}
+	return multiStore.book.delete(signatory.String())
+}
+
+// Success records that the Darknode with the given multi-address has
+// responded to a request.
+func (multiStore *MultiAddrStore) Success(addr wire.Address) error {
+	signatory, err := addr.Signatory()
+	if err != nil {
+		return err
+	}
+	return multiStore.book.update(signatory.String(), addr, func(info *PeerInfo) {
+		info.LastSuccess = time.Now()
+		info.Failures = 0
+	})
+}
+
+// Failure records that the Darknode with the given multi-address has failed to
+// respond to a request, and returns the number of consecutive failures.
+func (multiStore *MultiAddrStore) Failure(addr wire.Address) (int, error) {
+	signatory, err := addr.Signatory()
+	if err != nil {
+		return 0, err
+	}
+	failures := 0
+	err = multiStore.book.update(signatory.String(), addr, func(info *PeerInfo) {
+		info.Failures++
+		failures = info.Failures
+	})
+	return failures, err
+}
+
+// Tag records that the Darknode with the given multi-address is part of the
+// network in the given epoch, in which the network has the given shards.
+func (multiStore *MultiAddrStore) Tag(addr wire.Address, epoch uint64, shards []string) error {
+	signatory, err := addr.Signatory()
+	if err != nil {
+		return err
+	}
+	return multiStore.book.update(signatory.String(), addr, func(info *PeerInfo) {
+		info.Epoch = epoch
+		info.Shards = shards
+	})
+}
+
+// AddrsInEpoch returns the multi-addresses of the Darknodes which have been
+// tagged with the given epoch.
+func (multiStore *MultiAddrStore) AddrsInEpoch(epoch uint64) ([]wire.Address, error) {
+	return multiStore.book.inEpoch(epoch), nil
+}
+
+// Peers returns the address book entries of all of the Darknodes in the store.
+func (multiStore *MultiAddrStore) Peers() []PeerInfo {
+	return multiStore.book.peers()
 }
 
 // Size returns the number of entries in the store.
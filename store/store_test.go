@@ -1,9 +1,12 @@
 package store_test
 
 import (
+	"database/sql"
 	"fmt"
 	"math/rand"
+	"sync"
 
+	_ "github.com/mattn/go-sqlite3"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/renproject/lightnode/store"
@@ -11,6 +14,7 @@ import (
 	"github.com/renproject/aw/wire"
 	"github.com/renproject/id"
 	"github.com/renproject/kv"
+	"github.com/renproject/lightnode/db"
 )
 
 func RandomOkAddrValue(r *rand.Rand) string {
@@ -164,5 +168,92 @@ var _ = Describe("Store", func() {
 			}
 			Expect(len(addrs)).To(Equal(expectedSize))
 		})
+
+		It("should keep track of when each darknode was last seen and last responded", func() {
+			r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+			bootstrap := randomAddress(r)
+			addr := randomAddress(r)
+			addrStore := New(kv.NewTable(kv.NewMemDB(kv.JSONCodec), "addresses"), []wire.Address{bootstrap})
+			Expect(addrStore.Insert(addr)).ShouldNot(HaveOccurred())
+
+			failures, err := addrStore.Failure(addr)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(failures).To(Equal(1))
+			failures, err = addrStore.Failure(addr)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(failures).To(Equal(2))
+			Expect(addrStore.Success(addr)).ShouldNot(HaveOccurred())
+
+			peers := addrStore.Peers()
+			Expect(peers).To(HaveLen(2))
+			for _, peer := range peers {
+				Expect(peer.LastSeen.IsZero()).To(BeFalse())
+				if peer.Addr.String() == bootstrap.String() {
+					Expect(peer.Bootstrap).To(BeTrue())
+					continue
+				}
+				Expect(peer.Bootstrap).To(BeFalse())
+				Expect(peer.LastSuccess.IsZero()).To(BeFalse())
+				Expect(peer.Failures).To(BeZero())
+			}
+
+			Expect(addrStore.Delete(addr)).ShouldNot(HaveOccurred())
+			Expect(addrStore.Peers()).To(HaveLen(1))
+		})
+
+		It("should persist the latest state of each darknode", func() {
+			r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+			addr := randomAddress(r)
+			sqlDB, err := sql.Open("sqlite3", ":memory:")
+			Expect(err).NotTo(HaveOccurred())
+			defer sqlDB.Close()
+			sqlDB.SetMaxOpenConns(1)
+			database := db.New(sqlDB, 0)
+			Expect(database.Init()).Should(Succeed())
+
+			addrStore, err := NewPersistent(kv.NewTable(kv.NewMemDB(kv.JSONCodec), "addresses"), nil, database)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrStore.Insert(addr)).ShouldNot(HaveOccurred())
+
+			n := 50
+			wg := new(sync.WaitGroup)
+			wg.Add(n)
+			for i := 0; i < n; i++ {
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					_, err := addrStore.Failure(addr)
+					Expect(err).ShouldNot(HaveOccurred())
+				}()
+			}
+			wg.Wait()
+
+			peers, err := database.Peers()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(peers).To(HaveLen(1))
+			Expect(peers[0].Failures).To(Equal(n))
+		})
+
+		It("should return the darknodes tagged with an epoch", func() {
+			r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+			current := randomAddress(r)
+			previous := randomAddress(r)
+			untagged := randomAddress(r)
+			addrStore := New(kv.NewTable(kv.NewMemDB(kv.JSONCodec), "addresses"), nil)
+			for _, addr := range []wire.Address{current, previous, untagged} {
+				Expect(addrStore.Insert(addr)).ShouldNot(HaveOccurred())
+			}
+			Expect(addrStore.Tag(previous, 1, []string{"shard"})).ShouldNot(HaveOccurred())
+			Expect(addrStore.Tag(current, 2, []string{"shard"})).ShouldNot(HaveOccurred())
+
+			addrs, err := addrStore.AddrsInEpoch(2)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrs).To(HaveLen(1))
+			Expect(addrs[0].String()).To(Equal(current.String()))
+
+			addrs, err = addrStore.AddrsInEpoch(3)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrs).To(BeEmpty())
+		})
 	})
 })
@@ -0,0 +1,135 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+
+	"github.com/renproject/aw/wire"
+	"github.com/renproject/darknode/engine"
+	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/id"
+	"github.com/renproject/pack"
+)
+
+// Epoch is the epoch of the network, as described by the System contract.
+// Nodes is the set of Darknodes which are part of the epoch.
+type Epoch struct {
+	Number   uint64
+	Hash     string
+	NumNodes int
+	Shards   []string
+	Nodes    map[id.Signatory]bool
+}
+
+// contains returns whether the Darknode with the given address is part of the
+// epoch.
+func (epoch Epoch) contains(addr wire.Address) bool {
+	signatory, err := addr.Signatory()
+	if err != nil {
+		return false
+	}
+	return epoch.Nodes[signatory]
+}
+
+// epochFromSystemState returns the epoch described by the given state of the
+// System contract.
+func epochFromSystemState(state engine.SystemState) Epoch {
+	shards := make([]string, 0, len(state.Shards.Primary)+len(state.Shards.Secondary)+len(state.Shards.Tertiary))
+	for _, shard := range state.Shards.Primary {
+		shards = append(shards, shard.Shard.String())
+	}
+	for _, shard := range state.Shards.Secondary {
+		shards = append(shards, shard.Shard.String())
+	}
+	for _, shard := range state.Shards.Tertiary {
+		shards = append(shards, shard.Shard.String())
+	}
+
+	nodes := make(map[id.Signatory]bool, len(state.Nodes))
+	for _, node := range state.Nodes {
+		nodes[node.Node] = true
+	}
+
+	numNodes := int(state.Epoch.NumNodes)
+	if numNodes == 0 {
+		numNodes = len(state.Nodes)
+	}
+	return Epoch{
+		Number:   uint64(state.Epoch.Number),
+		Hash:     state.Epoch.Hash.String(),
+		NumNodes: numNodes,
+		Shards:   shards,
+		Nodes:    nodes,
+	}
+}
+
+// queryEpoch asks the Bootstrap nodes, in a random order, for the state of the
+// System contract and returns the epoch from the first valid response.
+func (updater *Updater) queryEpoch(ctx context.Context) (Epoch, error) {
+	params, err := json.Marshal(jsonrpc.ParamsQueryBlockState{})
+	if err != nil {
+		return Epoch{}, fmt.Errorf("cannot marshal query block state params: %v", err)
+	}
+	addrs, err := updater.multiStore.BootstrapAll()
+	if err != nil {
+		return Epoch{}, fmt.Errorf("cannot get query addresses: %v", err)
+	}
+
+	shuffled := make([]wire.Address, len(addrs))
+	copy(shuffled, addrs)
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	err = fmt.Errorf("no bootstrap nodes")
+	for _, addr := range shuffled {
+		var epoch Epoch
+		epoch, err = updater.queryEpochFrom(ctx, addr, params)
+		if err == nil {
+			return epoch, nil
+		}
+		updater.logger.Warnf("[updater] cannot query epoch from %v: %v", addr.String(), err)
+	}
+	return Epoch{}, err
+}
+
+// queryEpochFrom asks the given Darknode for the state of the System contract.
+func (updater *Updater) queryEpochFrom(ctx context.Context, addr wire.Address, params json.RawMessage) (Epoch, error) {
+	addrString, err := updater.urls.URL(addr)
+	if err != nil {
+		return Epoch{}, err
+	}
+	request := jsonrpc.Request{
+		Version: "2.0",
+		ID:      rand.Int31(),
+		Method:  jsonrpc.MethodQueryBlockState,
+		Params:  params,
+	}
+	response, err := updater.client.SendRequest(ctx, addrString, request, nil)
+	if err != nil {
+		return Epoch{}, err
+	}
+	if response.Error != nil {
+		return Epoch{}, fmt.Errorf("%v", response.Error.Message)
+	}
+
+	raw, err := json.Marshal(response.Result)
+	if err != nil {
+		return Epoch{}, fmt.Errorf("cannot marshal queryBlockState result: %v", err)
+	}
+	var resp jsonrpc.ResponseQueryBlockState
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return Epoch{}, fmt.Errorf("cannot unmarshal queryBlockState result: %v", err)
+	}
+	systemContract := resp.State.Get("System")
+	if systemContract == nil {
+		return Epoch{}, fmt.Errorf("system contract is nil")
+	}
+	var state engine.SystemState
+	if err := pack.Decode(&state, systemContract); err != nil {
+		return Epoch{}, fmt.Errorf("cannot decode system state: %v", err)
+	}
+	return epochFromSystemState(state), nil
+}
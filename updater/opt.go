@@ -0,0 +1,52 @@
+package updater
+
+import "time"
+
+// Enumerate default options.
+var (
+	DefaultPollRate      = 5 * time.Minute
+	DefaultProbeInterval = time.Minute
+	DefaultMaxFailures   = 5
+)
+
+// Options to configure the precise behaviour of the updater.
+type Options struct {
+	// PollRate is how often the Bootstrap nodes are asked for their peers.
+	PollRate time.Duration
+	// ProbeInterval is how often every known Darknode is checked for
+	// liveness. A zero value disables probing.
+	ProbeInterval time.Duration
+	// MaxFailures is the number of consecutive failed probes after which a
+	// Darknode is evicted from the store. Bootstrap nodes are never evicted. A
+	// zero value disables eviction.
+	MaxFailures int
+}
+
+// DefaultOptions returns new options with default configurations that should
+// work for the majority of use cases.
+func DefaultOptions() Options {
+	return Options{
+		PollRate:      DefaultPollRate,
+		ProbeInterval: DefaultProbeInterval,
+		MaxFailures:   DefaultMaxFailures,
+	}
+}
+
+// WithPollRate returns new options with the given poll rate.
+func (opts Options) WithPollRate(pollRate time.Duration) Options {
+	opts.PollRate = pollRate
+	return opts
+}
+
+// WithProbeInterval returns new options with the given probe interval.
+func (opts Options) WithProbeInterval(probeInterval time.Duration) Options {
+	opts.ProbeInterval = probeInterval
+	return opts
+}
+
+// WithMaxFailures returns new options with the given number of failed probes
+// after which a Darknode is evicted.
+func (opts Options) WithMaxFailures(maxFailures int) Options {
+	opts.MaxFailures = maxFailures
+	return opts
+}
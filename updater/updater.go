@@ -3,14 +3,12 @@ package updater
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"math/rand"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/renproject/aw/wire"
 	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/lightnode/dispatcher"
 	"github.com/renproject/lightnode/http"
 	"github.com/renproject/lightnode/store"
 	"github.com/renproject/phi"
@@ -22,32 +20,60 @@ import (
 // peers of a random subset of the already known darknodes and adding any new
 // darknodes to a store. This store is shared by the `Dispatcher`, which needs
 // to know about the darknodes in the network.
+//
+// The `Updater` also periodically probes every known darknode, and evicts the
+// darknodes which repeatedly fail to respond so that darknodes which have left
+// the network are no longer used.
+//
+// Each time it polls the Bootstrap nodes, the `Updater` also queries the state
+// of the System contract to learn the current epoch. The Darknodes it finds
+// which are part of the epoch are tagged with the epoch and its shards, and the
+// `Dispatcher` is told when the epoch changes so that it follows the current
+// shard.
 type Updater struct {
 	logger     logrus.FieldLogger
 	multiStore store.MultiAddrStore
 	client     http.Client
-	pollRate   time.Duration
+	urls       http.URLResolver
+	dispatcher phi.Sender
+	options    Options
+
+	// epoch is the last known epoch. It is only accessed by the goroutine
+	// running the `Updater`.
+	epoch *Epoch
 }
 
 // New constructs a new `Updater`. If the given store of multi addresses is
 // empty, then the constructed `Updater` will be useless since it will not know
 // any darknodes to query. Therefore the given store must contain some number
-// of bootstrap addresses.
-func New(logger logrus.FieldLogger, multiStore store.MultiAddrStore, pollRate, timeout time.Duration) Updater {
+// of bootstrap addresses. The darknodes are queried using the given client, at
+// the URLs returned by the given resolver. Epoch changes are sent to the given
+// dispatcher, which may be nil.
+func New(logger logrus.FieldLogger, multiStore store.MultiAddrStore, client http.Client, urls http.URLResolver, dispatcher phi.Sender, options Options) Updater {
 	return Updater{
 		logger:     logger,
 		multiStore: multiStore,
-		pollRate:   pollRate,
-		client:     http.NewClient(timeout),
+		client:     client,
+		urls:       urls,
+		dispatcher: dispatcher,
+		options:    options,
 	}
 }
 
 // Run starts the `Updater` making requests to the darknodes and updating its
 // store. This function is blocking.
 func (updater *Updater) Run(ctx context.Context) {
-	ticker := time.NewTicker(updater.pollRate)
+	ticker := time.NewTicker(updater.options.PollRate)
 	defer ticker.Stop()
 
+	// A nil channel never receives, which disables probing.
+	var probe <-chan time.Time
+	if updater.options.ProbeInterval > 0 {
+		probeTicker := time.NewTicker(updater.options.ProbeInterval)
+		defer probeTicker.Stop()
+		probe = probeTicker.C
+	}
+
 	updater.updateMultiAddress(ctx)
 	for {
 		select {
@@ -55,12 +81,14 @@ func (updater *Updater) Run(ctx context.Context) {
 			return
 		case <-ticker.C:
 			updater.updateMultiAddress(ctx)
+		case <-probe:
+			updater.probe(ctx)
 		}
 	}
 }
 
 func (updater *Updater) updateMultiAddress(ctx context.Context) {
-	queryCtx, cancel := context.WithTimeout(ctx, updater.pollRate)
+	queryCtx, cancel := context.WithTimeout(ctx, updater.options.PollRate)
 	defer cancel()
 
 	params, err := json.Marshal(jsonrpc.ParamsQueryPeers{})
@@ -74,6 +102,25 @@ func (updater *Updater) updateMultiAddress(ctx context.Context) {
 		return
 	}
 
+	// The System contract lists the Darknodes of the epoch, so only the
+	// Darknodes found among them are tagged with the epoch and its shards.
+	// The dispatcher uses every known Darknode until some are tagged.
+	epoch, err := updater.queryEpoch(queryCtx)
+	knownEpoch := err == nil
+	if knownEpoch {
+		updater.updateEpoch(epoch)
+	} else {
+		updater.logger.Warnf("[updater] cannot query epoch: %v", err)
+	}
+	tag := func(addr wire.Address) {
+		if !knownEpoch || !epoch.contains(addr) {
+			return
+		}
+		if err := updater.multiStore.Tag(addr, epoch.Number, epoch.Shards); err != nil {
+			updater.logger.Errorf("[updater] cannot tag %v with epoch %v: %v", addr.String(), epoch.Number, err)
+		}
+	}
+
 	// Collect all peers connected to Bootstrap nodes.
 	phi.ParForAll(addrs, func(i int) {
 		multi := addrs[i]
@@ -86,22 +133,23 @@ func (updater *Updater) updateMultiAddress(ctx context.Context) {
 			Params:  params,
 		}
 
-		addrParts := strings.Split(addrs[i].Value, ":")
-		if len(addrParts) != 2 {
-			updater.logger.Errorf("[updater] invalid address value=%v", addrs[i].Value)
-			return
-		}
-		port, err := strconv.Atoi(addrParts[1])
+		addrString, err := updater.urls.URL(multi)
 		if err != nil {
-			updater.logger.Errorf("[updater] invalid port=%v", addrParts[1])
+			updater.logger.Errorf("[updater] %v", err)
 			return
 		}
-		addrString := fmt.Sprintf("http://%s:%v", addrParts[0], port+1)
 		response, err := updater.client.SendRequest(queryCtx, addrString, request, nil)
 		if err != nil {
 			updater.logger.Warnf("[updater] cannot connect to node %v: %v", multi.String(), err)
+			if _, err := updater.multiStore.Failure(multi); err != nil {
+				updater.logger.Errorf("[updater] cannot record failure of %v: %v", multi.String(), err)
+			}
 			return
 		}
+		if err := updater.multiStore.Success(multi); err != nil {
+			updater.logger.Errorf("[updater] cannot record success of %v: %v", multi.String(), err)
+		}
+		tag(multi)
 
 		// Parse the response
 		raw, err := json.Marshal(response.Result)
@@ -124,6 +172,7 @@ func (updater *Updater) updateMultiAddress(ctx context.Context) {
 				updater.logger.Errorf("[updater] failed to add multi-address to store: %v", err)
 				return
 			}
+			tag(addr)
 		}
 	})
 
@@ -135,3 +184,77 @@ func (updater *Updater) updateMultiAddress(ctx context.Context) {
 	}
 	updater.logger.Infof("connected to %v nodes", size)
 }
+
+// updateEpoch records the given epoch and, if it differs from the last known
+// epoch, tells the dispatcher about the change.
+func (updater *Updater) updateEpoch(epoch Epoch) {
+	if updater.epoch != nil && updater.epoch.Number == epoch.Number && updater.epoch.Hash == epoch.Hash {
+		return
+	}
+	if updater.epoch == nil {
+		updater.logger.Infof("[updater] network is in epoch %v with %v nodes", epoch.Number, epoch.NumNodes)
+	} else {
+		updater.logger.Infof("[updater] network moved from epoch %v to epoch %v with %v nodes", updater.epoch.Number, epoch.Number, epoch.NumNodes)
+	}
+	updater.epoch = &epoch
+
+	if updater.dispatcher == nil {
+		return
+	}
+	msg := dispatcher.EpochChange{
+		Number:   epoch.Number,
+		NumNodes: epoch.NumNodes,
+		Shards:   epoch.Shards,
+	}
+	if ok := updater.dispatcher.Send(msg); !ok {
+		updater.logger.Errorf("[updater] cannot notify dispatcher of epoch %v", epoch.Number)
+	}
+}
+
+// probe sends a request to every known darknode, and evicts the darknodes which
+// have failed too many probes in a row.
+func (updater *Updater) probe(ctx context.Context) {
+	probeCtx, cancel := context.WithTimeout(ctx, updater.options.ProbeInterval)
+	defer cancel()
+
+	params, err := json.Marshal(jsonrpc.ParamsQueryNumPeers{})
+	if err != nil {
+		updater.logger.Errorf("[updater] cannot marshal query num peers params: %v", err)
+		return
+	}
+
+	peers := updater.multiStore.Peers()
+	phi.ParForAll(peers, func(i int) {
+		peer := peers[i]
+		request := jsonrpc.Request{
+			Version: "2.0",
+			ID:      rand.Int31(),
+			Method:  jsonrpc.MethodQueryNumPeers,
+			Params:  params,
+		}
+		addrString, err := updater.urls.URL(peer.Addr)
+		if err == nil {
+			_, err = updater.client.SendRequest(probeCtx, addrString, request, nil)
+		}
+		if err == nil {
+			if err := updater.multiStore.Success(peer.Addr); err != nil {
+				updater.logger.Errorf("[updater] cannot record success of %v: %v", peer.Addr.String(), err)
+			}
+			return
+		}
+
+		failures, err := updater.multiStore.Failure(peer.Addr)
+		if err != nil {
+			updater.logger.Errorf("[updater] cannot record failure of %v: %v", peer.Addr.String(), err)
+			return
+		}
+		if peer.Bootstrap || updater.options.MaxFailures <= 0 || failures < updater.options.MaxFailures {
+			return
+		}
+		if err := updater.multiStore.Delete(peer.Addr); err != nil {
+			updater.logger.Errorf("[updater] cannot evict %v: %v", peer.Addr.String(), err)
+			return
+		}
+		updater.logger.Infof("[updater] evicted %v after %v failed probes", peer.Addr.String(), failures)
+	})
+}
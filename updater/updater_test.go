@@ -2,8 +2,10 @@ package updater_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -13,19 +15,28 @@ import (
 	. "github.com/renproject/lightnode/testutils"
 
 	"github.com/renproject/aw/wire"
+	"github.com/renproject/darknode/engine"
+	"github.com/renproject/id"
 	"github.com/renproject/kv"
+	lhttp "github.com/renproject/lightnode/http"
 	"github.com/renproject/lightnode/store"
 	"github.com/renproject/lightnode/updater"
+	"github.com/renproject/pack"
 	"github.com/sirupsen/logrus"
 )
 
 func initUpdater(ctx context.Context, bootstrapAddrs []wire.Address, pollRate, timeout time.Duration) store.MultiAddrStore {
+	options := updater.DefaultOptions().WithPollRate(pollRate)
+	return initUpdaterWithOptions(ctx, bootstrapAddrs, nil, timeout, options)
+}
+
+func initUpdaterWithOptions(ctx context.Context, bootstrapAddrs, addrs []wire.Address, timeout time.Duration, options updater.Options) store.MultiAddrStore {
 	logger := logrus.New()
 	multiStore := store.New(kv.NewTable(kv.NewMemDB(kv.JSONCodec), "addresses"), bootstrapAddrs)
-	for _, addr := range bootstrapAddrs {
+	for _, addr := range append(bootstrapAddrs, addrs...) {
 		multiStore.Insert(addr)
 	}
-	updater := updater.New(logger, multiStore, pollRate, timeout)
+	updater := updater.New(logger, multiStore, lhttp.NewClient(timeout), lhttp.NewURLResolver(lhttp.DefaultURLOptions()), nil, options)
 
 	go updater.Run(ctx)
 
@@ -64,6 +75,49 @@ func initDarknodes(ctx context.Context, n int) []*MockDarknode {
 	return dns
 }
 
+// newSignedAddress returns a signed multi-address with the given value.
+func newSignedAddress(value string) wire.Address {
+	addr := wire.NewUnsignedAddress(wire.TCP, value, uint64(time.Now().Unix()))
+	if err := addr.Sign(id.NewPrivKey()); err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+// initEpochDarknode starts a server which returns the given peers, and a System
+// contract state in epoch 7 with the given Darknodes.
+func initEpochDarknode(peers []wire.Address, nodes []wire.Address) *httptest.Server {
+	state := MockSystemState()
+	state.Epoch.Number = 7
+	for _, node := range nodes {
+		state.Nodes = append(state.Nodes, engine.SystemStateNode{Node: mustSignatoryOf(node)})
+	}
+	system, err := pack.Encode(state)
+	if err != nil {
+		panic(err)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req jsonrpc.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var result interface{}
+		switch req.Method {
+		case jsonrpc.MethodQueryPeers:
+			values := make([]string, len(peers))
+			for i := range peers {
+				values[i] = peers[i].String()
+			}
+			result = jsonrpc.ResponseQueryPeers{Peers: values}
+		case jsonrpc.MethodQueryBlockState:
+			result = jsonrpc.ResponseQueryBlockState{State: pack.Typed{pack.NewStructField("System", system)}}
+		}
+		json.NewEncoder(w).Encode(jsonrpc.Response{Version: "2.0", ID: req.ID, Result: result})
+	}))
+}
+
 var _ = Describe("Updater", func() {
 	Context("When running", func() {
 		It("Should periodically query the darknodes", func() {
@@ -83,4 +137,92 @@ var _ = Describe("Updater", func() {
 			}, 5*time.Second).Should(Equal(13))
 		})
 	})
+
+	Context("When the network is in an epoch", func() {
+		It("Should only tag the darknodes which are part of the epoch", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			bootstrap := newSignedAddress("127.0.0.1:5100")
+			member := newSignedAddress("127.0.0.1:5102")
+			outsider := newSignedAddress("127.0.0.1:5104")
+			server := initEpochDarknode([]wire.Address{member, outsider}, []wire.Address{bootstrap, member})
+			defer server.Close()
+
+			// Every darknode is reached through the mock server.
+			urls := lhttp.NewURLResolver(lhttp.DefaultURLOptions().WithOverrides(map[string]string{
+				bootstrap.Value: server.URL,
+				member.Value:    server.URL,
+				outsider.Value:  server.URL,
+			}))
+			multiStore := store.New(kv.NewTable(kv.NewMemDB(kv.JSONCodec), "addresses"), []wire.Address{bootstrap})
+			Expect(multiStore.Insert(bootstrap)).To(Succeed())
+			options := updater.DefaultOptions().WithPollRate(100 * time.Millisecond)
+			updater := updater.New(logrus.New(), multiStore, lhttp.NewClient(time.Second), urls, nil, options)
+			go updater.Run(ctx)
+
+			Eventually(func() int {
+				size, err := multiStore.Size()
+				Expect(err).ShouldNot(HaveOccurred())
+				return size
+			}, 5*time.Second).Should(Equal(3))
+			Eventually(func() []string {
+				addrs, err := multiStore.AddrsInEpoch(7)
+				Expect(err).ShouldNot(HaveOccurred())
+				values := make([]string, len(addrs))
+				for i := range addrs {
+					values[i] = addrs[i].Value
+				}
+				return values
+			}, 5*time.Second).Should(ConsistOf(bootstrap.Value, member.Value))
+		})
+	})
+
+	Context("When a darknode stops responding", func() {
+		It("Should evict it after too many failed probes", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Requests are sent to the port after the one in the address, so
+			// the first darknode is reached through the address of the second.
+			darknodes := initDarknodes(ctx, 2)
+			offline := wire.NewUnsignedAddress(wire.TCP, "0.0.0.0:4500", uint64(time.Now().Unix()))
+			Expect(offline.Sign(id.NewPrivKey())).To(Succeed())
+			options := updater.DefaultOptions().
+				WithPollRate(time.Minute).
+				WithProbeInterval(50 * time.Millisecond).
+				WithMaxFailures(2)
+			multiStore := initUpdaterWithOptions(ctx, []wire.Address{darknodes[0].Me}, []wire.Address{offline}, time.Second, options)
+
+			Eventually(func() []string {
+				addrs, err := multiStore.AddrsAll()
+				Expect(err).ShouldNot(HaveOccurred())
+				values := make([]string, len(addrs))
+				for i := range addrs {
+					values[i] = addrs[i].Value
+				}
+				return values
+			}, 5*time.Second).ShouldNot(ContainElement(offline.Value))
+
+			// Bootstrap nodes are never evicted.
+			_, err := multiStore.Get(mustSignatory(darknodes[0].Me))
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
 })
+
+func mustSignatoryOf(addr wire.Address) id.Signatory {
+	signatory, err := addr.Signatory()
+	if err != nil {
+		panic(err)
+	}
+	return signatory
+}
+
+func mustSignatory(addr wire.Address) string {
+	signatory, err := addr.Signatory()
+	if err != nil {
+		panic(err)
+	}
+	return signatory.String()
+}
@@ -0,0 +1,129 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/renproject/darknode/tx"
+	"github.com/renproject/lightnode/admin"
+)
+
+// Enumerate the admin methods served by the `Manager`.
+const (
+	MethodWatcherList        = "watcher_list"
+	MethodWatcherPause       = "watcher_pause"
+	MethodWatcherResume      = "watcher_resume"
+	MethodWatcherResetCursor = "watcher_resetCursor"
+	MethodWatcherQuarantine  = "watcher_quarantine"
+)
+
+// DefaultQuarantineLimit is the number of quarantined burns returned by the
+// `watcher_quarantine` admin method if no limit is given.
+var DefaultQuarantineLimit = 100
+
+// ParamsWatcher are the params of the admin methods which control a single
+// watcher.
+type ParamsWatcher struct {
+	Selector string `json:"selector"`
+}
+
+// ParamsWatcherResetCursor are the params of the `watcher_resetCursor` admin
+// method.
+type ParamsWatcherResetCursor struct {
+	Selector string  `json:"selector"`
+	Height   *uint64 `json:"height"`
+}
+
+// ParamsWatcherQuarantine are the params of the `watcher_quarantine` admin
+// method.
+type ParamsWatcherQuarantine struct {
+	Selector string `json:"selector"`
+	Offset   *int   `json:"offset"`
+	Limit    *int   `json:"limit"`
+}
+
+// RegisterAdmin registers the admin methods for inspecting and controlling the
+// watchers with the admin server. The methods which control a watcher return
+// its status afterwards, and the quarantined burns of a watcher can be listed.
+func (manager *Manager) RegisterAdmin(server *admin.Server) {
+	server.Register(MethodWatcherList, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		return manager.Statuses(), nil
+	})
+
+	server.Register(MethodWatcherPause, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		watcher, err := manager.watcherFromParams(raw)
+		if err != nil {
+			return nil, err
+		}
+		watcher.Pause()
+		return watcher.Status(), nil
+	})
+
+	server.Register(MethodWatcherResume, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		watcher, err := manager.watcherFromParams(raw)
+		if err != nil {
+			return nil, err
+		}
+		watcher.Resume()
+		return watcher.Status(), nil
+	})
+
+	server.Register(MethodWatcherResetCursor, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var params ParamsWatcherResetCursor
+		if err := admin.UnmarshalParams(raw, &params); err != nil {
+			return nil, err
+		}
+		if params.Height == nil {
+			return nil, admin.InvalidParams("missing height")
+		}
+		watcher, err := manager.lookup(params.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if err := watcher.ResetCursor(*params.Height); err != nil {
+			return nil, err
+		}
+		return watcher.Status(), nil
+	})
+
+	server.Register(MethodWatcherQuarantine, func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var params ParamsWatcherQuarantine
+		if err := admin.UnmarshalParams(raw, &params); err != nil {
+			return nil, err
+		}
+		offset, limit := 0, DefaultQuarantineLimit
+		if params.Offset != nil {
+			offset = *params.Offset
+		}
+		if params.Limit != nil {
+			limit = *params.Limit
+		}
+		if offset < 0 || limit < 0 {
+			return nil, admin.InvalidParams("invalid offset or limit")
+		}
+		watcher, err := manager.lookup(params.Selector)
+		if err != nil {
+			return nil, err
+		}
+		return watcher.Quarantined(offset, limit)
+	})
+}
+
+func (manager *Manager) watcherFromParams(raw json.RawMessage) (Watcher, error) {
+	var params ParamsWatcher
+	if err := admin.UnmarshalParams(raw, &params); err != nil {
+		return Watcher{}, err
+	}
+	return manager.lookup(params.Selector)
+}
+
+func (manager *Manager) lookup(selector string) (Watcher, error) {
+	if selector == "" {
+		return Watcher{}, admin.InvalidParams("missing selector")
+	}
+	watcher, ok := manager.Watcher(tx.Selector(selector))
+	if !ok {
+		return Watcher{}, admin.InvalidParams("%v is not watched", selector)
+	}
+	return watcher, nil
+}
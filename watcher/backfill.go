@@ -0,0 +1,146 @@
+package watcher
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/lightnode/db"
+	"github.com/renproject/pack"
+)
+
+// BackfillStatus is the outcome of replaying a burn.
+type BackfillStatus uint8
+
+const (
+	// BackfillStatusKnown means the burn had already been forwarded, so it
+	// was skipped.
+	BackfillStatusKnown BackfillStatus = iota
+	// BackfillStatusPending means the burn would have been submitted, but
+	// the backfill was a dry run.
+	BackfillStatusPending
+	// BackfillStatusSubmitted means the burn was submitted.
+	BackfillStatusSubmitted
+	// BackfillStatusFailed means the burn could not be submitted.
+	BackfillStatusFailed
+)
+
+// String implements the `fmt.Stringer` interface.
+func (status BackfillStatus) String() string {
+	switch status {
+	case BackfillStatusKnown:
+		return "known"
+	case BackfillStatusPending:
+		return "pending"
+	case BackfillStatusSubmitted:
+		return "submitted"
+	case BackfillStatusFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("unknown(%d)", status)
+	}
+}
+
+// BackfillResult is a burn which was replayed by a backfill.
+type BackfillResult struct {
+	Burn   BurnInfo
+	Params jsonrpc.ParamsSubmitTx
+	Status BackfillStatus
+	Error  error
+}
+
+// Backfill replays the burns which the burn log fetcher returns for the given
+// range, so that burns which were missed while the watcher was down, or because
+// an RPC returned bad data, are forwarded without touching the cursor. Burns
+// which have already been forwarded are skipped. If dryRun is true, nothing is
+// submitted or stored. It returns the result of every burn, even if fetching
+// the logs fails part way.
+func (watcher Watcher) Backfill(ctx context.Context, from, to uint64, dryRun bool) ([]BackfillResult, error) {
+	c, err := watcher.burnLogFetcher.FetchBurnLogs(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("fetching burns from=%v to=%v: %v", from, to, err)
+	}
+
+	results := []BackfillResult{}
+	for res := range c {
+		if res.Error != nil {
+			return results, fmt.Errorf("iterating burns from=%v to=%v: %v", from, to, res.Error)
+		}
+		burn := res.Result
+		result := BackfillResult{Burn: burn}
+		if burn.Pruned {
+			result.Status = BackfillStatusFailed
+			result.Error = fmt.Errorf("transaction not available")
+			results = append(results, result)
+			continue
+		}
+
+		result.Params, result.Error = watcher.burnToTx(burn.Txid, burn.Amount, burn.ToBytes, burn.Nonce)
+		if result.Error != nil {
+			result.Status = BackfillStatusFailed
+			results = append(results, result)
+			continue
+		}
+
+		known, err := watcher.isKnown(result.Params, burn.Nonce)
+		if err != nil {
+			return results, fmt.Errorf("checking burn with nonce=%v: %v", pack.NewU256(burn.Nonce), err)
+		}
+		switch {
+		case known:
+			result.Status = BackfillStatusKnown
+		case dryRun:
+			result.Status = BackfillStatusPending
+		default:
+			result.Status, result.Error = watcher.submit(ctx, burn)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// submit forwards the burn in the same way as the watcher does, and records
+// the result. A quarantined burn is released once it has been submitted.
+func (watcher Watcher) submit(ctx context.Context, burn BurnInfo) (BackfillStatus, error) {
+	params, err := watcher.burnToParams(burn.Txid, burn.Amount, burn.ToBytes, burn.Nonce)
+	if err != nil {
+		return BackfillStatusFailed, err
+	}
+	response := watcher.resolver.SubmitTx(ctx, 0, &params, nil)
+	if response.Error != nil {
+		watcher.recordBurn(burn, params.Tx.Hash.String(), db.BurnStatusFailed, response.Error.Message)
+		return BackfillStatusFailed, fmt.Errorf("submitting tx: %v", response.Error.Message)
+	}
+	watcher.recordBurn(burn, params.Tx.Hash.String(), db.BurnStatusSubmitted, "")
+
+	// The burn is no longer quarantined once it has been submitted.
+	watcher.succeeded(burn)
+	if err := watcher.release(burn); err != nil {
+		watcher.logger.Errorf("[watcher] cannot release quarantined burn for %v with nonce=%v: %v", watcher.selector.String(), pack.NewU256(burn.Nonce), err)
+	}
+	return BackfillStatusSubmitted, nil
+}
+
+// isKnown returns whether the burn has already been forwarded, either because
+// its transaction is stored or because it was recorded as submitted.
+func (watcher Watcher) isKnown(params jsonrpc.ParamsSubmitTx, nonce pack.Bytes32) (bool, error) {
+	if watcher.db == nil {
+		return false, nil
+	}
+	_, err := watcher.db.Tx(params.Tx.Hash)
+	if err == nil {
+		return true, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+	burn, err := watcher.db.Burn(watcher.selector.String(), pack.NewU256(nonce).String())
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return burn.Status == db.BurnStatusSubmitted, nil
+}
@@ -0,0 +1,138 @@
+package watcher
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/renproject/darknode/jsonrpc"
+	"github.com/renproject/lightnode/db"
+	"github.com/renproject/pack"
+)
+
+// DefaultMaxAttempts is the number of times the Darknodes can reject a burn
+// before it is quarantined.
+var DefaultMaxAttempts = 10
+
+// Backpressure decides what a `Watcher` does when the Darknodes reject a burn.
+// While a burn is retried, the watcher stops at it and tries again on its next
+// poll. Once a burn is no longer retried, it is quarantined and the watcher
+// moves on to the burns after it. Burns which cannot be submitted because the
+// Darknodes are unreachable or applying backpressure are always retried, and
+// are not counted as attempts.
+type Backpressure interface {
+	// Retry returns whether the burn should be retried, given the number of
+	// times it has been rejected, including this time, and the last error.
+	Retry(burn BurnInfo, attempts int, err error) bool
+}
+
+// MaxAttempts is a `Backpressure` which retries a burn until it has been
+// rejected the given number of times. A value of zero or less retries burns
+// forever.
+type MaxAttempts int
+
+// Retry implements the `Backpressure` interface.
+func (maxAttempts MaxAttempts) Retry(burn BurnInfo, attempts int, err error) bool {
+	return maxAttempts <= 0 || attempts < int(maxAttempts)
+}
+
+// WithBackpressure returns the watcher with the given backpressure.
+func (watcher Watcher) WithBackpressure(backpressure Backpressure) Watcher {
+	watcher.backpressure = backpressure
+	return watcher
+}
+
+// Quarantined returns the quarantined burns of the watcher with the given
+// pagination options, from the most recent block. Quarantined burns can be
+// submitted again with a backfill over their blocks.
+func (watcher Watcher) Quarantined(offset, limit int) ([]db.QuarantinedBurn, error) {
+	if watcher.db == nil {
+		return nil, fmt.Errorf("%v has no database", watcher.selector.String())
+	}
+	return watcher.db.QuarantinedBurns(watcher.selector.String(), offset, limit)
+}
+
+// rejected returns whether the error means that the burn was rejected. Errors
+// from reaching the Darknodes or the host chain of the burn, such as timeouts,
+// backpressure or a lack of quorum, are reported by the lightnode as internal
+// errors.
+func rejected(err *jsonrpc.Error) bool {
+	return err.Code != jsonrpc.ErrorCodeInternal
+}
+
+// failed records a rejected attempt to submit the burn and returns whether it
+// should be retried. Attempts are only tracked in memory, so they start again
+// from zero when the lightnode restarts.
+func (watcher Watcher) failed(burn BurnInfo, err error) (int, bool) {
+	nonce := pack.NewU256(burn.Nonce).String()
+
+	watcher.state.mu.Lock()
+	watcher.state.attempts[nonce]++
+	attempts := watcher.state.attempts[nonce]
+	watcher.state.mu.Unlock()
+
+	return attempts, watcher.backpressure.Retry(burn, attempts, err)
+}
+
+// succeeded forgets the failed attempts to submit the burn.
+func (watcher Watcher) succeeded(burn BurnInfo) {
+	watcher.state.mu.Lock()
+	defer watcher.state.mu.Unlock()
+	delete(watcher.state.attempts, pack.NewU256(burn.Nonce).String())
+}
+
+// isQuarantined returns whether the burn has been quarantined, in which case
+// the watcher skips it until it is released by a backfill.
+func (watcher Watcher) isQuarantined(burn BurnInfo) (bool, error) {
+	nonce := pack.NewU256(burn.Nonce).String()
+	watcher.state.mu.Lock()
+	quarantined := watcher.state.quarantined[nonce]
+	watcher.state.mu.Unlock()
+	if quarantined || watcher.db == nil {
+		return quarantined, nil
+	}
+
+	_, err := watcher.db.QuarantinedBurn(watcher.selector.String(), nonce)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// release forgets that the burn has been quarantined.
+func (watcher Watcher) release(burn BurnInfo) error {
+	nonce := pack.NewU256(burn.Nonce).String()
+	watcher.state.mu.Lock()
+	delete(watcher.state.quarantined, nonce)
+	watcher.state.mu.Unlock()
+	if watcher.db == nil {
+		return nil
+	}
+	return watcher.db.DeleteQuarantinedBurn(watcher.selector.String(), nonce)
+}
+
+// quarantine stores the burn so that operators can inspect it, and forgets its
+// failed attempts. It returns an error if the burn cannot be stored, in which
+// case the watcher must not move past it.
+func (watcher Watcher) quarantine(burn BurnInfo, txHash string, attempts int, reason string) error {
+	if watcher.db != nil {
+		err := watcher.db.InsertQuarantinedBurn(db.QuarantinedBurn{
+			Selector: watcher.selector.String(),
+			Nonce:    pack.NewU256(burn.Nonce).String(),
+			Txid:     burn.Txid.String(),
+			Block:    uint64(burn.BlockNumber),
+			TxHash:   txHash,
+			Attempts: attempts,
+			Error:    reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	watcher.state.mu.Lock()
+	watcher.state.quarantined[pack.NewU256(burn.Nonce).String()] = true
+	watcher.state.mu.Unlock()
+
+	watcher.succeeded(burn)
+	watcher.recordBurn(burn, txHash, db.BurnStatusQuarantined, reason)
+	return nil
+}
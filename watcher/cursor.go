@@ -0,0 +1,150 @@
+package watcher
+
+import (
+	"fmt"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/renproject/lightnode/db"
+)
+
+// A cursor stores how far a watcher has checked its chain, along with the
+// checkpoints which are used to detect reorgs.
+type cursor interface {
+	// lastChecked returns the last checked height. It returns false if the
+	// cursor has not been initialised.
+	lastChecked() (uint64, bool, error)
+
+	// checkpoints returns the recorded checkpoints, from the most recent.
+	checkpoints() ([]checkpoint, error)
+
+	// advance records that the watcher has checked up to the checkpoint.
+	advance(cp checkpoint) error
+
+	// rewind drops the checkpoints above the given checkpoint, which becomes
+	// the last checked height.
+	rewind(cp checkpoint) error
+}
+
+// legacyKey returns the key that is used to store the last checked block in
+// redis.
+func legacyKey(selector string) string {
+	return fmt.Sprintf("%v_lastCheckedBlock", selector)
+}
+
+// redisCursor stores the cursor in redis. It is used when the watcher is not
+// given a database.
+type redisCursor struct {
+	selector string
+	cache    redis.Cmdable
+}
+
+func (c redisCursor) checkpointsKey() string {
+	return fmt.Sprintf("%v_checkpoints", c.selector)
+}
+
+func (c redisCursor) lastChecked() (uint64, bool, error) {
+	last, err := c.cache.Get(legacyKey(c.selector)).Uint64()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	return last, err == nil, err
+}
+
+func (c redisCursor) checkpoints() ([]checkpoint, error) {
+	values, err := c.cache.LRange(c.checkpointsKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := make([]checkpoint, 0, len(values))
+	for _, value := range values {
+		cp, err := decodeCheckpoint(value)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, nil
+}
+
+func (c redisCursor) advance(cp checkpoint) error {
+	if cp.hash != "" {
+		if err := c.cache.LPush(c.checkpointsKey(), cp.String()).Err(); err != nil {
+			return err
+		}
+		if err := c.cache.LTrim(c.checkpointsKey(), 0, maxCheckpoints-1).Err(); err != nil {
+			return err
+		}
+	}
+	return c.cache.Set(legacyKey(c.selector), cp.height, 0).Err()
+}
+
+func (c redisCursor) rewind(cp checkpoint) error {
+	checkpoints, err := c.checkpoints()
+	if err != nil {
+		return err
+	}
+	values := []interface{}{}
+	if cp.hash != "" {
+		values = append(values, cp.String())
+	}
+	for _, older := range checkpoints {
+		if older.height < cp.height {
+			values = append(values, older.String())
+		}
+	}
+	if err := c.cache.Del(c.checkpointsKey()).Err(); err != nil {
+		return err
+	}
+	if len(values) > 0 {
+		if err := c.cache.RPush(c.checkpointsKey(), values...).Err(); err != nil {
+			return err
+		}
+	}
+	return c.cache.Set(legacyKey(c.selector), cp.height, 0).Err()
+}
+
+// dbCursor stores the cursor in the database, so that the watcher resumes from
+// exactly where it stopped. Watchers which previously stored their cursor in
+// redis resume from it until their first checkpoint is recorded.
+type dbCursor struct {
+	selector string
+	database db.DB
+	cache    redis.Cmdable
+}
+
+func (c dbCursor) lastChecked() (uint64, bool, error) {
+	checkpoints, err := c.database.Checkpoints(c.selector, 1)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(checkpoints) > 0 {
+		return checkpoints[0].Height, true, nil
+	}
+	return redisCursor{selector: c.selector, cache: c.cache}.lastChecked()
+}
+
+func (c dbCursor) checkpoints() ([]checkpoint, error) {
+	stored, err := c.database.Checkpoints(c.selector, maxCheckpoints)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := make([]checkpoint, 0, len(stored))
+	for _, cp := range stored {
+		checkpoints = append(checkpoints, checkpoint{height: cp.Height, hash: cp.Hash})
+	}
+	return checkpoints, nil
+}
+
+func (c dbCursor) advance(cp checkpoint) error {
+	if err := c.database.InsertCheckpoint(db.Checkpoint{Selector: c.selector, Height: cp.height, Hash: cp.hash}); err != nil {
+		return err
+	}
+	return c.database.PruneCheckpoints(c.selector, maxCheckpoints)
+}
+
+func (c dbCursor) rewind(cp checkpoint) error {
+	if err := c.database.DeleteCheckpoints(c.selector, cp.height); err != nil {
+		return err
+	}
+	return c.database.InsertCheckpoint(db.Checkpoint{Selector: c.selector, Height: cp.height, Hash: cp.hash})
+}
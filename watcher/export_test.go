@@ -0,0 +1,11 @@
+package watcher
+
+import "github.com/renproject/pack"
+
+// WithBurnLogDecoder returns the fetcher with the given decoder of burn log
+// accounts, so that tests can serve burn logs without the layout of the
+// gateway's accounts.
+func (fetcher SolFetcher) WithBurnLogDecoder(decode func(data []byte) (pack.U256, string, error)) SolFetcher {
+	fetcher.decodeBurnLog = decode
+	return fetcher
+}
@@ -0,0 +1,313 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Enumerate default failover options.
+var (
+	DefaultFailoverMaxFailures = 3
+	DefaultFailoverBackoff     = time.Minute
+	DefaultFailoverQuorum      = 1
+)
+
+// FailoverOptions configure how the fetchers of a chain fail over between its
+// RPC endpoints.
+type FailoverOptions struct {
+	// MaxFailures is the number of consecutive failures after which an
+	// endpoint is considered unhealthy.
+	MaxFailures int
+	// Backoff is how long an unhealthy endpoint is only used as a last resort
+	// before it is tried again.
+	Backoff time.Duration
+	// Quorum is the number of endpoints which must have reached a block height
+	// before the watcher advances to it. A value of one or less disables it.
+	Quorum int
+}
+
+// DefaultFailoverOptions returns new options with default configurations that
+// should work for the majority of use cases.
+func DefaultFailoverOptions() FailoverOptions {
+	return FailoverOptions{
+		MaxFailures: DefaultFailoverMaxFailures,
+		Backoff:     DefaultFailoverBackoff,
+		Quorum:      DefaultFailoverQuorum,
+	}
+}
+
+// WithMaxFailures returns new options with the given number of consecutive
+// failures after which an endpoint is considered unhealthy.
+func (opts FailoverOptions) WithMaxFailures(maxFailures int) FailoverOptions {
+	opts.MaxFailures = maxFailures
+	return opts
+}
+
+// WithBackoff returns new options with the given duration for which unhealthy
+// endpoints are avoided.
+func (opts FailoverOptions) WithBackoff(backoff time.Duration) FailoverOptions {
+	opts.Backoff = backoff
+	return opts
+}
+
+// WithQuorum returns new options with the given number of endpoints which must
+// agree on a block height.
+func (opts FailoverOptions) WithQuorum(quorum int) FailoverOptions {
+	opts.Quorum = quorum
+	return opts
+}
+
+// Endpoints tracks the health of the RPC endpoints of a chain. Requests go to
+// the endpoint which last succeeded, and move on to the next endpoint when it
+// fails. Endpoints which fail repeatedly are only used as a last resort until
+// their backoff expires. It is safe for concurrent use, so that the fetchers of
+// every watcher on a chain share it.
+type Endpoints struct {
+	options FailoverOptions
+
+	mu        *sync.Mutex
+	primary   int
+	failures  []int
+	skipUntil []time.Time
+}
+
+// NewEndpoints returns the health of the given number of endpoints, which are
+// initially all healthy.
+func NewEndpoints(n int, options FailoverOptions) *Endpoints {
+	return &Endpoints{
+		options:   options,
+		mu:        new(sync.Mutex),
+		failures:  make([]int, n),
+		skipUntil: make([]time.Time, n),
+	}
+}
+
+// Len returns the number of endpoints.
+func (endpoints *Endpoints) Len() int {
+	return len(endpoints.failures)
+}
+
+// Healthy returns whether each endpoint is healthy.
+func (endpoints *Endpoints) Healthy() []bool {
+	endpoints.mu.Lock()
+	defer endpoints.mu.Unlock()
+
+	now := time.Now()
+	healthy := make([]bool, len(endpoints.failures))
+	for i := range healthy {
+		healthy[i] = !now.Before(endpoints.skipUntil[i])
+	}
+	return healthy
+}
+
+// order returns the indices of the endpoints in the order in which they should
+// be tried, starting from the primary endpoint, with unhealthy endpoints last.
+func (endpoints *Endpoints) order() []int {
+	endpoints.mu.Lock()
+	defer endpoints.mu.Unlock()
+
+	now := time.Now()
+	n := len(endpoints.failures)
+	healthy := make([]int, 0, n)
+	unhealthy := make([]int, 0, n)
+	for j := 0; j < n; j++ {
+		i := (endpoints.primary + j) % n
+		if now.Before(endpoints.skipUntil[i]) {
+			unhealthy = append(unhealthy, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// succeeded marks the endpoint as healthy and makes it the primary endpoint.
+func (endpoints *Endpoints) succeeded(i int) {
+	endpoints.mu.Lock()
+	defer endpoints.mu.Unlock()
+
+	endpoints.primary = i
+	endpoints.failures[i] = 0
+	endpoints.skipUntil[i] = time.Time{}
+}
+
+// failed records a failure of the endpoint, and marks it as unhealthy if it
+// has failed too many times in a row.
+func (endpoints *Endpoints) failed(i int) {
+	endpoints.mu.Lock()
+	defer endpoints.mu.Unlock()
+
+	endpoints.failures[i]++
+	if endpoints.failures[i] >= endpoints.options.MaxFailures {
+		endpoints.failures[i] = 0
+		endpoints.skipUntil[i] = time.Now().Add(endpoints.options.Backoff)
+	}
+}
+
+// Try calls the function with the index of each endpoint, in the order in which
+// they should be tried, until it succeeds. The endpoints are marked as
+// succeeded or failed accordingly, so that everything which queries a chain
+// shares the health of its endpoints.
+func (endpoints *Endpoints) Try(f func(i int) error) error {
+	var errs []error
+	for _, i := range endpoints.order() {
+		if err := f(i); err != nil {
+			endpoints.failed(i)
+			errs = append(errs, err)
+			continue
+		}
+		endpoints.succeeded(i)
+		return nil
+	}
+	return fmt.Errorf("all %v endpoints failed: %v", len(errs), errs)
+}
+
+// FailoverBurnLogFetcher fetches burn logs from the first endpoint of a chain
+// which responds.
+type FailoverBurnLogFetcher struct {
+	endpoints *Endpoints
+	fetchers  []BurnLogFetcher
+}
+
+// NewFailoverBurnLogFetcher returns a new `FailoverBurnLogFetcher`. The
+// fetchers must be in the same order as the endpoints.
+func NewFailoverBurnLogFetcher(endpoints *Endpoints, fetchers []BurnLogFetcher) FailoverBurnLogFetcher {
+	if len(fetchers) != endpoints.Len() {
+		panic(fmt.Sprintf("expected %v burn log fetchers, got %v", endpoints.Len(), len(fetchers)))
+	}
+	return FailoverBurnLogFetcher{
+		endpoints: endpoints,
+		fetchers:  fetchers,
+	}
+}
+
+// FetchBurnLogs implements the `BurnLogFetcher` interface. An endpoint which
+// fails while the logs are being iterated is marked as failed, so that the
+// next attempt uses another endpoint.
+func (fetcher FailoverBurnLogFetcher) FetchBurnLogs(ctx context.Context, from uint64, to uint64) (chan BurnLogResult, error) {
+	var errs []error
+	for _, i := range fetcher.endpoints.order() {
+		c, err := fetcher.fetchers[i].FetchBurnLogs(ctx, from, to)
+		if err != nil {
+			fetcher.endpoints.failed(i)
+			errs = append(errs, err)
+			continue
+		}
+
+		resultChan := make(chan BurnLogResult)
+		go func(i int) {
+			defer close(resultChan)
+			failed := false
+			for res := range c {
+				if res.Error != nil {
+					failed = true
+				}
+				resultChan <- res
+			}
+			if failed {
+				fetcher.endpoints.failed(i)
+			} else {
+				fetcher.endpoints.succeeded(i)
+			}
+		}(i)
+		return resultChan, nil
+	}
+	return nil, fmt.Errorf("all %v endpoints failed: %v", len(errs), errs)
+}
+
+// FailoverBlockHeightFetcher fetches the block height from the first endpoint
+// of a chain which responds, or from a quorum of endpoints.
+type FailoverBlockHeightFetcher struct {
+	endpoints *Endpoints
+	fetchers  []BlockHeightFetcher
+}
+
+// NewFailoverBlockHeightFetcher returns a new `FailoverBlockHeightFetcher`. The
+// fetchers must be in the same order as the endpoints. If every fetcher is also
+// a `BlockHashFetcher`, so is the returned fetcher.
+func NewFailoverBlockHeightFetcher(endpoints *Endpoints, fetchers []BlockHeightFetcher) BlockHeightFetcher {
+	if len(fetchers) != endpoints.Len() {
+		panic(fmt.Sprintf("expected %v block height fetchers, got %v", endpoints.Len(), len(fetchers)))
+	}
+	fetcher := FailoverBlockHeightFetcher{
+		endpoints: endpoints,
+		fetchers:  fetchers,
+	}
+	for _, f := range fetchers {
+		if _, ok := f.(BlockHashFetcher); !ok {
+			return fetcher
+		}
+	}
+	return failoverBlockHashFetcher{fetcher}
+}
+
+// FetchBlockHeight implements the `BlockHeightFetcher` interface. If a quorum
+// is required, every endpoint is queried and the highest block height which
+// enough endpoints have reached is returned, so that a single endpoint which
+// is ahead of the others cannot advance the watcher.
+func (fetcher FailoverBlockHeightFetcher) FetchBlockHeight(ctx context.Context) (uint64, error) {
+	quorum := fetcher.endpoints.options.Quorum
+	if quorum <= 1 {
+		var height uint64
+		err := fetcher.endpoints.Try(func(i int) error {
+			var err error
+			height, err = fetcher.fetchers[i].FetchBlockHeight(ctx)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+		return height, nil
+	}
+
+	heights := make([]uint64, 0, len(fetcher.fetchers))
+	mu := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	for i := range fetcher.fetchers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			height, err := fetcher.fetchers[i].FetchBlockHeight(ctx)
+			if err != nil {
+				fetcher.endpoints.failed(i)
+				return
+			}
+			fetcher.endpoints.succeeded(i)
+			mu.Lock()
+			heights = append(heights, height)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	if len(heights) < quorum {
+		return 0, fmt.Errorf("quorum not reached: %v of %v endpoints responded, %v required", len(heights), len(fetcher.fetchers), quorum)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] > heights[j] })
+	return heights[quorum-1], nil
+}
+
+// failoverBlockHashFetcher is a `FailoverBlockHeightFetcher` whose fetchers can
+// also fetch block hashes.
+type failoverBlockHashFetcher struct {
+	FailoverBlockHeightFetcher
+}
+
+// FetchBlockHash implements the `BlockHashFetcher` interface.
+func (fetcher failoverBlockHashFetcher) FetchBlockHash(ctx context.Context, height uint64) (string, string, error) {
+	var errs []error
+	for _, i := range fetcher.endpoints.order() {
+		hash, parentHash, err := fetcher.fetchers[i].(BlockHashFetcher).FetchBlockHash(ctx, height)
+		if err != nil {
+			fetcher.endpoints.failed(i)
+			errs = append(errs, err)
+			continue
+		}
+		fetcher.endpoints.succeeded(i)
+		return hash, parentHash, nil
+	}
+	return "", "", fmt.Errorf("all %v endpoints failed: %v", len(errs), errs)
+}
@@ -0,0 +1,200 @@
+package watcher
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/renproject/darknode/binding"
+	"github.com/renproject/darknode/tx"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultRetryInterval is how often the `Manager` retries creating the watchers
+// of selectors which could not be watched.
+var DefaultRetryInterval = time.Minute
+
+// A Factory returns a new `Watcher` for the given burn selector, or an error if
+// the selector cannot be watched.
+type Factory func(selector tx.Selector) (Watcher, error)
+
+// managedWatcher is a watcher run by a `Manager`. The cancel function is nil
+// until the watcher is started.
+type managedWatcher struct {
+	watcher Watcher
+	cancel  context.CancelFunc
+}
+
+// A Manager runs a `Watcher` for each burn selector in the whitelist, and
+// starts and stops watchers as the whitelist changes. Selectors whose watcher
+// cannot be created are retried periodically while they are whitelisted. It is
+// safe for concurrent use.
+type Manager struct {
+	logger        logrus.FieldLogger
+	factory       Factory
+	retryInterval time.Duration
+
+	mu       *sync.Mutex
+	ctx      context.Context
+	watchers map[tx.Selector]*managedWatcher
+	failed   map[tx.Selector]bool
+}
+
+// NewManager returns a new `Manager` which creates watchers using the given
+// factory.
+func NewManager(logger logrus.FieldLogger, factory Factory) *Manager {
+	return &Manager{
+		logger:        logger,
+		factory:       factory,
+		retryInterval: DefaultRetryInterval,
+		mu:            new(sync.Mutex),
+		watchers:      map[tx.Selector]*managedWatcher{},
+		failed:        map[tx.Selector]bool{},
+	}
+}
+
+// WithRetryInterval sets how often the selectors which could not be watched are
+// retried, and returns the `Manager`. It must be called before the `Manager` is
+// run.
+func (manager *Manager) WithRetryInterval(retryInterval time.Duration) *Manager {
+	manager.retryInterval = retryInterval
+	return manager
+}
+
+// SetWhitelist creates a watcher for each burn selector in the whitelist which
+// is not already watched, and stops the watchers of selectors which are no
+// longer whitelisted. If the `Manager` is running, new watchers are started
+// immediately.
+func (manager *Manager) SetWhitelist(whitelist []tx.Selector) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	whitelisted := map[tx.Selector]bool{}
+	for _, selector := range whitelist {
+		if !selector.IsBurn() || !selector.IsRelease() {
+			continue
+		}
+		whitelisted[selector] = true
+		if _, ok := manager.watchers[selector]; ok {
+			continue
+		}
+		manager.watch(selector)
+	}
+
+	for selector := range manager.failed {
+		if !whitelisted[selector] {
+			delete(manager.failed, selector)
+		}
+	}
+
+	for selector, managed := range manager.watchers {
+		if whitelisted[selector] {
+			continue
+		}
+		if managed.cancel != nil {
+			managed.cancel()
+		}
+		delete(manager.watchers, selector)
+		manager.logger.Info("stopped watching", selector)
+	}
+}
+
+// watch creates and, if the `Manager` is running, starts the watcher of the
+// selector. If the watcher cannot be created, the selector is recorded so that
+// it can be retried. It must be called while holding the lock.
+func (manager *Manager) watch(selector tx.Selector) {
+	watcher, err := manager.factory(selector)
+	if err != nil {
+		manager.logger.Warnf("[watcher] cannot watch %v: %v", selector, err)
+		manager.failed[selector] = true
+		return
+	}
+	delete(manager.failed, selector)
+	managed := &managedWatcher{watcher: watcher}
+	manager.watchers[selector] = managed
+	if manager.ctx != nil {
+		manager.start(managed)
+	}
+	manager.logger.Info("watching", selector)
+}
+
+// Run starts the watchers and any watchers added later, and retries the
+// selectors which could not be watched, until the context is canceled. This
+// function is blocking.
+func (manager *Manager) Run(ctx context.Context) {
+	manager.mu.Lock()
+	manager.ctx = ctx
+	for _, managed := range manager.watchers {
+		manager.start(managed)
+	}
+	manager.mu.Unlock()
+
+	ticker := time.NewTicker(manager.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			manager.retry()
+		}
+	}
+}
+
+// retry tries again to create the watchers of the selectors which could not be
+// watched.
+func (manager *Manager) retry() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for selector := range manager.failed {
+		manager.watch(selector)
+	}
+}
+
+// start runs the watcher in the background. It must be called while holding
+// the lock.
+func (manager *Manager) start(managed *managedWatcher) {
+	ctx, cancel := context.WithCancel(manager.ctx)
+	managed.cancel = cancel
+	go managed.watcher.Run(ctx)
+}
+
+// SetBindings replaces the bindings of every watcher. Watchers created
+// afterwards use the bindings returned by the factory.
+func (manager *Manager) SetBindings(bindings binding.Bindings) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for _, managed := range manager.watchers {
+		managed.watcher.SetBindings(bindings)
+	}
+}
+
+// Statuses returns the status of every watcher, sorted by selector.
+func (manager *Manager) Statuses() []Status {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	statuses := make([]Status, 0, len(manager.watchers))
+	for _, managed := range manager.watchers {
+		statuses = append(statuses, managed.watcher.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Selector < statuses[j].Selector })
+	return statuses
+}
+
+// Watcher returns the watcher of the given selector, or false if the selector
+// is not watched.
+func (manager *Manager) Watcher(selector tx.Selector) (Watcher, bool) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	managed, ok := manager.watchers[selector]
+	if !ok {
+		return Watcher{}, false
+	}
+	return managed.watcher, true
+}
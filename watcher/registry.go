@@ -0,0 +1,130 @@
+package watcher
+
+import (
+	"fmt"
+	"sync"
+
+	solanaRPC "github.com/dfuse-io/solana-go/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/renproject/darknode/binding/gatewaybinding"
+	"github.com/renproject/multichain"
+)
+
+// A ChainFamily is a group of chains whose burns are watched in the same way.
+type ChainFamily string
+
+// Enumerate the chain families which can be watched.
+const (
+	ChainFamilyEVM    = ChainFamily("evm")
+	ChainFamilySolana = ChainFamily("solana")
+)
+
+// A FetcherFactory returns the fetchers for the burns of a gateway, using the
+// given RPC endpoint of its chain.
+type FetcherFactory func(rpc string, gateway string) (BurnLogFetcher, BlockHeightFetcher, error)
+
+// Family describes how the burns of a chain family are watched.
+type Family struct {
+	NewFetchers FetcherFactory
+	// Indexed is set if the fetchers count burns by nonce instead of by block,
+	// in which case no confidence interval is needed.
+	Indexed bool
+}
+
+// Registry maps chains to the family used to watch their burns.
+type Registry struct {
+	families map[ChainFamily]Family
+	chains   map[multichain.Chain]ChainFamily
+}
+
+// NewRegistry returns an empty `Registry`.
+func NewRegistry() *Registry {
+	return &Registry{
+		families: map[ChainFamily]Family{},
+		chains:   map[multichain.Chain]ChainFamily{},
+	}
+}
+
+// DefaultRegistry returns a `Registry` with every chain family which has Ren
+// gateways: the MintGatewayLogicV1 contracts on EVM chains and the gateway
+// program on Solana. The other account-based chains of multichain, Terra and
+// Filecoin, only have lock gateways, so there are no burns to watch on them
+// and the Darknodes cannot verify burns from them. The EVM fetchers of the
+// returned registry share one client for each RPC endpoint.
+func DefaultRegistry() *Registry {
+	clients := &ethClients{mu: new(sync.Mutex), clients: map[string]*ethclient.Client{}}
+	registry := NewRegistry()
+	registry.Register(ChainFamilyEVM, Family{NewFetchers: clients.newEVMFetchers},
+		multichain.Arbitrum,
+		multichain.Avalanche,
+		multichain.BinanceSmartChain,
+		multichain.Ethereum,
+		multichain.Fantom,
+		multichain.Goerli,
+		multichain.Polygon,
+	)
+	registry.Register(ChainFamilySolana, Family{NewFetchers: newSolanaFetchers, Indexed: true}, multichain.Solana)
+	return registry
+}
+
+// Register the family, replacing any family with the same name, and watch the
+// given chains with it.
+func (registry *Registry) Register(name ChainFamily, family Family, chains ...multichain.Chain) {
+	registry.families[name] = family
+	for _, chain := range chains {
+		registry.chains[chain] = name
+	}
+}
+
+// Family returns the family used to watch the burns of the chain, or an error
+// if the burns of the chain cannot be watched.
+func (registry *Registry) Family(chain multichain.Chain) (Family, error) {
+	name, ok := registry.chains[chain]
+	if !ok {
+		return Family{}, fmt.Errorf("unsupported chain %v: no burn gateway family is registered", chain)
+	}
+	family, ok := registry.families[name]
+	if !ok {
+		return Family{}, fmt.Errorf("unsupported chain family %v of %v", name, chain)
+	}
+	return family, nil
+}
+
+// ethClients are the clients of the EVM RPC endpoints, so that the watchers of
+// the gateways on a chain share a client for each endpoint.
+type ethClients struct {
+	mu      *sync.Mutex
+	clients map[string]*ethclient.Client
+}
+
+func (c *ethClients) dial(rpc string) (*ethclient.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client, ok := c.clients[rpc]; ok {
+		return client, nil
+	}
+	client, err := ethclient.Dial(rpc)
+	if err != nil {
+		return nil, err
+	}
+	c.clients[rpc] = client
+	return client, nil
+}
+
+func (c *ethClients) newEVMFetchers(rpc string, gateway string) (BurnLogFetcher, BlockHeightFetcher, error) {
+	client, err := c.dial(rpc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("dialing rpc: %v", err)
+	}
+	bindings, err := gatewaybinding.NewMintGatewayLogicV1(common.HexToAddress(gateway), client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("binding gateway %v: %v", gateway, err)
+	}
+	return NewEthBurnLogFetcher(bindings), NewEthBlockHeightFetcher(client), nil
+}
+
+func newSolanaFetchers(rpc string, gateway string) (BurnLogFetcher, BlockHeightFetcher, error) {
+	fetcher := NewSolFetcher(solanaRPC.NewClient(rpc), rpc, gateway)
+	return fetcher, fetcher, nil
+}
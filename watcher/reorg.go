@@ -0,0 +1,141 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxCheckpoints is the number of checkpoints kept for each watcher, which
+// bounds how far back the watcher can rewind after a reorg.
+const maxCheckpoints = 128
+
+// A BlockHashFetcher returns the hash and the parent hash of the block at a
+// given height. Block height fetchers which implement it allow the watcher to
+// detect reorgs.
+type BlockHashFetcher interface {
+	FetchBlockHash(ctx context.Context, height uint64) (hash string, parentHash string, err error)
+}
+
+// FetchBlockHash returns the hash and the parent hash of the block at the given
+// height.
+func (fetcher EthBlockHeightFetcher) FetchBlockHash(ctx context.Context, height uint64) (string, string, error) {
+	header, err := fetcher.client.HeaderByNumber(ctx, new(big.Int).SetUint64(height))
+	if err != nil {
+		return "", "", err
+	}
+	return header.Hash().Hex(), header.ParentHash.Hex(), nil
+}
+
+// checkpoint is a height that the watcher has checked up to, and the hash of
+// the block at that height when it was checked.
+type checkpoint struct {
+	height uint64
+	hash   string
+}
+
+func (cp checkpoint) String() string {
+	return fmt.Sprintf("%v:%v", cp.height, cp.hash)
+}
+
+func decodeCheckpoint(value string) (checkpoint, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return checkpoint{}, fmt.Errorf("invalid checkpoint %v", value)
+	}
+	height, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return checkpoint{}, fmt.Errorf("invalid checkpoint %v: %v", value, err)
+	}
+	return checkpoint{height: height, hash: parts[1]}, nil
+}
+
+// checkpointAt returns a checkpoint at the given height. If the watcher can
+// detect reorgs, the checkpoint includes the hash of the block at that height.
+// It must be fetched before the logs up to the height are read, so that if the
+// chain is reorganised while the logs are read, the recorded hash is that of
+// the replaced block and the reorg is detected on the next poll.
+func (watcher Watcher) checkpointAt(ctx context.Context, height uint64) (checkpoint, error) {
+	cp := checkpoint{height: height}
+	if watcher.blockHashFetcher != nil {
+		hash, _, err := watcher.blockHashFetcher.FetchBlockHash(ctx, height)
+		if err != nil {
+			return cp, fmt.Errorf("fetching block hash at %v: %v", height, err)
+		}
+		cp.hash = hash
+	}
+	return cp, nil
+}
+
+// setLastCheckedBlockNumber records that the watcher has checked up to the
+// height of the given checkpoint.
+func (watcher Watcher) setLastCheckedBlockNumber(cp checkpoint) error {
+	return watcher.cursor.advance(cp)
+}
+
+// rewind compares the parent hash of the block after the last checked block
+// with the recorded hash of the last checked block. If they differ, the chain
+// has been reorganised, and the watcher is rewound to the most recent
+// checkpoint which is still part of the chain so that the logs of the replaced
+// blocks are fetched again. It returns the height to continue from.
+func (watcher Watcher) rewind(ctx context.Context, lastHeight uint64) (uint64, error) {
+	if watcher.blockHashFetcher == nil {
+		return lastHeight, nil
+	}
+	checkpoints, err := watcher.cursor.checkpoints()
+	if err != nil {
+		return lastHeight, fmt.Errorf("loading checkpoints: %v", err)
+	}
+	// Nothing can be compared if the last checked block has no checkpoint,
+	// such as when the watcher has just been initialised.
+	if len(checkpoints) == 0 || checkpoints[0].height != lastHeight || checkpoints[0].hash == "" {
+		return lastHeight, nil
+	}
+
+	_, parentHash, err := watcher.blockHashFetcher.FetchBlockHash(ctx, lastHeight+1)
+	if err != nil {
+		return lastHeight, fmt.Errorf("fetching block hash at %v: %v", lastHeight+1, err)
+	}
+	if parentHash == checkpoints[0].hash {
+		return lastHeight, nil
+	}
+	watcher.logger.Warnf("[watcher] detected reorg for %v: block %v has changed from %v", watcher.selector.String(), lastHeight, checkpoints[0].hash)
+
+	for _, cp := range checkpoints[1:] {
+		if cp.hash == "" {
+			continue
+		}
+		hash, _, err := watcher.blockHashFetcher.FetchBlockHash(ctx, cp.height)
+		if err != nil {
+			return lastHeight, fmt.Errorf("fetching block hash at %v: %v", cp.height, err)
+		}
+		if hash != cp.hash {
+			continue
+		}
+		// Drop the checkpoints of the replaced blocks.
+		if err := watcher.cursor.rewind(cp); err != nil {
+			return lastHeight, err
+		}
+		watcher.logger.Infof("[watcher] rewound %v from block %v to block %v", watcher.selector.String(), lastHeight, cp.height)
+		return cp.height, nil
+	}
+
+	// The reorg is deeper than the recorded checkpoints, so the watcher is
+	// rewound as far as possible.
+	oldest := checkpoints[len(checkpoints)-1]
+	watcher.logger.Errorf("[watcher] reorg for %v is deeper than block %v, burns before it may have been missed", watcher.selector.String(), oldest.height)
+	// The oldest checkpoint is kept with the hash of the block that replaced
+	// it, so that the watcher does not rewind again from the same height.
+	if oldest.hash != "" {
+		oldest.hash, _, err = watcher.blockHashFetcher.FetchBlockHash(ctx, oldest.height)
+		if err != nil {
+			return lastHeight, fmt.Errorf("fetching block hash at %v: %v", oldest.height, err)
+		}
+	}
+	if err := watcher.cursor.rewind(oldest); err != nil {
+		return lastHeight, err
+	}
+	return oldest.height, nil
+}
@@ -0,0 +1,110 @@
+package watcher
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/renproject/darknode/binding"
+)
+
+// Status describes what a `Watcher` is doing.
+type Status struct {
+	Selector      string    `json:"selector"`
+	Paused        bool      `json:"paused"`
+	Cursor        uint64    `json:"cursor"`
+	Tip           uint64    `json:"tip"`
+	Lag           uint64    `json:"lag"`
+	LastPoll      time.Time `json:"lastPoll"`
+	LastError     string    `json:"lastError,omitempty"`
+	LastErrorTime time.Time `json:"lastErrorTime"`
+	LastBurn      string    `json:"lastBurn,omitempty"`
+	LastBurnTime  time.Time `json:"lastBurnTime"`
+}
+
+// state is shared by the copies of a `Watcher`. The poll mutex is held while
+// the watcher polls its chain, so that the cursor is not reset in the middle of
+// a poll. The rejected attempts to submit each burn, and the burns which have
+// been quarantined, are keyed by nonce.
+type state struct {
+	pollMu *sync.Mutex
+
+	mu          *sync.Mutex
+	status      Status
+	attempts    map[string]int
+	quarantined map[string]bool
+}
+
+func newState(selector string) *state {
+	return &state{
+		pollMu:      new(sync.Mutex),
+		mu:          new(sync.Mutex),
+		status:      Status{Selector: selector},
+		attempts:    map[string]int{},
+		quarantined: map[string]bool{},
+	}
+}
+
+func (state *state) update(f func(status *Status)) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	f(&state.status)
+}
+
+// Status returns what the watcher is doing.
+func (watcher Watcher) Status() Status {
+	watcher.state.mu.Lock()
+	defer watcher.state.mu.Unlock()
+
+	status := watcher.state.status
+	if status.Tip > status.Cursor {
+		status.Lag = status.Tip - status.Cursor
+	}
+	return status
+}
+
+// Pause stops the watcher from polling its chain until it is resumed.
+func (watcher Watcher) Pause() {
+	watcher.state.update(func(status *Status) { status.Paused = true })
+	watcher.logger.Infof("[watcher] paused %v", watcher.selector.String())
+}
+
+// Resume continues polling the chain after the watcher has been paused.
+func (watcher Watcher) Resume() {
+	watcher.state.update(func(status *Status) { status.Paused = false })
+	watcher.logger.Infof("[watcher] resumed %v", watcher.selector.String())
+}
+
+// ResetCursor sets the last checked height of the watcher, so that it
+// continues from the given height. Checkpoints above the height are dropped.
+func (watcher Watcher) ResetCursor(height uint64) error {
+	watcher.state.pollMu.Lock()
+	defer watcher.state.pollMu.Unlock()
+
+	if err := watcher.cursor.rewind(checkpoint{height: height}); err != nil {
+		return fmt.Errorf("resetting cursor of %v: %v", watcher.selector.String(), err)
+	}
+	watcher.state.update(func(status *Status) { status.Cursor = height })
+	watcher.logger.Infof("[watcher] reset cursor of %v to %v", watcher.selector.String(), height)
+	return nil
+}
+
+// SetBindings replaces the bindings used to decode the recipients of burns.
+func (watcher Watcher) SetBindings(bindings binding.Bindings) {
+	watcher.bindings.Swap(bindings)
+}
+
+// paused returns whether the watcher has been paused.
+func (watcher Watcher) paused() bool {
+	watcher.state.mu.Lock()
+	defer watcher.state.mu.Unlock()
+	return watcher.state.status.Paused
+}
+
+// recordError stores the error as the last error of the watcher.
+func (watcher Watcher) recordError(err error) {
+	watcher.state.update(func(status *Status) {
+		status.LastError = err.Error()
+		status.LastErrorTime = time.Now()
+	})
+}
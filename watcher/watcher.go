@@ -1,9 +1,16 @@
 package watcher
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/chaincfg"
@@ -21,6 +28,8 @@ import (
 	"github.com/renproject/darknode/jsonrpc"
 	"github.com/renproject/darknode/tx"
 	v0 "github.com/renproject/lightnode/compat/v0"
+	"github.com/renproject/lightnode/config"
+	"github.com/renproject/lightnode/db"
 	"github.com/renproject/multichain"
 	"github.com/renproject/multichain/chain/bitcoin"
 	"github.com/renproject/multichain/chain/bitcoincash"
@@ -40,8 +49,32 @@ type BurnInfo struct {
 	ToBytes     []byte
 	Nonce       pack.Bytes32
 	BlockNumber pack.U64
+
+	// Pruned is set if the transaction of the burn is no longer available, in
+	// which case the txid is a placeholder and the burn has to be reviewed
+	// manually.
+	Pruned bool
 }
 
+const (
+	// solanaMaxAccounts is the maximum number of accounts which can be read
+	// with a single getMultipleAccounts request.
+	solanaMaxAccounts = 100
+	// solanaSignatureWorkers is the number of signatures which are looked up
+	// at the same time.
+	solanaSignatureWorkers = 8
+	// solanaTimeout is the time limit for a request to a Solana node.
+	solanaTimeout = 30 * time.Second
+)
+
+// DefaultSolanaPruneAfter is how long the Solana nodes must keep returning no
+// signatures for a burn before it is flagged as pruned.
+var DefaultSolanaPruneAfter = 10 * time.Minute
+
+// DefaultMappingExpiry is how long the v0 hashes of the burns forwarded by a
+// watcher remain queryable.
+var DefaultMappingExpiry = 30 * 24 * time.Hour
+
 type BurnLogResult struct {
 	Result BurnInfo
 	Error  error
@@ -126,11 +159,25 @@ func (fetcher EthBurnLogFetcher) FetchBurnLogs(ctx context.Context, from uint64,
 
 type SolFetcher struct {
 	client           *solanaRPC.Client
+	rpcURL           string
+	httpClient       *http.Client
 	gatewayStatePubk solanaSDK.PublicKey
 	gatewayAddress   string
+	decodeBurnLog    func(data []byte) (pack.U256, string, error)
+
+	// unsigned is when each burn log account was first found without any
+	// signatures, so that a burn is only flagged as pruned once the nodes have
+	// consistently returned no signatures for it.
+	unsigned   *unsignedAccounts
+	pruneAfter time.Duration
 }
 
-func NewSolFetcher(client *solanaRPC.Client, gatewayAddress string) SolFetcher {
+type unsignedAccounts struct {
+	mu    *sync.Mutex
+	since map[string]time.Time
+}
+
+func NewSolFetcher(client *solanaRPC.Client, rpcURL string, gatewayAddress string) SolFetcher {
 	seeds := []byte("GatewayStateV0.1.4")
 	programDerivedAddress := solana.ProgramDerivedAddress(pack.Bytes(seeds), multichain.Address(gatewayAddress))
 	programPubk, err := solanaSDK.PublicKeyFromBase58(string(programDerivedAddress))
@@ -140,84 +187,269 @@ func NewSolFetcher(client *solanaRPC.Client, gatewayAddress string) SolFetcher {
 
 	return SolFetcher{
 		client:           client,
+		rpcURL:           rpcURL,
+		httpClient:       &http.Client{Timeout: solanaTimeout},
 		gatewayStatePubk: programPubk,
 		gatewayAddress:   gatewayAddress,
+		decodeBurnLog:    solanastate.DecodeBurnLog,
+		unsigned:         &unsignedAccounts{mu: new(sync.Mutex), since: map[string]time.Time{}},
+		pruneAfter:       DefaultSolanaPruneAfter,
 	}
 }
 
+// WithPruneAfter returns the fetcher with the given duration for which the
+// nodes must keep returning no signatures for a burn, while their ledger no
+// longer starts at the genesis block, before the burn is flagged as pruned.
+func (fetcher SolFetcher) WithPruneAfter(pruneAfter time.Duration) SolFetcher {
+	fetcher.pruneAfter = pruneAfter
+	return fetcher
+}
+
+// FetchBurnLogs fetches the burn logs of the burns with indices in [from, to).
+// The burn log accounts are read in batches, and the signatures of each batch
+// are looked up in parallel.
 func (fetcher SolFetcher) FetchBurnLogs(ctx context.Context, from uint64, to uint64) (chan BurnLogResult, error) {
 	resultChan := make(chan BurnLogResult)
 
 	go func() {
 		defer close(resultChan)
-		for i := from; i < to; i++ {
-			nonce := i
-
-			b := make([]byte, 8)
-			binary.LittleEndian.PutUint64(b, i)
-
-			var nonceBytes pack.Bytes32
-			copy(nonceBytes[:], pack.NewU256FromU64(pack.NewU64(nonce)).Bytes())
-
-			burnLogDerivedAddress := solana.ProgramDerivedAddress(b, multichain.Address(fetcher.gatewayAddress))
-
-			burnLogPubk, err := solanaSDK.PublicKeyFromBase58(string(burnLogDerivedAddress))
-			if err != nil {
-				resultChan <- BurnLogResult{Error: fmt.Errorf("getting burn log account: %v", err)}
-				return
+		for start := from; start < to; start += solanaMaxAccounts {
+			end := start + solanaMaxAccounts
+			if end > to {
+				end = to
 			}
-
-			// Fetch account data at gateway's state
-			accountInfo, err := fetcher.client.GetAccountInfo(ctx, burnLogPubk)
-			if err != nil {
-				resultChan <- BurnLogResult{Error: fmt.Errorf("getting burn log data for burn: %v err: %v", i, err)}
-				return
+			burns, err := fetcher.fetchBurns(ctx, start, end)
+			for _, result := range burns {
+				// Send the burn transaction to the resolver.
+				select {
+				case <-ctx.Done():
+					resultChan <- BurnLogResult{Error: ctx.Err()}
+					return
+				default:
+					resultChan <- BurnLogResult{Result: result}
+				}
 			}
-			data := accountInfo.Value.Data
-			amount, recipient, err := solanastate.DecodeBurnLog(data)
 			if err != nil {
-				resultChan <- BurnLogResult{Error: fmt.Errorf("failed to decode burn log :  %v", err)}
+				resultChan <- BurnLogResult{Error: err}
 				return
 			}
+		}
+	}()
 
-			signatures, err := fetcher.client.GetSignaturesForAddress(ctx, burnLogPubk, &solanaRPC.GetSignaturesForAddressOpts{})
-			if err != nil {
-				legacySignatures, err2 := fetcher.client.GetConfirmedSignaturesForAddress2(ctx, burnLogPubk, &solanaRPC.GetConfirmedSignaturesForAddress2Opts{})
-				if err2 != nil {
-					resultChan <- BurnLogResult{Error: fmt.Errorf("getting burn log txes: (current: %v) (legacy: %v)", err, err2)}
-					return
-				}
-				signatures = solanaRPC.GetSignaturesForAddressResult(legacySignatures)
-			}
+	return resultChan, nil
+}
 
-			// NOTE: We assume the burn watcher will always run before a signature gets pruned
-			// manual intervention will be required to skip a burns where the signatures are no longer
-			// returned by the nodes
-			if len(signatures) == 0 {
-				resultChan <- BurnLogResult{Error: fmt.Errorf("Burn signature not confirmed")}
-				return
-			}
+// fetchBurns returns the burns with indices in [from, to), in order. If the
+// signature of a burn cannot be looked up, the burns before it are returned
+// along with the error.
+func (fetcher SolFetcher) fetchBurns(ctx context.Context, from uint64, to uint64) ([]BurnInfo, error) {
+	pubks := make([]solanaSDK.PublicKey, 0, to-from)
+	for i := from; i < to; i++ {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, i)
+		burnLogDerivedAddress := solana.ProgramDerivedAddress(b, multichain.Address(fetcher.gatewayAddress))
+		burnLogPubk, err := solanaSDK.PublicKeyFromBase58(string(burnLogDerivedAddress))
+		if err != nil {
+			return nil, fmt.Errorf("getting burn log account: %v", err)
+		}
+		pubks = append(pubks, burnLogPubk)
+	}
 
-			result := BurnInfo{
-				Txid:        base58.Decode(signatures[0].Signature),
-				Amount:      amount,
-				ToBytes:     []byte(recipient),
-				Nonce:       nonceBytes,
-				BlockNumber: pack.NewU64(i),
-			}
+	// Fetch the data of the burn log accounts
+	accounts, err := fetcher.getMultipleAccounts(ctx, pubks)
+	if err != nil {
+		return nil, fmt.Errorf("getting burn log data for burns from=%v to=%v: %v", from, to, err)
+	}
+	burns := make([]BurnInfo, len(pubks))
+	for j, data := range accounts {
+		nonce := from + uint64(j)
+		if data == nil {
+			return nil, fmt.Errorf("getting burn log data for burn: %v err: account not found", nonce)
+		}
+		amount, recipient, err := fetcher.decodeBurnLog(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode burn log :  %v", err)
+		}
+		var nonceBytes pack.Bytes32
+		copy(nonceBytes[:], pack.NewU256FromU64(pack.NewU64(nonce)).Bytes())
+		burns[j] = BurnInfo{
+			Amount:      amount,
+			ToBytes:     []byte(recipient),
+			Nonce:       nonceBytes,
+			BlockNumber: pack.NewU64(nonce),
+		}
+	}
 
-			// Send the burn transaction to the resolver.
-			select {
-			case <-ctx.Done():
-				resultChan <- BurnLogResult{Error: ctx.Err()}
-				return
-			default:
-				resultChan <- BurnLogResult{Result: result}
+	// The first available block is only needed for burns without signatures,
+	// so it is fetched at most once per batch.
+	firstBlockOnce := new(sync.Once)
+	var firstBlock uint64
+	var firstBlockErr error
+	firstAvailableBlock := func() (uint64, error) {
+		firstBlockOnce.Do(func() {
+			firstBlock, firstBlockErr = fetcher.firstAvailableBlock(ctx)
+		})
+		return firstBlock, firstBlockErr
+	}
+
+	// Look up the signatures with a bounded number of workers.
+	errs := make([]error, len(pubks))
+	indices := make(chan int)
+	wg := new(sync.WaitGroup)
+	for w := 0; w < solanaSignatureWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range indices {
+				burns[j].Txid, burns[j].Pruned, errs[j] = fetcher.fetchSignature(ctx, pubks[j], firstAvailableBlock)
 			}
+		}()
+	}
+	for j := range pubks {
+		indices <- j
+	}
+	close(indices)
+	wg.Wait()
+
+	for j, err := range errs {
+		if err != nil {
+			return burns[:j], fmt.Errorf("getting burn log txes for burn %v: %v", from+uint64(j), err)
 		}
-	}()
+	}
+	return burns, nil
+}
 
-	return resultChan, nil
+// fetchSignature returns the signature of the transaction which created the
+// burn log account. A burn is only flagged as pruned, rather than blocking the
+// burns after it, once the nodes have pruned part of their ledger and have
+// returned no signatures for it for long enough. Until then, an error is
+// returned so that the burn is fetched again.
+func (fetcher SolFetcher) fetchSignature(ctx context.Context, burnLogPubk solanaSDK.PublicKey, firstAvailableBlock func() (uint64, error)) (pack.Bytes, bool, error) {
+	signatures, err := fetcher.client.GetSignaturesForAddress(ctx, burnLogPubk, &solanaRPC.GetSignaturesForAddressOpts{})
+	if err != nil {
+		legacySignatures, err2 := fetcher.client.GetConfirmedSignaturesForAddress2(ctx, burnLogPubk, &solanaRPC.GetConfirmedSignaturesForAddress2Opts{})
+		if err2 != nil {
+			return nil, false, fmt.Errorf("(current: %v) (legacy: %v)", err, err2)
+		}
+		signatures = solanaRPC.GetSignaturesForAddressResult(legacySignatures)
+	}
+	if len(signatures) > 0 {
+		fetcher.unsigned.mu.Lock()
+		delete(fetcher.unsigned.since, burnLogPubk.String())
+		fetcher.unsigned.mu.Unlock()
+		return base58.Decode(signatures[0].Signature), false, nil
+	}
+
+	fetcher.unsigned.mu.Lock()
+	since, ok := fetcher.unsigned.since[burnLogPubk.String()]
+	if !ok {
+		since = time.Now()
+		fetcher.unsigned.since[burnLogPubk.String()] = since
+	}
+	fetcher.unsigned.mu.Unlock()
+
+	// The transaction cannot have been pruned if the ledger is complete.
+	firstBlock, err := firstAvailableBlock()
+	if err != nil {
+		return nil, false, fmt.Errorf("getting first available block: %v", err)
+	}
+	if firstBlock == 0 {
+		return nil, false, fmt.Errorf("no signatures for %v", burnLogPubk)
+	}
+	if time.Since(since) < fetcher.pruneAfter {
+		return nil, false, fmt.Errorf("no signatures for %v since %v", burnLogPubk, since.Format(time.RFC3339))
+	}
+	return pack.Bytes{}, true, nil
+}
+
+// firstAvailableBlock returns the slot of the lowest confirmed block which has
+// not been pruned from the ledger of the node.
+func (fetcher SolFetcher) firstAvailableBlock(ctx context.Context) (uint64, error) {
+	var slot uint64
+	if err := fetcher.call(ctx, "getFirstAvailableBlock", []interface{}{}, &slot); err != nil {
+		return 0, err
+	}
+	return slot, nil
+}
+
+// getMultipleAccounts returns the data of the given accounts, which is nil for
+// accounts that do not exist.
+func (fetcher SolFetcher) getMultipleAccounts(ctx context.Context, pubks []solanaSDK.PublicKey) ([][]byte, error) {
+	addresses := make([]string, len(pubks))
+	for i, pubk := range pubks {
+		addresses[i] = pubk.String()
+	}
+	var result struct {
+		Value []*struct {
+			Data []string `json:"data"`
+		} `json:"value"`
+	}
+	params := []interface{}{addresses, map[string]string{"encoding": "base64"}}
+	if err := fetcher.call(ctx, "getMultipleAccounts", params, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Value) != len(pubks) {
+		return nil, fmt.Errorf("expected %v accounts, got %v", len(pubks), len(result.Value))
+	}
+
+	accounts := make([][]byte, len(pubks))
+	for i, account := range result.Value {
+		if account == nil {
+			continue
+		}
+		if len(account.Data) == 0 {
+			return nil, fmt.Errorf("missing data of account %v", addresses[i])
+		}
+		data, err := base64.StdEncoding.DecodeString(account.Data[0])
+		if err != nil {
+			return nil, fmt.Errorf("decoding data of account %v: %v", addresses[i], err)
+		}
+		accounts[i] = data
+	}
+	return accounts, nil
+}
+
+// call sends a JSON-RPC request to the Solana node and unmarshals the result
+// into the given value.
+func (fetcher SolFetcher) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fetcher.rpcURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := fetcher.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %v: %s", resp.Status, body)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("decoding response: %v", err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("code=%v, message=%v", response.Error.Code, response.Error.Message)
+	}
+	return json.Unmarshal(response.Result, result)
 }
 
 type BlockHeightFetcher interface {
@@ -265,33 +497,64 @@ type Watcher struct {
 	network            multichain.Network
 	logger             logrus.FieldLogger
 	selector           tx.Selector
-	bindings           binding.Bindings
+	bindings           *config.SwappableBindings
 	burnLogFetcher     BurnLogFetcher
 	blockHeightFetcher BlockHeightFetcher
+	blockHashFetcher   BlockHashFetcher
 	resolver           jsonrpc.Resolver
 	cache              redis.Cmdable
+	db                 db.DB
+	cursor             cursor
+	state              *state
+	backpressure       Backpressure
+	mappingExpiry      time.Duration
 	pollInterval       time.Duration
 	maxBlockAdvance    uint64
 	confidenceInterval uint64
 }
 
-// NewWatcher returns a new Watcher.
-func NewWatcher(logger logrus.FieldLogger, network multichain.Network, selector tx.Selector, bindings binding.Bindings, burnLogFetcher BurnLogFetcher, blockHeightFetcher BlockHeightFetcher, resolver jsonrpc.Resolver, cache redis.Cmdable, pollInterval time.Duration, maxBlockAdvance uint64, confidenceInterval uint64) Watcher {
+// NewWatcher returns a new Watcher. If the block height fetcher also implements
+// `BlockHashFetcher`, the watcher detects reorgs and fetches the logs of the
+// replaced blocks again. If the database is not nil, the watcher stores its
+// cursor in the database instead of redis and records every burn it forwards.
+// Burns which the Darknodes reject are retried up to `DefaultMaxAttempts` times
+// before they are quarantined, unless another backpressure is given with
+// `WithBackpressure`.
+func NewWatcher(logger logrus.FieldLogger, network multichain.Network, selector tx.Selector, bindings binding.Bindings, burnLogFetcher BurnLogFetcher, blockHeightFetcher BlockHeightFetcher, resolver jsonrpc.Resolver, cache redis.Cmdable, database db.DB, pollInterval time.Duration, maxBlockAdvance uint64, confidenceInterval uint64) Watcher {
+	blockHashFetcher, _ := blockHeightFetcher.(BlockHashFetcher)
+	var c cursor = redisCursor{selector: selector.String(), cache: cache}
+	if database != nil {
+		c = dbCursor{selector: selector.String(), database: database, cache: cache}
+	}
 	return Watcher{
 		logger:             logger,
 		network:            network,
 		selector:           selector,
-		bindings:           bindings,
+		bindings:           config.NewSwappableBindings(bindings),
 		burnLogFetcher:     burnLogFetcher,
 		blockHeightFetcher: blockHeightFetcher,
+		blockHashFetcher:   blockHashFetcher,
 		resolver:           resolver,
 		cache:              cache,
+		db:                 database,
+		cursor:             c,
+		state:              newState(selector.String()),
+		backpressure:       MaxAttempts(DefaultMaxAttempts),
+		mappingExpiry:      DefaultMappingExpiry,
 		pollInterval:       pollInterval,
 		maxBlockAdvance:    maxBlockAdvance,
 		confidenceInterval: confidenceInterval,
 	}
 }
 
+// WithMappingExpiry returns the watcher with the given expiry for the v0 hashes
+// of the burns it forwards. It should be the same as the expiry of the
+// transactions in the database.
+func (watcher Watcher) WithMappingExpiry(expiry time.Duration) Watcher {
+	watcher.mappingExpiry = expiry
+	return watcher
+}
+
 // Run starts the watcher until the context is canceled.
 func (watcher Watcher) Run(ctx context.Context) {
 	ticker := time.NewTicker(watcher.pollInterval)
@@ -311,6 +574,13 @@ func (watcher Watcher) Run(ctx context.Context) {
 // and the last checked block number. It constructs a `jsonrpc.Request` from
 // these events and forwards them to the resolver.
 func (watcher Watcher) watchLogShiftOuts(parent context.Context) {
+	if watcher.paused() {
+		return
+	}
+	watcher.state.pollMu.Lock()
+	defer watcher.state.pollMu.Unlock()
+	watcher.state.update(func(status *Status) { status.LastPoll = time.Now() })
+
 	ctx, cancel := context.WithTimeout(parent, watcher.pollInterval)
 	defer cancel()
 
@@ -318,15 +588,26 @@ func (watcher Watcher) watchLogShiftOuts(parent context.Context) {
 	currentHeight, err := watcher.blockHeightFetcher.FetchBlockHeight(ctx)
 	if err != nil {
 		watcher.logger.Warnf("[watcher] error loading block header: %v", err)
+		watcher.recordError(fmt.Errorf("loading block header: %v", err))
 		return
 	}
+	watcher.state.update(func(status *Status) { status.Tip = currentHeight })
 
 	lastHeight, err := watcher.lastCheckedBlockNumber(currentHeight)
 	if err != nil {
 		watcher.logger.Errorf("[watcher] error loading last checked block number: %v", err)
+		watcher.recordError(fmt.Errorf("loading last checked block number: %v", err))
 		return
 	}
 
+	lastHeight, err = watcher.rewind(ctx, lastHeight)
+	if err != nil {
+		watcher.logger.Errorf("[watcher] error checking for reorgs: %v", err)
+		watcher.recordError(fmt.Errorf("checking for reorgs: %v", err))
+		return
+	}
+	watcher.state.update(func(status *Status) { status.Cursor = lastHeight })
+
 	if currentHeight <= lastHeight {
 		watcher.logger.Debug("[watcher] tried to process old blocks")
 		// Make sure we do not process old events. This could occur if there is
@@ -347,15 +628,26 @@ func (watcher Watcher) watchLogShiftOuts(parent context.Context) {
 		currentHeight = step
 	}
 
-	// for Eth, avoid checking blocks that might have shuffled
-	if watcher.selector.Source() != multichain.Solana {
-		currentHeight -= watcher.confidenceInterval
+	// Avoid checking blocks that might have shuffled
+	if currentHeight <= lastHeight+watcher.confidenceInterval {
+		return
+	}
+	currentHeight -= watcher.confidenceInterval
+
+	// Fetch the checkpoint before the logs, so that it cannot describe a block
+	// which replaced the blocks whose logs were read.
+	cp, err := watcher.checkpointAt(ctx, currentHeight)
+	if err != nil {
+		watcher.logger.Warnf("[watcher] error loading checkpoint at %v: %v", currentHeight, err)
+		watcher.recordError(fmt.Errorf("loading checkpoint at %v: %v", currentHeight, err))
+		return
 	}
 
 	// Fetch logs
 	c, err := watcher.burnLogFetcher.FetchBurnLogs(ctx, lastHeight, currentHeight)
 	if err != nil {
 		watcher.logger.Warnf("[watcher] error fetching LogBurn events from=%v to=%v: %v", lastHeight, currentHeight, err)
+		watcher.recordError(fmt.Errorf("fetching LogBurn events from=%v to=%v: %v", lastHeight, currentHeight, err))
 		return
 	}
 
@@ -363,6 +655,7 @@ func (watcher Watcher) watchLogShiftOuts(parent context.Context) {
 	for res := range c {
 		if res.Error != nil {
 			watcher.logger.Errorf("[watcher] error iterating LogBurn events from=%v to=%v: %v", lastHeight, currentHeight, res.Error)
+			watcher.recordError(fmt.Errorf("iterating LogBurn events from=%v to=%v: %v", lastHeight, currentHeight, res.Error))
 			return
 		}
 		burn := res.Result
@@ -371,52 +664,145 @@ func (watcher Watcher) watchLogShiftOuts(parent context.Context) {
 		to := burn.ToBytes
 
 		watcher.logger.Infof("[watcher] detected burn for %v  with nonce=%v", watcher.selector.String(), nonce)
+		watcher.state.update(func(status *Status) {
+			status.LastBurn = pack.NewU256(nonce).String()
+			status.LastBurnTime = time.Now()
+		})
+
+		if burn.Pruned {
+			// The burn cannot be submitted without its txid, so it is left
+			// for manual review rather than blocking the burns after it.
+			watcher.logger.Errorf("[watcher] transaction of burn for %v with nonce=%v is no longer available, flagging it for review", watcher.selector.String(), nonce)
+			watcher.recordBurn(burn, "", db.BurnStatusReview, "transaction not available")
+			continue
+		}
+
+		// Skip burns which have been quarantined, so that they are not
+		// retried when the logs are fetched again.
+		quarantined, err := watcher.isQuarantined(burn)
+		if err != nil {
+			watcher.logger.Errorf("[watcher] cannot check quarantine of burn for %v with nonce=%v: %v", watcher.selector.String(), pack.NewU256(nonce), err)
+			watcher.recordError(fmt.Errorf("checking quarantine of burn with nonce=%v: %v", pack.NewU256(nonce), err))
+			return
+		}
+		if quarantined {
+			watcher.logger.Infof("[watcher] skipping quarantined burn for %v with nonce=%v", watcher.selector.String(), pack.NewU256(nonce))
+			continue
+		}
 
 		// Send the burn transaction to the resolver.
 		params, err := watcher.burnToParams(burn.Txid, amount, to, nonce)
 		if err != nil {
 			watcher.logger.Errorf("[watcher] cannot get params from burn transaction (to=%v, amount=%v, nonce=%v): %v", to, amount, nonce, err)
+			watcher.recordBurn(burn, "", db.BurnStatusFailed, err.Error())
+			watcher.recordError(fmt.Errorf("getting params from burn with nonce=%v: %v", pack.NewU256(nonce), err))
 			continue
 		}
 
 		response := watcher.resolver.SubmitTx(ctx, 0, &params, nil)
 		if response.Error != nil {
-			watcher.logger.Errorf("[watcher] invalid burn transaction %v: %v", params, response.Error.Message)
-			// return so that we retry, if the burnToParams are valid, the darknode should accept the tx
-			// we assume that the only failure case would be RPC/darknode backpressure, so we backoff here
-			return
+			err := fmt.Errorf("submitting burn with nonce=%v: %v", pack.NewU256(nonce), response.Error.Message)
+			watcher.recordError(err)
+			if !rejected(response.Error) {
+				// Return so that the burn is retried on the next poll, as
+				// the Darknodes are unavailable or applying backpressure.
+				watcher.logger.Warnf("[watcher] cannot submit burn transaction %v: %v", params, response.Error.Message)
+				watcher.recordBurn(burn, params.Tx.Hash.String(), db.BurnStatusFailed, response.Error.Message)
+				return
+			}
+			attempts, retry := watcher.failed(burn, err)
+			if retry {
+				// Return so that the burn is retried on the next poll, in
+				// case it was rejected by a Darknode which is behind.
+				watcher.logger.Errorf("[watcher] invalid burn transaction %v (attempt %v): %v", params, attempts, response.Error.Message)
+				watcher.recordBurn(burn, params.Tx.Hash.String(), db.BurnStatusFailed, response.Error.Message)
+				return
+			}
+
+			// Quarantine the burn so that it does not block the burns after
+			// it.
+			watcher.logger.Errorf("[watcher] quarantining burn transaction %v after %v attempts: %v", params, attempts, response.Error.Message)
+			if err := watcher.quarantine(burn, params.Tx.Hash.String(), attempts, response.Error.Message); err != nil {
+				watcher.logger.Errorf("[watcher] cannot quarantine burn for %v with nonce=%v: %v", watcher.selector.String(), pack.NewU256(nonce), err)
+				watcher.recordError(fmt.Errorf("quarantining burn with nonce=%v: %v", pack.NewU256(nonce), err))
+				return
+			}
+			continue
 		}
+		watcher.succeeded(burn)
+		watcher.recordBurn(burn, params.Tx.Hash.String(), db.BurnStatusSubmitted, "")
 	}
 
-	if err := watcher.cache.Set(watcher.key(), currentHeight, 0).Err(); err != nil {
-		watcher.logger.Errorf("[watcher] error setting last checked block number in redis: %v", err)
+	if err := watcher.setLastCheckedBlockNumber(cp); err != nil {
+		watcher.logger.Errorf("[watcher] error setting last checked block number: %v", err)
+		watcher.recordError(fmt.Errorf("setting last checked block number: %v", err))
 		return
 	}
-}
-
-// key returns the key that is used to store the last checked block.
-func (watcher Watcher) key() string {
-	return fmt.Sprintf("%v_lastCheckedBlock", watcher.selector.String())
+	watcher.state.update(func(status *Status) { status.Cursor = currentHeight })
 }
 
 // lastCheckedBlockNumber returns the last checked block number of Ethereum.
 func (watcher Watcher) lastCheckedBlockNumber(currentBlockN uint64) (uint64, error) {
-	last, err := watcher.cache.Get(watcher.key()).Uint64()
+	last, ok, err := watcher.cursor.lastChecked()
+	if err != nil {
+		return 0, err
+	}
 	// Initialise the pointer with current block number if it has not been yet.
-	if err == redis.Nil {
+	if !ok {
 		watcher.logger.Warnf("[watcher] last checked block number not initialised")
-		if err := watcher.cache.Set(watcher.key(), currentBlockN, 0).Err(); err != nil {
-			watcher.logger.Errorf("[watcher] cannot initialise last checked block in redis: %v", err)
+		if err := watcher.cursor.advance(checkpoint{height: currentBlockN}); err != nil {
+			watcher.logger.Errorf("[watcher] cannot initialise last checked block: %v", err)
 			return 0, err
 		}
 		return currentBlockN, nil
 	}
+	return last, nil
+}
 
-	return last, err
+// recordBurn stores the result of forwarding the burn, so that forwarded burns
+// can be audited. Failing to record a burn does not stop the watcher.
+func (watcher Watcher) recordBurn(burn BurnInfo, txHash string, status db.BurnStatus, reason string) {
+	if watcher.db == nil {
+		return
+	}
+	record := db.Burn{
+		Selector: watcher.selector.String(),
+		Nonce:    pack.NewU256(burn.Nonce).String(),
+		Txid:     burn.Txid.String(),
+		Block:    uint64(burn.BlockNumber),
+		TxHash:   txHash,
+		Status:   status,
+		Error:    reason,
+	}
+	if err := watcher.db.InsertBurn(record); err != nil {
+		watcher.logger.Errorf("[watcher] cannot record burn for %v with nonce=%v: %v", watcher.selector.String(), record.Nonce, err)
+	}
 }
 
 // burnToParams constructs params for a SubmitTx request with given ref.
 func (watcher Watcher) burnToParams(txid pack.Bytes, amount pack.U256, toBytes []byte, nonce pack.Bytes32) (jsonrpc.ParamsSubmitTx, error) {
+	params, err := watcher.burnToTx(txid, amount, toBytes, nonce)
+	if err != nil {
+		return jsonrpc.ParamsSubmitTx{}, err
+	}
+
+	// Map the v0 burn txhash to v1 txhash so that it is still
+	// queryable
+	// We don't get the required data during tx submission rpc to track it there,
+	// so we persist here in order to not re-filter all burn events
+	v0Hash := v0.BurnTxHash(watcher.selector, pack.NewU256(nonce))
+	watcher.cache.Set(v0Hash.String(), params.Tx.Hash.String(), watcher.mappingExpiry)
+
+	// Map the selector + burn ref to the v0 hash so that we can return something
+	// to ren-js v1
+	watcher.cache.Set(fmt.Sprintf("%s_%v", watcher.selector, pack.NewU256(nonce).String()), v0Hash.String(), watcher.mappingExpiry)
+
+	return params, nil
+}
+
+// burnToTx constructs params for a SubmitTx request with given ref, without
+// storing anything.
+func (watcher Watcher) burnToTx(txid pack.Bytes, amount pack.U256, toBytes []byte, nonce pack.Bytes32) (jsonrpc.ParamsSubmitTx, error) {
 	var to multichain.Address
 	var toDecoded []byte
 	var err error
@@ -458,17 +844,6 @@ func (watcher Watcher) burnToParams(txid pack.Bytes, amount pack.U256, toBytes [
 		Input:    pack.Typed(input.(pack.Struct)),
 	}
 
-	// Map the v0 burn txhash to v1 txhash so that it is still
-	// queryable
-	// We don't get the required data during tx submission rpc to track it there,
-	// so we persist here in order to not re-filter all burn events
-	v0Hash := v0.BurnTxHash(watcher.selector, pack.NewU256(nonce))
-	watcher.cache.Set(v0Hash.String(), transaction.Hash.String(), 0)
-
-	// Map the selector + burn ref to the v0 hash so that we can return something
-	// to ren-js v1
-	watcher.cache.Set(fmt.Sprintf("%s_%v", watcher.selector, pack.NewU256(nonce).String()), v0Hash.String(), 0)
-
 	return jsonrpc.ParamsSubmitTx{Tx: transaction}, nil
 }
 
@@ -489,7 +864,7 @@ func (watcher Watcher) handleAssetAddr(toBytes []byte) (multichain.Address, []by
 	}
 
 	burnChain := watcher.selector.Destination()
-	toBytes, err := watcher.bindings.DecodeAddress(burnChain, to)
+	toBytes, err := watcher.bindings.Get().DecodeAddress(burnChain, to)
 	if err != nil {
 		return "", nil, err
 	}
@@ -1,9 +1,16 @@
 package watcher_test
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -14,10 +21,16 @@ import (
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v7"
+	"github.com/jbenet/go-base58"
 	"github.com/renproject/darknode/binding"
+	"github.com/renproject/darknode/jsonrpc"
 	"github.com/renproject/darknode/jsonrpc/jsonrpcresolver"
 	"github.com/renproject/darknode/tx"
+	"github.com/renproject/id"
+	"github.com/renproject/lightnode/admin"
 	v0 "github.com/renproject/lightnode/compat/v0"
+	"github.com/renproject/lightnode/db"
+	lightnoderesolver "github.com/renproject/lightnode/resolver"
 	"github.com/renproject/multichain"
 	"github.com/renproject/pack"
 	"github.com/sirupsen/logrus"
@@ -158,7 +171,7 @@ var _ = Describe("Watcher", func() {
 			live = true
 		}
 
-		watcher := NewWatcher(logger, multichain.NetworkDevnet, selector, bindings, fetcher, heightFetcher, mockResolver, client, interval, 1000, 6)
+		watcher := NewWatcher(logger, multichain.NetworkDevnet, selector, bindings, fetcher, heightFetcher, mockResolver, client, nil, interval, 1000, 6)
 
 		return watcher, client, burnIn, mr
 	}
@@ -214,6 +227,10 @@ var _ = Describe("Watcher", func() {
 				h := redisClient.Get(fmt.Sprintf("BTC/fromEthereum_%v", 0)).Val()
 				return h
 			}, 15*time.Second, time.Second).Should(Equal(v0Hash.String()))
+
+			// The mappings expire along with the transactions.
+			Expect(redisClient.TTL(fmt.Sprintf("BTC/fromEthereum_%v", 0)).Val()).To(BeNumerically(">", 0))
+			Expect(redisClient.TTL(v0Hash.String()).Val()).To(BeNumerically(">", 0))
 		})
 
 		It("should not process burn events in the future or the past", func() {
@@ -675,9 +692,10 @@ var _ = Describe("Watcher", func() {
 				})
 
 			bindings := binding.New(bindingsOpts)
-			solClient := solanaRPC.NewClient(bindingsOpts.Chains[multichain.Solana].RPC.String())
+			solRPC := bindingsOpts.Chains[multichain.Solana].RPC.String()
+			solClient := solanaRPC.NewClient(solRPC)
 			btcGateway := bindings.ContractGateway(multichain.Solana, multichain.BTC)
-			burnLogFetcher := NewSolFetcher(solClient, string(btcGateway))
+			burnLogFetcher := NewSolFetcher(solClient, solRPC, string(btcGateway))
 
 			results, err := burnLogFetcher.FetchBurnLogs(ctx, 0, 0)
 			Expect(err).ToNot(HaveOccurred())
@@ -710,7 +728,7 @@ var _ = Describe("Watcher", func() {
 			// We set the last checked block manually, because it will always start after the last checked burn
 			client.Set("BTC/fromSolana_lastCheckedBlock", 1, 0)
 
-			watcher := NewWatcher(logger, multichain.NetworkDevnet, selector, bindings, burnLogFetcher, burnLogFetcher, mockResolver, client, time.Second, 1000, 6)
+			watcher := NewWatcher(logger, multichain.NetworkDevnet, selector, bindings, burnLogFetcher, burnLogFetcher, mockResolver, client, nil, time.Second, 1000, 0)
 
 			go watcher.Run(ctx)
 
@@ -736,9 +754,10 @@ var _ = Describe("Watcher", func() {
 				})
 
 			bindings := binding.New(bindingsOpts)
-			solClient := solanaRPC.NewClient(bindingsOpts.Chains[multichain.Solana].RPC.String())
+			solRPC := bindingsOpts.Chains[multichain.Solana].RPC.String()
+			solClient := solanaRPC.NewClient(solRPC)
 			btcGateway := bindings.ContractGateway(multichain.Solana, multichain.BTC)
-			burnLogFetcher := NewSolFetcher(solClient, string(btcGateway))
+			burnLogFetcher := NewSolFetcher(solClient, solRPC, string(btcGateway))
 
 			results, err := burnLogFetcher.FetchBurnLogs(ctx, 0, 0)
 			Expect(err).ToNot(HaveOccurred())
@@ -780,3 +799,1107 @@ var _ = Describe("Watcher", func() {
 	})
 
 })
+
+// countingBlockHeightFetcher counts how many times it is called, and always
+// fails so that the watcher does nothing else.
+type countingBlockHeightFetcher struct {
+	calls *int64
+}
+
+func (fetcher countingBlockHeightFetcher) FetchBlockHeight(ctx context.Context) (uint64, error) {
+	atomic.AddInt64(fetcher.calls, 1)
+	return 0, fmt.Errorf("unavailable")
+}
+
+var _ = Describe("Manager", func() {
+	Context("when the whitelist changes", func() {
+		It("should start and stop watchers", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mr, err := miniredis.Run()
+			Expect(err).ShouldNot(HaveOccurred())
+			defer mr.Close()
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer client.Close()
+
+			logger := logrus.New()
+			logger.SetLevel(logrus.ErrorLevel)
+
+			calls := map[tx.Selector]*int64{}
+			mu := new(sync.Mutex)
+			factory := func(selector tx.Selector) (Watcher, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls[selector] = new(int64)
+				heightFetcher := countingBlockHeightFetcher{calls: calls[selector]}
+				return NewWatcher(logger, multichain.NetworkDevnet, selector, nil, NewMockBurnLogFetcher(nil), heightFetcher, jsonrpcresolver.OkResponder(), client, nil, 10*time.Millisecond, 1000, 6), nil
+			}
+			count := func(selector tx.Selector) int64 {
+				mu.Lock()
+				defer mu.Unlock()
+				if calls[selector] == nil {
+					return 0
+				}
+				return atomic.LoadInt64(calls[selector])
+			}
+
+			btc := tx.Selector("BTC/fromEthereum")
+			zec := tx.Selector("ZEC/fromEthereum")
+			lock := tx.Selector("BTC/toEthereum")
+
+			manager := NewManager(logger, factory)
+			manager.SetWhitelist([]tx.Selector{btc, lock})
+			go manager.Run(ctx)
+
+			Eventually(func() int64 { return count(btc) }).Should(BeNumerically(">", 0))
+			Expect(count(lock)).To(BeZero())
+
+			manager.SetWhitelist([]tx.Selector{zec})
+			Eventually(func() int64 { return count(zec) }).Should(BeNumerically(">", 0))
+
+			// Allow a poll which was in progress when the watcher was stopped
+			// to finish.
+			time.Sleep(50 * time.Millisecond)
+			stopped := count(btc)
+			time.Sleep(100 * time.Millisecond)
+			Expect(count(btc)).To(Equal(stopped))
+		})
+	})
+
+	Context("when a watcher cannot be created", func() {
+		It("should retry the selector until it can be watched", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mr, err := miniredis.Run()
+			Expect(err).ShouldNot(HaveOccurred())
+			defer mr.Close()
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer client.Close()
+
+			logger := logrus.New()
+			logger.SetLevel(logrus.ErrorLevel)
+
+			attempts := int64(0)
+			calls := new(int64)
+			factory := func(selector tx.Selector) (Watcher, error) {
+				if atomic.AddInt64(&attempts, 1) < 3 {
+					return Watcher{}, fmt.Errorf("unavailable")
+				}
+				heightFetcher := countingBlockHeightFetcher{calls: calls}
+				return NewWatcher(logger, multichain.NetworkDevnet, selector, nil, NewMockBurnLogFetcher(nil), heightFetcher, jsonrpcresolver.OkResponder(), client, nil, 10*time.Millisecond, 1000, 6), nil
+			}
+
+			btc := tx.Selector("BTC/fromEthereum")
+			manager := NewManager(logger, factory).WithRetryInterval(10 * time.Millisecond)
+			manager.SetWhitelist([]tx.Selector{btc})
+			_, ok := manager.Watcher(btc)
+			Expect(ok).To(BeFalse())
+			go manager.Run(ctx)
+
+			Eventually(func() bool {
+				_, ok := manager.Watcher(btc)
+				return ok
+			}).Should(BeTrue())
+			Eventually(func() int64 { return atomic.LoadInt64(calls) }).Should(BeNumerically(">", 0))
+			Consistently(func() int64 { return atomic.LoadInt64(&attempts) }).Should(Equal(int64(3)))
+		})
+	})
+})
+
+// mockChain is a chain whose blocks can be replaced to simulate a reorg. The
+// hash of a block is derived from its height and the fork it belongs to.
+type mockChain struct {
+	mu     *sync.Mutex
+	height uint64
+	forkAt uint64
+	calls  [][2]uint64
+	burns  []BurnInfo
+
+	// forkOnFetch, if set, is the height from which the blocks are replaced
+	// while the next burn logs are fetched.
+	forkOnFetch uint64
+}
+
+func (chain *mockChain) hash(height uint64) string {
+	if chain.forkAt > 0 && height >= chain.forkAt {
+		return fmt.Sprintf("fork-%v", height)
+	}
+	return fmt.Sprintf("block-%v", height)
+}
+
+func (chain *mockChain) FetchBlockHeight(ctx context.Context) (uint64, error) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	return chain.height, nil
+}
+
+func (chain *mockChain) FetchBlockHash(ctx context.Context, height uint64) (string, string, error) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	return chain.hash(height), chain.hash(height - 1), nil
+}
+
+func (chain *mockChain) FetchBurnLogs(ctx context.Context, from uint64, to uint64) (chan BurnLogResult, error) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	chain.calls = append(chain.calls, [2]uint64{from, to})
+	if chain.forkOnFetch > 0 {
+		chain.forkAt, chain.forkOnFetch = chain.forkOnFetch, 0
+	}
+	c := make(chan BurnLogResult, len(chain.burns))
+	for _, burn := range chain.burns {
+		if uint64(burn.BlockNumber) > from && uint64(burn.BlockNumber) <= to {
+			c <- BurnLogResult{Result: burn}
+		}
+	}
+	close(c)
+	return c, nil
+}
+
+func (chain *mockChain) set(height, forkAt uint64) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	chain.height = height
+	chain.forkAt = forkAt
+}
+
+func (chain *mockChain) forkDuringFetch(height, forkAt uint64) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	chain.height = height
+	chain.forkOnFetch = forkAt
+}
+
+func (chain *mockChain) fetched(from, to uint64) bool {
+	return chain.fetchedTimes(from, to) > 0
+}
+
+func (chain *mockChain) fetchedTimes(from, to uint64) int {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	times := 0
+	for _, call := range chain.calls {
+		if call[0] == from && call[1] == to {
+			times++
+		}
+	}
+	return times
+}
+
+// initStores returns a redis server, a client connected to it and an
+// in-memory database, along with a function which closes them.
+func initStores() (*miniredis.Miniredis, *redis.Client, db.DB, func()) {
+	mr, err := miniredis.Run()
+	Expect(err).ShouldNot(HaveOccurred())
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	Expect(err).ShouldNot(HaveOccurred())
+	// Every connection opens a separate in-memory database.
+	sqlDB.SetMaxOpenConns(1)
+	database := db.New(sqlDB, 100)
+	Expect(database.Init()).Should(Succeed())
+
+	return mr, client, database, func() {
+		sqlDB.Close()
+		client.Close()
+		mr.Close()
+	}
+}
+
+// dbWatcherSelector is the selector of the watchers run by runDBWatcher.
+const dbWatcherSelector = tx.Selector("BTC/fromEthereum")
+
+// runDBWatcher runs a watcher with a database over a chain with the given
+// burns. The watcher has checked up to block 100, and the chain is at block
+// 110. It returns the watcher, its database and a function which stops it.
+func runDBWatcher(burns []BurnInfo, resolver jsonrpc.Resolver, backpressure Backpressure) (Watcher, db.DB, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, client, database, closeStores := initStores()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	Expect(database.InsertCheckpoint(db.Checkpoint{Selector: string(dbWatcherSelector), Height: 100, Hash: "block-100"})).Should(Succeed())
+	chain := &mockChain{mu: new(sync.Mutex), height: 110, burns: burns}
+	watcher := NewWatcher(logger, multichain.NetworkDevnet, dbWatcherSelector, nil, chain, chain, resolver, client, database, 10*time.Millisecond, 1000, 0).
+		WithBackpressure(backpressure)
+	go watcher.Run(ctx)
+
+	return watcher, database, func() {
+		cancel()
+		closeStores()
+	}
+}
+
+// checkedUpTo110 returns whether the watcher run by runDBWatcher has checked
+// every block of its chain.
+func checkedUpTo110(database db.DB) func() ([]db.Checkpoint, error) {
+	return func() ([]db.Checkpoint, error) {
+		return database.Checkpoints(string(dbWatcherSelector), 1)
+	}
+}
+
+// checkpoint110 is the checkpoint of a watcher run by runDBWatcher which has
+// checked every block of its chain.
+var checkpoint110 = []db.Checkpoint{{Selector: string(dbWatcherSelector), Height: 110, Hash: "block-110"}}
+
+var _ = Describe("Reorgs", func() {
+	Context("when the chain is reorganised", func() {
+		It("should rewind to the last block which is still part of the chain", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mr, err := miniredis.Run()
+			Expect(err).ShouldNot(HaveOccurred())
+			defer mr.Close()
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer client.Close()
+
+			logger := logrus.New()
+			logger.SetLevel(logrus.ErrorLevel)
+
+			selector := tx.Selector("BTC/fromEthereum")
+			key := "BTC/fromEthereum_lastCheckedBlock"
+			Expect(client.Set(key, 100, 0).Err()).ShouldNot(HaveOccurred())
+
+			chain := &mockChain{mu: new(sync.Mutex), height: 110}
+			watcher := NewWatcher(logger, multichain.NetworkDevnet, selector, nil, chain, chain, jsonrpcresolver.OkResponder(), client, nil, 10*time.Millisecond, 1000, 0)
+			go watcher.Run(ctx)
+
+			Eventually(func() (uint64, error) { return client.Get(key).Uint64() }).Should(Equal(uint64(110)))
+			chain.set(120, 0)
+			Eventually(func() (uint64, error) { return client.Get(key).Uint64() }).Should(Equal(uint64(120)))
+
+			// Replace the blocks after the first checkpoint.
+			chain.set(125, 115)
+			Eventually(func() bool { return chain.fetched(110, 125) }).Should(BeTrue())
+			Eventually(func() ([]string, error) {
+				return client.LRange("BTC/fromEthereum_checkpoints", 0, -1).Result()
+			}).Should(Equal([]string{"125:fork-125", "110:block-110"}))
+		})
+
+		It("should detect a reorg which happens while the logs are fetched", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mr, err := miniredis.Run()
+			Expect(err).ShouldNot(HaveOccurred())
+			defer mr.Close()
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer client.Close()
+
+			logger := logrus.New()
+			logger.SetLevel(logrus.FatalLevel)
+
+			selector := tx.Selector("BTC/fromEthereum")
+			key := "BTC/fromEthereum_lastCheckedBlock"
+			Expect(client.Set(key, 100, 0).Err()).ShouldNot(HaveOccurred())
+
+			chain := &mockChain{mu: new(sync.Mutex), height: 110}
+			watcher := NewWatcher(logger, multichain.NetworkDevnet, selector, nil, chain, chain, jsonrpcresolver.OkResponder(), client, nil, 10*time.Millisecond, 1000, 0)
+			go watcher.Run(ctx)
+			Eventually(func() (uint64, error) { return client.Get(key).Uint64() }).Should(Equal(uint64(110)))
+
+			// The blocks are replaced after the checkpoint is fetched, so the
+			// logs of the replaced blocks must be fetched again.
+			chain.forkDuringFetch(120, 115)
+			Eventually(func() int { return chain.fetchedTimes(110, 120) }).Should(Equal(2))
+			Eventually(func() ([]string, error) {
+				return client.LRange("BTC/fromEthereum_checkpoints", 0, -1).Result()
+			}).Should(Equal([]string{"120:fork-120", "110:block-110"}))
+		})
+	})
+})
+
+var _ = Describe("Database", func() {
+	Context("when the watcher has a database", func() {
+		It("should migrate the cursor from redis and store the checkpoints and burns", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			_, client, database, closeStores := initStores()
+			defer closeStores()
+
+			logger := logrus.New()
+			logger.SetLevel(logrus.FatalLevel)
+
+			// The cursor which was stored in redis is migrated.
+			selector := tx.Selector("BTC/fromEthereum")
+			Expect(client.Set("BTC/fromEthereum_lastCheckedBlock", 100, 0).Err()).ShouldNot(HaveOccurred())
+			checkpoints, err := database.Checkpoints(selector.String(), 1)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(checkpoints).Should(BeEmpty())
+
+			burns := []BurnInfo{
+				{
+					Txid:        pack.Bytes{1, 2, 3},
+					Amount:      pack.NewU256FromU64(pack.NewU64(1000)),
+					ToBytes:     []byte("not an address"),
+					Nonce:       pack.NewU256FromU64(pack.NewU64(7)).Bytes32(),
+					BlockNumber: pack.NewU64(105),
+				},
+				{
+					Txid:        pack.Bytes{4, 5, 6},
+					Amount:      pack.NewU256FromU64(pack.NewU64(1000)),
+					ToBytes:     []byte("miMi2VET41YV1j6SDNTeZoPBbmH8B4nEx6"),
+					Nonce:       pack.NewU256FromU64(pack.NewU64(8)).Bytes32(),
+					BlockNumber: pack.NewU64(106),
+				},
+			}
+			chain := &mockChain{mu: new(sync.Mutex), height: 110, burns: burns}
+			watcher := NewWatcher(logger, multichain.NetworkDevnet, selector, nil, chain, chain, jsonrpcresolver.OkResponder(), client, database, 10*time.Millisecond, 1000, 0)
+			go watcher.Run(ctx)
+
+			Eventually(func() ([]db.Checkpoint, error) {
+				return database.Checkpoints(selector.String(), 1)
+			}).Should(Equal([]db.Checkpoint{{Selector: selector.String(), Height: 110, Hash: "block-110"}}))
+			Expect(chain.fetched(100, 110)).Should(BeTrue())
+
+			burn, err := database.Burn(selector.String(), "7")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(burn.Block).Should(Equal(uint64(105)))
+			Expect(burn.Status).Should(Equal(db.BurnStatusFailed))
+			Expect(burn.Error).ShouldNot(BeEmpty())
+
+			burn, err = database.Burn(selector.String(), "8")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(burn.Block).Should(Equal(uint64(106)))
+			Expect(burn.Status).Should(Equal(db.BurnStatusSubmitted))
+			Expect(burn.TxHash).ShouldNot(BeEmpty())
+			Expect(burn.Error).Should(BeEmpty())
+
+			// Once the first checkpoint is recorded, the cursor in redis is no
+			// longer used.
+			Expect(client.Set("BTC/fromEthereum_lastCheckedBlock", 50, 0).Err()).ShouldNot(HaveOccurred())
+			chain.set(120, 0)
+			Eventually(func() bool { return chain.fetched(110, 120) }).Should(BeTrue())
+			Expect(chain.fetched(50, 120)).Should(BeFalse())
+		})
+	})
+})
+
+// recordingResolver records the hashes of the txs which are submitted to it.
+type recordingResolver struct {
+	jsonrpc.Resolver
+
+	mu        *sync.Mutex
+	submitted []id.Hash
+}
+
+func (resolver *recordingResolver) SubmitTx(ctx context.Context, reqID interface{}, params *jsonrpc.ParamsSubmitTx, req *http.Request) jsonrpc.Response {
+	resolver.mu.Lock()
+	resolver.submitted = append(resolver.submitted, params.Tx.Hash)
+	resolver.mu.Unlock()
+	return resolver.Resolver.SubmitTx(ctx, reqID, params, req)
+}
+
+var _ = Describe("Backfill", func() {
+	selector := tx.Selector("BTC/fromEthereum")
+
+	// initBackfill returns a watcher over a chain with a valid burn in each of
+	// the blocks 101 to 104, with nonces 0 to 3.
+	initBackfill := func(database db.DB, client *redis.Client) (Watcher, *recordingResolver) {
+		logger := logrus.New()
+		logger.SetLevel(logrus.FatalLevel)
+
+		burns := make([]BurnInfo, 4)
+		for i := range burns {
+			burns[i] = BurnInfo{
+				Txid:        pack.Bytes{byte(i)},
+				Amount:      pack.NewU256FromU64(pack.NewU64(1000)),
+				ToBytes:     []byte("miMi2VET41YV1j6SDNTeZoPBbmH8B4nEx6"),
+				Nonce:       pack.NewU256FromU64(pack.NewU64(uint64(i))).Bytes32(),
+				BlockNumber: pack.NewU64(uint64(101 + i)),
+			}
+		}
+		chain := &mockChain{mu: new(sync.Mutex), height: 110, burns: burns}
+		resolver := &recordingResolver{Resolver: jsonrpcresolver.OkResponder(), mu: new(sync.Mutex)}
+		watcher := NewWatcher(logger, multichain.NetworkDevnet, selector, nil, chain, chain, resolver, client, database, time.Second, 1000, 0)
+		return watcher, resolver
+	}
+
+	Context("when dry running", func() {
+		It("should not submit or store anything", func() {
+			mr, client, database, closeStores := initStores()
+			defer closeStores()
+			watcher, resolver := initBackfill(database, client)
+
+			results, err := watcher.Backfill(context.Background(), 101, 103, true)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(results).Should(HaveLen(2))
+			for i, result := range results {
+				Expect(result.Burn.BlockNumber).Should(Equal(pack.NewU64(uint64(102 + i))))
+				Expect(result.Status).Should(Equal(BackfillStatusPending))
+				Expect(result.Error).ShouldNot(HaveOccurred())
+			}
+
+			// Nothing is submitted or stored by a dry run.
+			Expect(resolver.submitted).Should(BeEmpty())
+			_, err = database.Burn(selector.String(), "1")
+			Expect(err).Should(Equal(sql.ErrNoRows))
+			Expect(mr.Keys()).Should(BeEmpty())
+		})
+	})
+
+	Context("when submitting", func() {
+		It("should skip known burns, submit the others and release them from quarantine", func() {
+			_, client, database, closeStores := initStores()
+			defer closeStores()
+			watcher, resolver := initBackfill(database, client)
+
+			pending, err := watcher.Backfill(context.Background(), 100, 104, true)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(pending).Should(HaveLen(4))
+
+			// The tx of the first burn is stored, the second burn was
+			// submitted by the watcher and the third burn is quarantined.
+			Expect(database.InsertTx(pending[0].Params.Tx)).Should(Succeed())
+			Expect(database.InsertBurn(db.Burn{
+				Selector: selector.String(),
+				Nonce:    "1",
+				Txid:     pending[1].Burn.Txid.String(),
+				Block:    102,
+				TxHash:   pending[1].Params.Tx.Hash.String(),
+				Status:   db.BurnStatusSubmitted,
+			})).Should(Succeed())
+			Expect(database.InsertQuarantinedBurn(db.QuarantinedBurn{
+				Selector: selector.String(),
+				Nonce:    "2",
+				Txid:     pending[2].Burn.Txid.String(),
+				Block:    103,
+				TxHash:   pending[2].Params.Tx.Hash.String(),
+				Attempts: 10,
+				Error:    "rejected",
+			})).Should(Succeed())
+
+			results, err := watcher.Backfill(context.Background(), 100, 104, false)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(results).Should(HaveLen(4))
+			Expect(results[0].Status).Should(Equal(BackfillStatusKnown))
+			Expect(results[1].Status).Should(Equal(BackfillStatusKnown))
+			Expect(results[2].Status).Should(Equal(BackfillStatusSubmitted))
+			Expect(results[3].Status).Should(Equal(BackfillStatusSubmitted))
+			Expect(resolver.submitted).Should(Equal([]id.Hash{pending[2].Params.Tx.Hash, pending[3].Params.Tx.Hash}))
+
+			for _, nonce := range []string{"2", "3"} {
+				burn, err := database.Burn(selector.String(), nonce)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(burn.Status).Should(Equal(db.BurnStatusSubmitted))
+			}
+			quarantined, err := watcher.Quarantined(0, 10)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(quarantined).Should(BeEmpty())
+
+			// Submitted burns are known to later backfills.
+			results, err = watcher.Backfill(context.Background(), 100, 104, false)
+			Expect(err).ShouldNot(HaveOccurred())
+			for _, result := range results {
+				Expect(result.Status).Should(Equal(BackfillStatusKnown))
+			}
+			Expect(resolver.submitted).Should(HaveLen(2))
+		})
+	})
+})
+
+// flakyFetcher is an endpoint which returns a fixed block height, or an error
+// while it is down.
+type flakyFetcher struct {
+	height uint64
+	down   *int32
+	calls  *int32
+}
+
+func newFlakyFetcher(height uint64) flakyFetcher {
+	return flakyFetcher{height: height, down: new(int32), calls: new(int32)}
+}
+
+func (fetcher flakyFetcher) FetchBlockHeight(ctx context.Context) (uint64, error) {
+	atomic.AddInt32(fetcher.calls, 1)
+	if atomic.LoadInt32(fetcher.down) == 1 {
+		return 0, fmt.Errorf("endpoint down")
+	}
+	return fetcher.height, nil
+}
+
+func (fetcher flakyFetcher) FetchBurnLogs(ctx context.Context, from uint64, to uint64) (chan BurnLogResult, error) {
+	atomic.AddInt32(fetcher.calls, 1)
+	if atomic.LoadInt32(fetcher.down) == 1 {
+		return nil, fmt.Errorf("endpoint down")
+	}
+	c := make(chan BurnLogResult)
+	close(c)
+	return c, nil
+}
+
+var _ = Describe("Failover", func() {
+	Context("when an endpoint fails", func() {
+		It("should use the next endpoint and avoid unhealthy endpoints", func() {
+			primary, fallback := newFlakyFetcher(100), newFlakyFetcher(99)
+			endpoints := NewEndpoints(2, DefaultFailoverOptions().WithMaxFailures(2).WithBackoff(time.Minute))
+			heightFetcher := NewFailoverBlockHeightFetcher(endpoints, []BlockHeightFetcher{primary, fallback})
+			burnLogFetcher := NewFailoverBurnLogFetcher(endpoints, []BurnLogFetcher{primary, fallback})
+
+			Expect(heightFetcher.FetchBlockHeight(context.Background())).Should(Equal(uint64(100)))
+
+			atomic.StoreInt32(primary.down, 1)
+			Expect(heightFetcher.FetchBlockHeight(context.Background())).Should(Equal(uint64(99)))
+			_, err := burnLogFetcher.FetchBurnLogs(context.Background(), 0, 10)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// The fallback has become the primary endpoint, so the failed
+			// endpoint is no longer tried first.
+			calls := atomic.LoadInt32(primary.calls)
+			Expect(heightFetcher.FetchBlockHeight(context.Background())).Should(Equal(uint64(99)))
+			Expect(atomic.LoadInt32(primary.calls)).Should(Equal(calls))
+			Expect(endpoints.Healthy()).Should(Equal([]bool{true, true}))
+
+			// Unhealthy endpoints are still tried as a last resort.
+			atomic.StoreInt32(fallback.down, 1)
+			_, err = heightFetcher.FetchBlockHeight(context.Background())
+			Expect(err).Should(HaveOccurred())
+			_, err = heightFetcher.FetchBlockHeight(context.Background())
+			Expect(err).Should(HaveOccurred())
+			Expect(endpoints.Healthy()).Should(Equal([]bool{false, false}))
+			atomic.StoreInt32(primary.down, 0)
+			Expect(heightFetcher.FetchBlockHeight(context.Background())).Should(Equal(uint64(100)))
+			Expect(endpoints.Healthy()).Should(Equal([]bool{true, false}))
+		})
+	})
+
+	Context("when a quorum is required", func() {
+		It("should return the highest height which enough endpoints have reached", func() {
+			fetchers := []BlockHeightFetcher{newFlakyFetcher(120), newFlakyFetcher(100), newFlakyFetcher(101)}
+			endpoints := NewEndpoints(3, DefaultFailoverOptions().WithQuorum(2))
+			heightFetcher := NewFailoverBlockHeightFetcher(endpoints, fetchers)
+			Expect(heightFetcher.FetchBlockHeight(context.Background())).Should(Equal(uint64(101)))
+
+			atomic.StoreInt32(fetchers[1].(flakyFetcher).down, 1)
+			atomic.StoreInt32(fetchers[2].(flakyFetcher).down, 1)
+			_, err := heightFetcher.FetchBlockHeight(context.Background())
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("Solana burns", func() {
+	Context("when reading burn logs", func() {
+		It("should read the accounts of a batch of burns in one request", func() {
+			requests := int32(0)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				var req struct {
+					Method string          `json:"method"`
+					Params [][]interface{} `json:"params"`
+				}
+				Expect(json.NewDecoder(r.Body).Decode(&req)).Should(Succeed())
+				Expect(req.Method).Should(Equal("getMultipleAccounts"))
+				Expect(req.Params[0]).Should(HaveLen(100))
+				// None of the burns exist yet.
+				values := make([]interface{}, len(req.Params[0]))
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"jsonrpc": "2.0",
+					"id":      1,
+					"result":  map[string]interface{}{"value": values},
+				})
+			}))
+			defer server.Close()
+
+			fetcher := NewSolFetcher(solanaRPC.NewClient(server.URL), server.URL, "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
+			c, err := fetcher.FetchBurnLogs(context.Background(), 1, 250)
+			Expect(err).ShouldNot(HaveOccurred())
+			res := <-c
+			Expect(res.Error).Should(HaveOccurred())
+			Eventually(c).Should(BeClosed())
+			Expect(atomic.LoadInt32(&requests)).Should(Equal(int32(1)))
+		})
+
+		It("should return an error if the node does not respond with OK", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte("unavailable"))
+			}))
+			defer server.Close()
+
+			fetcher := NewSolFetcher(solanaRPC.NewClient(server.URL), server.URL, "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
+			c, err := fetcher.FetchBurnLogs(context.Background(), 1, 4)
+			Expect(err).ShouldNot(HaveOccurred())
+			res := <-c
+			Expect(res.Error).Should(HaveOccurred())
+			Expect(res.Error.Error()).Should(ContainSubstring("503"))
+			Eventually(c).Should(BeClosed())
+		})
+	})
+
+	Context("when looking up the transactions of burns", func() {
+		// newSolanaRPC returns a node which serves a burn log account for
+		// each burn, and the signature of each burn which has one. The node
+		// has pruned its ledger up to the given slot.
+		firstBlockRequests := new(int32)
+		newSolanaRPC := func(signatures map[int]string, firstBlock uint64) *httptest.Server {
+			mu := new(sync.Mutex)
+			addresses := []string{}
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req struct {
+					ID     interface{}       `json:"id"`
+					Method string            `json:"method"`
+					Params []json.RawMessage `json:"params"`
+				}
+				Expect(json.NewDecoder(r.Body).Decode(&req)).Should(Succeed())
+
+				mu.Lock()
+				defer mu.Unlock()
+				var result interface{}
+				switch req.Method {
+				case "getMultipleAccounts":
+					Expect(json.Unmarshal(req.Params[0], &addresses)).Should(Succeed())
+					values := make([]interface{}, len(addresses))
+					for i := range addresses {
+						data := append([]byte{byte(i + 1)}, []byte("miMi2VET41YV1j6SDNTeZoPBbmH8B4nEx6")...)
+						values[i] = map[string]interface{}{"data": []string{base64.StdEncoding.EncodeToString(data), "base64"}}
+					}
+					result = map[string]interface{}{"value": values}
+				case "getSignaturesForAddress", "getConfirmedSignaturesForAddress2":
+					var address string
+					Expect(json.Unmarshal(req.Params[0], &address)).Should(Succeed())
+					results := []interface{}{}
+					for i := range addresses {
+						if sig, ok := signatures[i]; ok && addresses[i] == address {
+							results = append(results, map[string]interface{}{"signature": sig, "slot": 10})
+						}
+					}
+					result = results
+				case "getFirstAvailableBlock":
+					atomic.AddInt32(firstBlockRequests, 1)
+					result = firstBlock
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+			}))
+		}
+
+		// decodeBurnLog decodes the burn logs served by newSolanaRPC.
+		decodeBurnLog := func(data []byte) (pack.U256, string, error) {
+			return pack.NewU256FromU64(pack.NewU64(1000 * uint64(data[0]))), string(data[1:]), nil
+		}
+
+		fetchBurns := func(fetcher SolFetcher) ([]BurnInfo, error) {
+			c, err := fetcher.FetchBurnLogs(context.Background(), 1, 4)
+			Expect(err).ShouldNot(HaveOccurred())
+			burns := []BurnInfo{}
+			for res := range c {
+				if res.Error != nil {
+					return burns, res.Error
+				}
+				burns = append(burns, res.Result)
+			}
+			return burns, nil
+		}
+
+		signatures := map[int]string{
+			0: base58.Encode([]byte{1, 1, 1}),
+			2: base58.Encode([]byte{3, 3, 3}),
+		}
+
+		It("should flag a burn as pruned once the nodes have not returned its signature for long enough", func() {
+			server := newSolanaRPC(signatures, 1000)
+			defer server.Close()
+			fetcher := NewSolFetcher(solanaRPC.NewClient(server.URL), server.URL, "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA").
+				WithBurnLogDecoder(decodeBurnLog).
+				WithPruneAfter(100 * time.Millisecond)
+
+			// The burns are fetched again until the burn without a signature
+			// is flagged.
+			_, err := fetchBurns(fetcher)
+			Expect(err).Should(HaveOccurred())
+			var burns []BurnInfo
+			Eventually(func() error {
+				burns, err = fetchBurns(fetcher)
+				return err
+			}).Should(Succeed())
+
+			Expect(burns).Should(HaveLen(3))
+			for i, burn := range burns {
+				Expect(burn.Nonce).Should(Equal(pack.NewU256FromU64(pack.NewU64(uint64(i + 1))).Bytes32()))
+				Expect(burn.BlockNumber).Should(Equal(pack.NewU64(uint64(i + 1))))
+				Expect(burn.Amount).Should(Equal(pack.NewU256FromU64(pack.NewU64(1000 * uint64(i+1)))))
+				Expect(burn.ToBytes).Should(Equal([]byte("miMi2VET41YV1j6SDNTeZoPBbmH8B4nEx6")))
+			}
+			Expect(burns[0].Txid).Should(Equal(pack.Bytes{1, 1, 1}))
+			Expect(burns[0].Pruned).Should(BeFalse())
+			Expect(burns[1].Txid).Should(BeEmpty())
+			Expect(burns[1].Pruned).Should(BeTrue())
+			Expect(burns[2].Txid).Should(Equal(pack.Bytes{3, 3, 3}))
+			Expect(burns[2].Pruned).Should(BeFalse())
+		})
+
+		It("should not flag a burn as pruned if the ledger of the nodes is complete", func() {
+			server := newSolanaRPC(signatures, 0)
+			defer server.Close()
+			fetcher := NewSolFetcher(solanaRPC.NewClient(server.URL), server.URL, "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA").
+				WithBurnLogDecoder(decodeBurnLog).
+				WithPruneAfter(0)
+
+			_, err := fetchBurns(fetcher)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return the burns before the first burn without a signature", func() {
+			server := newSolanaRPC(signatures, 1000)
+			defer server.Close()
+			fetcher := NewSolFetcher(solanaRPC.NewClient(server.URL), server.URL, "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA").
+				WithBurnLogDecoder(decodeBurnLog)
+
+			burns, err := fetchBurns(fetcher)
+			Expect(err).Should(HaveOccurred())
+			Expect(burns).Should(HaveLen(1))
+			Expect(burns[0].Nonce).Should(Equal(pack.NewU256FromU64(pack.NewU64(1)).Bytes32()))
+			Expect(burns[0].Txid).Should(Equal(pack.Bytes{1, 1, 1}))
+		})
+
+		It("should only fetch the first available block once per batch", func() {
+			server := newSolanaRPC(map[int]string{0: base58.Encode([]byte{1, 1, 1})}, 1000)
+			defer server.Close()
+			fetcher := NewSolFetcher(solanaRPC.NewClient(server.URL), server.URL, "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA").
+				WithBurnLogDecoder(decodeBurnLog)
+
+			requests := atomic.LoadInt32(firstBlockRequests)
+			_, err := fetchBurns(fetcher)
+			Expect(err).Should(HaveOccurred())
+			Expect(atomic.LoadInt32(firstBlockRequests) - requests).Should(Equal(int32(1)))
+		})
+
+		It("should not flag a burn as pruned the first time it has no signature", func() {
+			server := newSolanaRPC(signatures, 1000)
+			defer server.Close()
+			fetcher := NewSolFetcher(solanaRPC.NewClient(server.URL), server.URL, "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA").
+				WithBurnLogDecoder(decodeBurnLog)
+
+			_, err := fetchBurns(fetcher)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when the transaction of a burn is no longer available", func() {
+		It("should flag the burn for review and continue", func() {
+			var nonce pack.Bytes32
+			nonce[31] = 3
+			burns := []BurnInfo{{
+				Txid:        pack.Bytes{},
+				Amount:      pack.NewU256FromU64(pack.NewU64(1000)),
+				Nonce:       nonce,
+				BlockNumber: pack.NewU64(105),
+				Pruned:      true,
+			}}
+			_, database, stop := runDBWatcher(burns, jsonrpcresolver.OkResponder(), MaxAttempts(DefaultMaxAttempts))
+			defer stop()
+
+			Eventually(func() (db.BurnStatus, error) {
+				burn, err := database.Burn(string(dbWatcherSelector), "3")
+				return burn.Status, err
+			}).Should(Equal(db.BurnStatusReview))
+			Eventually(checkedUpTo110(database)).Should(Equal(checkpoint110))
+		})
+	})
+})
+
+// rejectingResolver rejects every submission of the first tx it is sent.
+type rejectingResolver struct {
+	jsonrpc.Resolver
+
+	mu         *sync.Mutex
+	rejected   *id.Hash
+	rejections int
+}
+
+func (resolver *rejectingResolver) SubmitTx(ctx context.Context, reqID interface{}, params *jsonrpc.ParamsSubmitTx, req *http.Request) jsonrpc.Response {
+	resolver.mu.Lock()
+	if resolver.rejected == nil {
+		hash := params.Tx.Hash
+		resolver.rejected = &hash
+	}
+	if params.Tx.Hash == *resolver.rejected {
+		resolver.rejections++
+		resolver.mu.Unlock()
+		err := jsonrpc.NewError(jsonrpc.ErrorCodeInvalidParams, "rejected", nil)
+		return jsonrpc.NewResponse(reqID, nil, &err)
+	}
+	resolver.mu.Unlock()
+	return resolver.Resolver.SubmitTx(ctx, reqID, params, req)
+}
+
+func (resolver *rejectingResolver) numRejections() int {
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+	return resolver.rejections
+}
+
+// unavailableResolver fails the given number of submissions in the same way as
+// the lightnode does when the Darknodes cannot be reached.
+type unavailableResolver struct {
+	jsonrpc.Resolver
+
+	failures *int32
+}
+
+func newUnavailableResolver(failures int32) unavailableResolver {
+	return unavailableResolver{Resolver: jsonrpcresolver.OkResponder(), failures: &failures}
+}
+
+func (resolver unavailableResolver) SubmitTx(ctx context.Context, reqID interface{}, params *jsonrpc.ParamsSubmitTx, req *http.Request) jsonrpc.Response {
+	if atomic.AddInt32(resolver.failures, -1) >= 0 {
+		err := jsonrpc.NewError(jsonrpc.ErrorCodeInternal, "unable to query the network", nil)
+		return jsonrpc.NewResponse(reqID, nil, &err)
+	}
+	return resolver.Resolver.SubmitTx(ctx, reqID, params, req)
+}
+
+// unverifiableResolver fails the given number of submissions in the same way as
+// the lightnode does when a verifier cannot reach the host chain of a tx.
+type unverifiableResolver struct {
+	jsonrpc.Resolver
+
+	failures *int32
+}
+
+func newUnverifiableResolver(failures int32) unverifiableResolver {
+	return unverifiableResolver{Resolver: jsonrpcresolver.OkResponder(), failures: &failures}
+}
+
+func (resolver unverifiableResolver) SubmitTx(ctx context.Context, reqID interface{}, params *jsonrpc.ParamsSubmitTx, req *http.Request) jsonrpc.Response {
+	if atomic.AddInt32(resolver.failures, -1) >= 0 {
+		verifyErr := fmt.Errorf("dial tcp 127.0.0.1:8545: connect: connection refused")
+		err := jsonrpc.NewError(lightnoderesolver.VerificationErrorCode(ctx, verifyErr), verifyErr.Error(), nil)
+		return jsonrpc.NewResponse(reqID, nil, &err)
+	}
+	return resolver.Resolver.SubmitTx(ctx, reqID, params, req)
+}
+
+var _ = Describe("Backpressure", func() {
+	// burns returns valid burns with the given nonces, each in the block after
+	// the previous one.
+	burns := func(nonces ...uint64) []BurnInfo {
+		burns := make([]BurnInfo, len(nonces))
+		for i, nonce := range nonces {
+			burns[i] = BurnInfo{
+				Txid:        pack.Bytes{byte(nonce)},
+				Amount:      pack.NewU256FromU64(pack.NewU64(1000)),
+				ToBytes:     []byte("miMi2VET41YV1j6SDNTeZoPBbmH8B4nEx6"),
+				Nonce:       pack.NewU256FromU64(pack.NewU64(nonce)).Bytes32(),
+				BlockNumber: pack.NewU64(102 + nonce),
+			}
+		}
+		return burns
+	}
+
+	expectStatus := func(database db.DB, nonce string, status db.BurnStatus) {
+		burn, err := database.Burn(string(dbWatcherSelector), nonce)
+		ExpectWithOffset(1, err).ShouldNot(HaveOccurred())
+		ExpectWithOffset(1, burn.Status).Should(Equal(status))
+	}
+
+	Context("when a burn is rejected repeatedly", func() {
+		It("should quarantine the burn and forward the burns after it", func() {
+			resolver := &rejectingResolver{Resolver: jsonrpcresolver.OkResponder(), mu: new(sync.Mutex)}
+			watcher, database, stop := runDBWatcher(burns(3, 4), resolver, MaxAttempts(3))
+			defer stop()
+
+			Eventually(checkedUpTo110(database)).Should(Equal(checkpoint110))
+
+			quarantined, err := watcher.Quarantined(0, 10)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(quarantined).Should(HaveLen(1))
+			Expect(quarantined[0].Nonce).Should(Equal("3"))
+			Expect(quarantined[0].Attempts).Should(Equal(3))
+			Expect(quarantined[0].Error).Should(Equal("rejected"))
+
+			expectStatus(database, "3", db.BurnStatusQuarantined)
+			expectStatus(database, "4", db.BurnStatusSubmitted)
+		})
+
+		It("should not submit a quarantined burn again when a later burn is retried", func() {
+			// The burn after the rejected burn cannot be submitted for a
+			// while, so the logs of both burns are fetched again after the
+			// first burn has been quarantined.
+			resolver := &rejectingResolver{Resolver: newUnavailableResolver(5), mu: new(sync.Mutex)}
+			watcher, database, stop := runDBWatcher(burns(3, 4), resolver, MaxAttempts(3))
+			defer stop()
+
+			Eventually(checkedUpTo110(database)).Should(Equal(checkpoint110))
+			Expect(resolver.numRejections()).Should(Equal(3))
+
+			quarantined, err := watcher.Quarantined(0, 10)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(quarantined).Should(HaveLen(1))
+			Expect(quarantined[0].Nonce).Should(Equal("3"))
+			Expect(quarantined[0].Attempts).Should(Equal(3))
+
+			expectStatus(database, "3", db.BurnStatusQuarantined)
+			expectStatus(database, "4", db.BurnStatusSubmitted)
+		})
+	})
+
+	Context("when the darknodes cannot be reached", func() {
+		It("should retry the burn without quarantining it", func() {
+			watcher, database, stop := runDBWatcher(burns(3, 4), newUnavailableResolver(10), MaxAttempts(3))
+			defer stop()
+
+			Eventually(checkedUpTo110(database)).Should(Equal(checkpoint110))
+
+			quarantined, err := watcher.Quarantined(0, 10)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(quarantined).Should(BeEmpty())
+
+			expectStatus(database, "3", db.BurnStatusSubmitted)
+			expectStatus(database, "4", db.BurnStatusSubmitted)
+		})
+	})
+
+	Context("when the verifier cannot reach the host chain", func() {
+		It("should retry the burn without quarantining it", func() {
+			watcher, database, stop := runDBWatcher(burns(3, 4), newUnverifiableResolver(10), MaxAttempts(3))
+			defer stop()
+
+			Eventually(checkedUpTo110(database)).Should(Equal(checkpoint110))
+
+			quarantined, err := watcher.Quarantined(0, 10)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(quarantined).Should(BeEmpty())
+
+			expectStatus(database, "3", db.BurnStatusSubmitted)
+			expectStatus(database, "4", db.BurnStatusSubmitted)
+		})
+	})
+})
+
+var _ = Describe("Registry", func() {
+	Context("when looking up the family of a chain", func() {
+		It("should return the family of supported chains", func() {
+			registry := DefaultRegistry()
+			family, err := registry.Family(multichain.Ethereum)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(family.Indexed).Should(BeFalse())
+			family, err = registry.Family(multichain.Solana)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(family.Indexed).Should(BeTrue())
+		})
+
+		It("should return an error for unsupported chains", func() {
+			_, err := DefaultRegistry().Family(multichain.Bitcoin)
+			Expect(err).Should(HaveOccurred())
+			_, err = DefaultRegistry().Family(multichain.Terra)
+			Expect(err).Should(HaveOccurred())
+			_, err = DefaultRegistry().Family(multichain.Filecoin)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return the families which are registered", func() {
+			chain := &mockChain{mu: new(sync.Mutex), height: 100}
+			registry := DefaultRegistry()
+			registry.Register(ChainFamily("mock"), Family{
+				NewFetchers: func(rpc string, gateway string) (BurnLogFetcher, BlockHeightFetcher, error) {
+					return chain, chain, nil
+				},
+				Indexed: true,
+			}, multichain.Terra)
+
+			family, err := registry.Family(multichain.Terra)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(family.Indexed).Should(BeTrue())
+			burnLogFetcher, blockHeightFetcher, err := family.NewFetchers("http://localhost", "gateway")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(burnLogFetcher).Should(BeIdenticalTo(chain))
+			Expect(blockHeightFetcher).Should(BeIdenticalTo(chain))
+		})
+	})
+
+	Context("when creating the fetchers of EVM gateways", func() {
+		It("should share a client for each endpoint", func() {
+			family, err := DefaultRegistry().Family(multichain.Ethereum)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, first, err := family.NewFetchers("http://localhost:8545", "0x0000000000000000000000000000000000000001")
+			Expect(err).ShouldNot(HaveOccurred())
+			_, second, err := family.NewFetchers("http://localhost:8545", "0x0000000000000000000000000000000000000002")
+			Expect(err).ShouldNot(HaveOccurred())
+			_, other, err := family.NewFetchers("http://localhost:8546", "0x0000000000000000000000000000000000000001")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(second).Should(BeIdenticalTo(first))
+			Expect(other).ShouldNot(BeIdenticalTo(first))
+		})
+	})
+})
+
+var _ = Describe("Watcher control", func() {
+	Context("when controlling watchers through the admin server", func() {
+		It("should report, pause, resume and reset watchers", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mr, err := miniredis.Run()
+			Expect(err).ShouldNot(HaveOccurred())
+			defer mr.Close()
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer client.Close()
+
+			logger := logrus.New()
+			logger.SetLevel(logrus.ErrorLevel)
+
+			chain := &mockChain{mu: new(sync.Mutex), height: 100}
+			selector := tx.Selector("BTC/fromEthereum")
+			manager := NewManager(logger, func(selector tx.Selector) (Watcher, error) {
+				return NewWatcher(logger, multichain.NetworkDevnet, selector, nil, chain, chain, jsonrpcresolver.OkResponder(), client, nil, 10*time.Millisecond, 1000, 0), nil
+			})
+			manager.SetWhitelist([]tx.Selector{selector})
+			server := admin.New(admin.DefaultOptions())
+			manager.RegisterAdmin(server)
+			go manager.Run(ctx)
+
+			call := func(method string, params interface{}) jsonrpc.Response {
+				rawParams, err := json.Marshal(params)
+				Expect(err).ShouldNot(HaveOccurred())
+				body, err := json.Marshal(jsonrpc.Request{Version: "2.0", ID: 1, Method: method, Params: rawParams})
+				Expect(err).ShouldNot(HaveOccurred())
+				w := httptest.NewRecorder()
+				server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+				var response jsonrpc.Response
+				Expect(json.NewDecoder(w.Body).Decode(&response)).Should(Succeed())
+				return response
+			}
+
+			Eventually(func() uint64 { return manager.Statuses()[0].Cursor }).Should(Equal(uint64(100)))
+			response := call(MethodWatcherList, nil)
+			Expect(response.Error).Should(BeNil())
+			Expect(response.Result).Should(HaveLen(1))
+
+			response = call(MethodWatcherPause, ParamsWatcher{Selector: selector.String()})
+			Expect(response.Error).Should(BeNil())
+			Expect(response.Result).Should(HaveKeyWithValue("paused", true))
+
+			// A paused watcher does not follow the chain. Allow a poll which
+			// was in progress when the watcher was paused to finish.
+			time.Sleep(50 * time.Millisecond)
+			chain.set(120, 0)
+			time.Sleep(50 * time.Millisecond)
+			status := manager.Statuses()[0]
+			Expect(status.Cursor).Should(Equal(uint64(100)))
+			Expect(status.Tip).Should(Equal(uint64(100)))
+
+			height := uint64(90)
+			response = call(MethodWatcherResetCursor, ParamsWatcherResetCursor{Selector: selector.String(), Height: &height})
+			Expect(response.Error).Should(BeNil())
+			Expect(client.Get("BTC/fromEthereum_lastCheckedBlock").Uint64()).Should(Equal(uint64(90)))
+
+			response = call(MethodWatcherResume, ParamsWatcher{Selector: selector.String()})
+			Expect(response.Error).Should(BeNil())
+			Eventually(func() bool { return chain.fetched(90, 120) }).Should(BeTrue())
+			Eventually(func() uint64 { return manager.Statuses()[0].Cursor }).Should(Equal(uint64(120)))
+			Expect(manager.Statuses()[0].Lag).Should(BeZero())
+
+			response = call(MethodWatcherPause, ParamsWatcher{Selector: "ZEC/fromEthereum"})
+			Expect(response.Error).ShouldNot(BeNil())
+			Expect(response.Error.Code).Should(Equal(jsonrpc.ErrorCodeInvalidParams))
+		})
+	})
+})